@@ -1,8 +1,25 @@
 package blacklist
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// with redis, the bans are kept in a set shared by all the nodes and announced on a channel,
+// so that each node updates its own copy within seconds, without redis the in-process map is all there is
+const (
+	redisBlacklistKey     = "user_blacklist"
+	redisBlacklistChannel = "user_blacklist_events"
 )
 
 var blackList sync.Map
@@ -17,13 +34,128 @@ func userId2Key(id int) string {
 
 func BanUser(id int) {
 	blackList.Store(userId2Key(id), true)
+	if common.RedisEnabled {
+		publish(id, true)
+	}
 }
 
 func UnbanUser(id int) {
 	blackList.Delete(userId2Key(id))
+	if common.RedisEnabled {
+		publish(id, false)
+	}
 }
 
 func IsUserBanned(id int) bool {
 	_, ok := blackList.Load(userId2Key(id))
 	return ok
 }
+
+func publish(id int, banned bool) {
+	ctx := context.Background()
+	var err error
+	if banned {
+		err = common.RDB.SAdd(ctx, redisBlacklistKey, id).Err()
+	} else {
+		err = common.RDB.SRem(ctx, redisBlacklistKey, id).Err()
+	}
+	if err == nil {
+		err = common.RDB.Publish(ctx, redisBlacklistChannel, formatEvent(id, banned)).Err()
+	}
+	if err != nil {
+		logger.SysError("failed to publish the user blacklist: " + err.Error())
+	}
+}
+
+func formatEvent(id int, banned bool) string {
+	if banned {
+		return fmt.Sprintf("ban:%d", id)
+	}
+	return fmt.Sprintf("unban:%d", id)
+}
+
+func applyEvent(event string) {
+	action, idString, ok := strings.Cut(event, ":")
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(idString)
+	if err != nil {
+		return
+	}
+	switch action {
+	case "ban":
+		blackList.Store(userId2Key(id), true)
+	case "unban":
+		blackList.Delete(userId2Key(id))
+	}
+}
+
+// load replaces the in-process map with the shared set
+func load() {
+	members, err := common.RDB.SMembers(context.Background(), redisBlacklistKey).Result()
+	if err != nil {
+		logger.SysError("failed to load the user blacklist: " + err.Error())
+		return
+	}
+	banned := make(map[string]bool, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member)
+		if err != nil {
+			continue
+		}
+		banned[userId2Key(id)] = true
+		blackList.Store(userId2Key(id), true)
+	}
+	blackList.Range(func(key, _ any) bool {
+		if !banned[key.(string)] {
+			blackList.Delete(key)
+		}
+		return true
+	})
+}
+
+// Init follows the bans issued on the other nodes, it should be called after the redis client is initialized
+func Init() {
+	if !common.RedisEnabled {
+		return
+	}
+	client, ok := common.RDB.(redis.UniversalClient)
+	if !ok {
+		logger.SysError("the redis client doesn't support pub/sub, the user blacklist is not shared")
+		return
+	}
+	go subscribe(client)
+}
+
+func subscribe(client redis.UniversalClient) {
+	ctx := context.Background()
+	// the events missed while reconnecting are caught up by the periodic reload
+	ticker := time.NewTicker(time.Duration(config.SyncFrequency) * time.Second)
+	defer ticker.Stop()
+	for {
+		pubsub := client.Subscribe(ctx, redisBlacklistChannel)
+		_, err := pubsub.Receive(ctx)
+		if err != nil {
+			logger.SysError("failed to subscribe to the user blacklist: " + err.Error())
+			_ = pubsub.Close()
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		load()
+		channel := pubsub.Channel()
+	receive:
+		for {
+			select {
+			case message, ok := <-channel:
+				if !ok {
+					break receive
+				}
+				applyEvent(message.Payload)
+			case <-ticker.C:
+				load()
+			}
+		}
+		_ = pubsub.Close()
+	}
+}
@@ -0,0 +1,108 @@
+package blacklist
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common"
+)
+
+// fakeRedis keeps the shared set in memory and records the published events, the other commands are not used
+type fakeRedis struct {
+	redis.Cmdable
+	members map[string]bool
+	events  []string
+	err     error
+}
+
+func (r *fakeRedis) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	for _, member := range members {
+		r.members[strconv.Itoa(member.(int))] = true
+	}
+	return redis.NewIntResult(int64(len(members)), r.err)
+}
+
+func (r *fakeRedis) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	for _, member := range members {
+		delete(r.members, strconv.Itoa(member.(int)))
+	}
+	return redis.NewIntResult(int64(len(members)), r.err)
+}
+
+func (r *fakeRedis) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	var members []string
+	for member := range r.members {
+		members = append(members, member)
+	}
+	return redis.NewStringSliceResult(members, r.err)
+}
+
+func (r *fakeRedis) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	r.events = append(r.events, message.(string))
+	return redis.NewIntResult(1, r.err)
+}
+
+func resetBlackList() {
+	blackList = sync.Map{}
+}
+
+func TestApplyEvent(t *testing.T) {
+	Convey("the events published by the other nodes", t, func() {
+		resetBlackList()
+
+		Convey("ban and unban the user", func() {
+			applyEvent(formatEvent(1, true))
+			So(IsUserBanned(1), ShouldBeTrue)
+			So(IsUserBanned(2), ShouldBeFalse)
+			applyEvent(formatEvent(1, false))
+			So(IsUserBanned(1), ShouldBeFalse)
+		})
+		Convey("are ignored if malformed", func() {
+			applyEvent("ban:1")
+			for _, event := range []string{"", "ban", "ban:", "ban:abc", "kick:1", "unban:1:2"} {
+				applyEvent(event)
+			}
+			So(IsUserBanned(1), ShouldBeTrue)
+		})
+	})
+}
+
+func TestLoad(t *testing.T) {
+	Convey("loading the shared set", t, func() {
+		resetBlackList()
+		rdb := &fakeRedis{members: map[string]bool{}}
+		redisEnabled, originRDB := common.RedisEnabled, common.RDB
+		common.RedisEnabled, common.RDB = true, rdb
+		defer func() {
+			common.RedisEnabled, common.RDB = redisEnabled, originRDB
+		}()
+
+		Convey("the bans are added to the set and announced", func() {
+			BanUser(1)
+			BanUser(2)
+			UnbanUser(2)
+			So(rdb.members, ShouldResemble, map[string]bool{"1": true})
+			So(rdb.events, ShouldResemble, []string{"ban:1", "ban:2", "unban:2"})
+		})
+		Convey("replaces the in-process map", func() {
+			rdb.members = map[string]bool{"1": true, "3": true, "invalid": true}
+			applyEvent("ban:2")
+			load()
+			So(IsUserBanned(1), ShouldBeTrue)
+			So(IsUserBanned(2), ShouldBeFalse)
+			So(IsUserBanned(3), ShouldBeTrue)
+		})
+		Convey("keeps the in-process map if redis fails", func() {
+			applyEvent("ban:2")
+			rdb.err = errors.New("connection refused")
+			load()
+			So(IsUserBanned(2), ShouldBeTrue)
+		})
+	})
+}
@@ -25,6 +25,19 @@ var DisplayTokenStatEnabled = true
 // Any options with "Secret", "Token" in its key won't be return by GetOptions
 
 var SessionSecret = uuid.New().String()
+var SessionExpirationSeconds = env.Int("SESSION_EXPIRATION_SECONDS", 30*24*60*60)
+
+// TokenHashSecret salts the hashes of the token keys, a random one is generated and kept in database if not set
+var TokenHashSecret = env.String("TOKEN_HASH_SECRET", "")
+
+// channel keys and credentials are encrypted at rest when a master key is set,
+// the old keys are comma separated and only used to decrypt during key rotation
+var ChannelEncryptionKey = env.String("CHANNEL_ENCRYPTION_KEY", "")
+var ChannelEncryptionKeyFile = env.String("CHANNEL_ENCRYPTION_KEY_FILE", "")
+var ChannelEncryptionOldKeys = env.String("CHANNEL_ENCRYPTION_OLD_KEYS", "")
+
+// RelayJWTMaxLifetime is the longest a relay jwt may remain valid, unit is second
+var RelayJWTMaxLifetime = env.Int("RELAY_JWT_MAX_LIFETIME", 24*60*60)
 
 var OptionMap map[string]string
 var OptionMapRWMutex sync.RWMutex
@@ -41,6 +54,23 @@ var WeChatAuthEnabled = false
 var TurnstileCheckEnabled = false
 var RegisterEnabled = true
 
+const (
+	RegisterModeOpen     = "open"
+	RegisterModeInvite   = "invite"   // only with a registration invite, the third party accounts can't register
+	RegisterModeApproval = "approval" // the new users are pending until approved, unless they have an invite
+)
+
+// RegisterMode only applies when RegisterEnabled
+var RegisterMode = RegisterModeOpen
+var ValidRegisterModes = map[string]bool{
+	RegisterModeOpen:     true,
+	RegisterModeInvite:   true,
+	RegisterModeApproval: true,
+}
+
+// AdminTwoFactorRequiredEnabled locks the administrators out of the admin apis until they enable two-factor authentication
+var AdminTwoFactorRequiredEnabled = false
+
 var EmailDomainRestrictionEnabled = false
 var EmailDomainWhitelist = []string{
 	"gmail.com",
@@ -78,6 +108,26 @@ var OidcWellKnown = ""
 var OidcAuthorizationEndpoint = ""
 var OidcTokenEndpoint = ""
 var OidcUserinfoEndpoint = ""
+var OidcGroupClaim = ""    // e.g. groups or realm_access.roles, read from the userinfo and then the id token
+var OidcGroupMapping = ""  // json map of claim value to user group, synced at each login
+var OidcRoleMapping = ""   // json map of claim value to role, synced at each login
+var OidcRequiredGroup = "" // the users without this claim value are refused and disabled
+
+var LdapEnabled = false
+var LdapServerURL = "" // e.g. ldap://localhost:389 or ldaps://localhost:636
+var LdapStartTLSEnabled = false
+var LdapBindDN = "" // the service account to search the users, anonymous search if empty
+var LdapBindSecret = ""
+var LdapBaseDN = ""
+var LdapUserFilter = "(uid=%s)" // %s is replaced by the escaped login name
+var LdapUsernameAttribute = "uid"
+var LdapEmailAttribute = "mail"
+var LdapDisplayNameAttribute = "cn"
+var LdapGroupAttribute = "memberOf"
+var LdapGroupMapping = "" // json map of group dn to user group, synced at each login
+var LdapRoleMapping = ""  // json map of group dn to role, synced at each login
+
+var ScimToken = "" // the bearer token of the scim client, the scim endpoints are disabled if empty
 
 var WeChatServerAddress = ""
 var WeChatServerToken = ""
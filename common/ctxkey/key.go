@@ -21,4 +21,13 @@ const (
 	AvailableModels   = "available_models"
 	KeyRequestBody    = "key_request_body"
 	SystemPrompt      = "system_prompt"
+	ModelRequirement  = "model_requirement"
+	ContextFit        = "context_fit"
+	TokenRateLimit    = "token_rate_limit"
+	UsedTokens        = "used_tokens"
+	EndUserId         = "end_user_id"
+	EndUserRateLimit  = "end_user_rate_limit"
+	OrganizationId    = "organization_id"
+	// PersonalAccessTokenId is set when the management api is called with a personal access token
+	PersonalAccessTokenId = "personal_access_token_id"
 )
@@ -0,0 +1,204 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// encrypted values look like enc:v1:<key id>:<wrapped data key>:<ciphertext>,
+// values without the prefix are plaintext written before encryption was enabled
+const prefix = "enc:v1:"
+
+const dataKeySize = 32
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var currentKey *masterKey
+var masterKeys = make(map[string]*masterKey)
+
+func newMasterKey(secret string) (*masterKey, error) {
+	// any string can be used as the master key, it is stretched to an aes-256 key
+	sum := sha256.Sum256([]byte(secret))
+	aead, err := newAEAD(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	idSum := sha256.Sum256(sum[:])
+	return &masterKey{id: hex.EncodeToString(idSum[:4]), aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SetKeys replaces the master keys, the current key encrypts and all of them decrypt
+func SetKeys(current string, old ...string) error {
+	keys := make(map[string]*masterKey)
+	var currentMasterKey *masterKey
+	for i, secret := range append([]string{current}, old...) {
+		if secret == "" {
+			continue
+		}
+		key, err := newMasterKey(secret)
+		if err != nil {
+			return err
+		}
+		keys[key.id] = key
+		if i == 0 {
+			currentMasterKey = key
+		}
+	}
+	currentKey = currentMasterKey
+	masterKeys = keys
+	return nil
+}
+
+// Init loads the master key from CHANNEL_ENCRYPTION_KEY or CHANNEL_ENCRYPTION_KEY_FILE,
+// and the retired keys still used to decrypt from CHANNEL_ENCRYPTION_OLD_KEYS
+func Init() error {
+	current := config.ChannelEncryptionKey
+	if current == "" && config.ChannelEncryptionKeyFile != "" {
+		data, err := os.ReadFile(config.ChannelEncryptionKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read channel encryption key file: %w", err)
+		}
+		current = strings.TrimSpace(string(data))
+	}
+	var old []string
+	for _, secret := range strings.Split(config.ChannelEncryptionOldKeys, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			old = append(old, secret)
+		}
+	}
+	if current == "" && len(old) > 0 {
+		return errors.New("CHANNEL_ENCRYPTION_OLD_KEYS is set without CHANNEL_ENCRYPTION_KEY")
+	}
+	return SetKeys(current, old...)
+}
+
+func Enabled() bool {
+	return currentKey != nil
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// Encrypt seals the value with a new data key wrapped by the current master key,
+// the value is returned as is if encryption is disabled, or it is empty or already encrypted
+func Encrypt(value string) (string, error) {
+	if !Enabled() || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(currentKey.aead, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return prefix + currentKey.id + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt returns plaintext values as is
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	key, ok := masterKeys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("master key %s not found", parts[0])
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(key.aead, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencrypt tells whether the value is plaintext or sealed by a retired master key
+func NeedsReencrypt(value string) bool {
+	if !Enabled() || value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	return !strings.HasPrefix(value, prefix+currentKey.id+":")
+}
+
+// Reencrypt seals the value again with the current master key
+func Reencrypt(value string) (string, error) {
+	if !NeedsReencrypt(value) {
+		return value, nil
+	}
+	plaintext, err := Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(plaintext)
+}
@@ -0,0 +1,79 @@
+package encryption
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEncryption(t *testing.T) {
+	Convey("Encryption", t, func() {
+		Reset(func() {
+			_ = SetKeys("")
+		})
+
+		Convey("passes values through when disabled", func() {
+			So(SetKeys(""), ShouldBeNil)
+			value, err := Encrypt("sk-test")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "sk-test")
+			So(NeedsReencrypt(value), ShouldBeFalse)
+		})
+
+		Convey("round trips with the current key", func() {
+			So(SetKeys("master"), ShouldBeNil)
+			value, err := Encrypt("sk-test")
+			So(err, ShouldBeNil)
+			So(IsEncrypted(value), ShouldBeTrue)
+			So(value, ShouldNotContainSubstring, "sk-test")
+			plaintext, err := Decrypt(value)
+			So(err, ShouldBeNil)
+			So(plaintext, ShouldEqual, "sk-test")
+
+			again, err := Encrypt(value)
+			So(err, ShouldBeNil)
+			So(again, ShouldEqual, value)
+		})
+
+		Convey("decrypts plaintext values as is", func() {
+			So(SetKeys("master"), ShouldBeNil)
+			plaintext, err := Decrypt("sk-plain")
+			So(err, ShouldBeNil)
+			So(plaintext, ShouldEqual, "sk-plain")
+			So(NeedsReencrypt("sk-plain"), ShouldBeTrue)
+		})
+
+		Convey("rotates keys", func() {
+			So(SetKeys("old"), ShouldBeNil)
+			value, err := Encrypt("sk-test")
+			So(err, ShouldBeNil)
+
+			So(SetKeys("new"), ShouldBeNil)
+			_, err = Decrypt(value)
+			So(err, ShouldNotBeNil)
+
+			So(SetKeys("new", "old"), ShouldBeNil)
+			So(NeedsReencrypt(value), ShouldBeTrue)
+			rotated, err := Reencrypt(value)
+			So(err, ShouldBeNil)
+			So(NeedsReencrypt(rotated), ShouldBeFalse)
+
+			So(SetKeys("new"), ShouldBeNil)
+			plaintext, err := Decrypt(rotated)
+			So(err, ShouldBeNil)
+			So(plaintext, ShouldEqual, "sk-test")
+		})
+
+		Convey("rejects tampered values", func() {
+			So(SetKeys("master"), ShouldBeNil)
+			value, err := Encrypt("sk-test")
+			So(err, ShouldBeNil)
+			tampered := value[:len(value)-2] + "AA"
+			if tampered == value {
+				tampered = value[:len(value)-2] + "BB"
+			}
+			_, err = Decrypt(tampered)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -121,6 +121,33 @@ func GetRequestID(ctx context.Context) string {
 	return rawRequestId.(string)
 }
 
+func SetEndUser(ctx context.Context, endUser string) context.Context {
+	return context.WithValue(ctx, EndUserKey, endUser)
+}
+
+func GetEndUser(ctx context.Context) string {
+	endUser, _ := ctx.Value(EndUserKey).(string)
+	return endUser
+}
+
+func SetTags(ctx context.Context, tags []string) context.Context {
+	return context.WithValue(ctx, TagsKey, tags)
+}
+
+func GetTags(ctx context.Context) []string {
+	tags, _ := ctx.Value(TagsKey).([]string)
+	return tags
+}
+
+func SetOrganizationId(ctx context.Context, organizationId int) context.Context {
+	return context.WithValue(ctx, OrganizationIdKey, organizationId)
+}
+
+func GetOrganizationId(ctx context.Context) int {
+	organizationId, _ := ctx.Value(OrganizationIdKey).(int)
+	return organizationId
+}
+
 func GetResponseID(c *gin.Context) string {
 	logID := c.GetString(RequestIdKey)
 	return fmt.Sprintf("chatcmpl-%s", logID)
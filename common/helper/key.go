@@ -1,5 +1,8 @@
 package helper
 
 const (
-	RequestIdKey = "X-Oneapi-Request-Id"
+	RequestIdKey      = "X-Oneapi-Request-Id"
+	EndUserKey        = "X-End-User"
+	TagsKey           = "X-OneAPI-Tags"
+	OrganizationIdKey = "X-OneAPI-Organization-Id"
 )
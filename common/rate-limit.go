@@ -7,15 +7,22 @@ import (
 
 type InMemoryRateLimiter struct {
 	store              map[string]*[]int64
+	counters           map[string]*rateLimitCounter
 	mutex              sync.Mutex
 	expirationDuration time.Duration
 }
 
+type rateLimitCounter struct {
+	value    int64
+	expireAt int64
+}
+
 func (l *InMemoryRateLimiter) Init(expirationDuration time.Duration) {
 	if l.store == nil {
 		l.mutex.Lock()
 		if l.store == nil {
 			l.store = make(map[string]*[]int64)
+			l.counters = make(map[string]*rateLimitCounter)
 			l.expirationDuration = expirationDuration
 			if expirationDuration > 0 {
 				go l.clearExpiredItems()
@@ -37,6 +44,11 @@ func (l *InMemoryRateLimiter) clearExpiredItems() {
 				delete(l.store, key)
 			}
 		}
+		for key, counter := range l.counters {
+			if now >= counter.expireAt {
+				delete(l.counters, key)
+			}
+		}
 		l.mutex.Unlock()
 	}
 }
@@ -68,3 +80,29 @@ func (l *InMemoryRateLimiter) Request(key string, maxRequestNum int, duration in
 	}
 	return true
 }
+
+// IncrBy adds value to the counter of key and returns the new value, the counter is reset once expired
+func (l *InMemoryRateLimiter) IncrBy(key string, value int64, expiration time.Duration) int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now().Unix()
+	counter, ok := l.counters[key]
+	if !ok || now >= counter.expireAt {
+		counter = &rateLimitCounter{}
+		l.counters[key] = counter
+	}
+	counter.value += value
+	counter.expireAt = now + int64(expiration.Seconds())
+	return counter.value
+}
+
+// Get returns the value of the counter of key, 0 if it does not exist or has expired
+func (l *InMemoryRateLimiter) Get(key string) int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	counter, ok := l.counters[key]
+	if !ok || time.Now().Unix() >= counter.expireAt {
+		return 0
+	}
+	return counter.value
+}
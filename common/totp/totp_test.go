@@ -0,0 +1,56 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTOTP(t *testing.T) {
+	Convey("TOTP", t, func() {
+		// the sha1 secret of the test vectors in RFC 6238, truncated to 6 digits
+		secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+		Convey("matches the RFC 6238 test vectors", func() {
+			vectors := map[int64]string{
+				59:          "287082",
+				1111111109:  "081804",
+				1111111111:  "050471",
+				1234567890:  "005924",
+				2000000000:  "279037",
+				20000000000: "353130",
+			}
+			for unix, expected := range vectors {
+				code, err := GenerateCode(secret, time.Unix(unix, 0))
+				So(err, ShouldBeNil)
+				So(code, ShouldEqual, expected)
+			}
+		})
+
+		Convey("accepts the adjacent periods only", func() {
+			now := time.Unix(1234567890, 0)
+			code, _ := GenerateCode(secret, now)
+			step, ok := Validate(secret, code, now.Add(Period*time.Second))
+			So(ok, ShouldBeTrue)
+			So(step, ShouldEqual, now.Unix()/Period)
+			_, ok = Validate(secret, code, now.Add(3*Period*time.Second))
+			So(ok, ShouldBeFalse)
+			_, ok = Validate(secret, "12345", now)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("generates secrets usable in the provisioning uri", func() {
+			generated, err := GenerateSecret()
+			So(err, ShouldBeNil)
+			So(len(generated), ShouldEqual, 32)
+			_, err = GenerateCode(generated, time.Now())
+			So(err, ShouldBeNil)
+			uri := ProvisioningURI("One API", "root", generated)
+			So(strings.HasPrefix(uri, "otpauth://totp/One%20API:root?"), ShouldBeTrue)
+			So(uri, ShouldContainSubstring, "secret="+generated)
+		})
+	})
+}
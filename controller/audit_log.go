@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+var userAuditRedacted = []string{"password", "access_token", "verification_code"}
+
+func recordAuditLog(c *gin.Context, action string, target string, diff map[string]*model.AuditChange) {
+	model.RecordAuditLog(c.Request.Context(), c.GetInt(ctxkey.Id), c.ClientIP(), action, target, diff)
+}
+
+func GetAuditLogs(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	action := c.Query("action")
+	username := c.Query("username")
+	target := c.Query("target")
+	auditLogs, err := model.GetAuditLogs(action, username, target, startTimestamp, endTimestamp, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    auditLogs,
+	})
+	return
+}
@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/controller"
+	"github.com/songquanpeng/one-api/model"
+)
+
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Error       string `json:"error"`
+}
+
+type OAuthUser struct {
+	Subject     string
+	Username    string
+	Email       string
+	DisplayName string
+	Groups      []string
+}
+
+func getOAuthRedirectURI(name string) string {
+	return fmt.Sprintf("%s/oauth/provider/%s", config.ServerAddress, name)
+}
+
+// walkClaim walks a dotted path such as "data.user.id" through the claims
+func walkClaim(claims map[string]any, path string) any {
+	if path == "" {
+		return nil
+	}
+	var value any = claims
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func lookupClaim(claims map[string]any, path string) string {
+	switch v := walkClaim(claims, path).(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// lookupClaimValues reads a claim holding either a list or a single value, e.g. groups
+func lookupClaimValues(claims map[string]any, path string) []string {
+	value := walkClaim(claims, path)
+	if value == nil {
+		return nil
+	}
+	list, ok := value.([]any)
+	if !ok {
+		list = []any{value}
+	}
+	var values []string
+	for _, item := range list {
+		v := lookupClaim(map[string]any{"v": item}, "v")
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getOAuthUserInfoByCode(provider *model.OAuthProvider, code string) (*OAuthUser, error) {
+	if code == "" {
+		return nil, errors.New("无效的参数")
+	}
+	clientSecret, err := provider.GetClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{
+		"client_id":     {provider.ClientId},
+		"client_secret": {clientSecret},
+		"code":          {code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {getOAuthRedirectURI(provider.Name)},
+	}
+	req, err := http.NewRequest("POST", provider.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	client := http.Client{
+		Timeout: 5 * time.Second,
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		logger.SysLog(err.Error())
+		return nil, fmt.Errorf("无法连接至 %s 服务器，请稍后重试！", provider.DisplayName)
+	}
+	defer res.Body.Close()
+	var tokenResponse OAuthTokenResponse
+	err = json.NewDecoder(res.Body).Decode(&tokenResponse)
+	if err != nil {
+		return nil, err
+	}
+	if tokenResponse.AccessToken == "" {
+		return nil, fmt.Errorf("授权码兑换失败：%s", tokenResponse.Error)
+	}
+	req, err = http.NewRequest("GET", provider.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+tokenResponse.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	res2, err := client.Do(req)
+	if err != nil {
+		logger.SysLog(err.Error())
+		return nil, fmt.Errorf("无法连接至 %s 服务器，请稍后重试！", provider.DisplayName)
+	}
+	defer res2.Body.Close()
+	claims := make(map[string]any)
+	decoder := json.NewDecoder(res2.Body)
+	// keep the numeric ids as they are, a float64 would print 1e+06
+	decoder.UseNumber()
+	err = decoder.Decode(&claims)
+	if err != nil {
+		return nil, err
+	}
+	oauthUser := &OAuthUser{
+		Subject:     lookupClaim(claims, provider.UserIdClaim),
+		Username:    lookupClaim(claims, provider.UsernameClaim),
+		Email:       lookupClaim(claims, provider.EmailClaim),
+		DisplayName: lookupClaim(claims, provider.DisplayNameClaim),
+	}
+	if provider.GroupClaim != "" {
+		oauthUser.Groups = lookupClaimValues(claims, provider.GroupClaim)
+	}
+	if oauthUser.Subject == "" {
+		return nil, fmt.Errorf("用户信息缺少字段 %s", provider.UserIdClaim)
+	}
+	return oauthUser, nil
+}
+
+// GetOAuthProviders lists the enabled providers with what the login page needs to build the authorization url
+func GetOAuthProviders(c *gin.Context) {
+	providers, err := model.GetEnabledOAuthProviders()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	data := make([]gin.H, 0, len(providers))
+	for _, provider := range providers {
+		data = append(data, gin.H{
+			"name":                   provider.Name,
+			"display_name":           provider.DisplayName,
+			"client_id":              provider.ClientId,
+			"authorization_endpoint": provider.AuthorizationEndpoint,
+			"scopes":                 provider.Scopes,
+			"redirect_uri":           getOAuthRedirectURI(provider.Name),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+func GenericOAuth(c *gin.Context) {
+	ctx := c.Request.Context()
+	session := sessions.Default(c)
+	state := c.Query("state")
+	if state == "" || session.Get("oauth_state") == nil || state != session.Get("oauth_state").(string) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "state is empty or not same",
+		})
+		return
+	}
+	provider, err := model.GetEnabledOAuthProviderByName(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "登录方式不存在或未启用",
+		})
+		return
+	}
+	if controller.GetSessionUserId(c) != 0 {
+		GenericOAuthBind(c, provider)
+		return
+	}
+	oauthUser, err := getOAuthUserInfoByCode(provider, c.Query("code"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user := model.User{}
+	identity, err := model.GetUserIdentity(provider.Name, oauthUser.Subject)
+	if err == nil {
+		user.Id = identity.UserId
+		err = user.FillUserById()
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		// the groups are re-evaluated at each login so that the identity provider stays the source of truth
+		if provider.GroupClaim != "" && user.Status == model.UserStatusEnabled {
+			if !applyGroupClaim(&user, oauthUser.Groups, provider.RequiredGroup, provider.GroupMapping, provider.RoleMapping) {
+				user.Status = model.UserStatusDisabled
+			}
+			err = user.UpdateColumns("group", "role", "status")
+			if err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": err.Error(),
+				})
+				return
+			}
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	} else if !config.RegisterEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员关闭了新用户注册",
+		})
+		return
+	} else {
+		if provider.GroupClaim != "" && !applyGroupClaim(&user, oauthUser.Groups, provider.RequiredGroup, provider.GroupMapping, provider.RoleMapping) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "您不在允许使用本系统的组中",
+			})
+			return
+		}
+		user.Email = oauthUser.Email
+		user.Username = oauthUser.Username
+		if user.Username == "" || len(user.Username) > 12 || model.IsUsernameAlreadyTaken(user.Username) {
+			user.Username = "oauth_" + strconv.Itoa(model.GetMaxUserId()+1)
+		}
+		user.DisplayName = oauthUser.DisplayName
+		if user.DisplayName == "" {
+			user.DisplayName = provider.DisplayName + " User"
+		}
+		if !controller.PrepareThirdPartyRegister(c, &user) {
+			return
+		}
+		err = user.Insert(ctx, 0)
+		if err == nil {
+			identity = &model.UserIdentity{
+				UserId:   user.Id,
+				Provider: provider.Name,
+				Subject:  oauthUser.Subject,
+			}
+			err = identity.Insert()
+		}
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		controller.NotifyPendingUser(&user)
+	}
+	if user.Status == model.UserStatusPending {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "账户正在等待管理员审核",
+			"success": false,
+		})
+		return
+	}
+	if user.Status != model.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	controller.SetupLogin(&user, c)
+}
+
+func GenericOAuthBind(c *gin.Context, provider *model.OAuthProvider) {
+	oauthUser, err := getOAuthUserInfoByCode(provider, c.Query("code"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = model.GetUserIdentity(provider.Name, oauthUser.Subject)
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("该 %s 账户已被绑定", provider.DisplayName),
+		})
+		return
+	}
+	session := sessions.Default(c)
+	identity := model.UserIdentity{
+		UserId:   session.Get("id").(int),
+		Provider: provider.Name,
+		Subject:  oauthUser.Subject,
+	}
+	err = identity.Insert()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "bind",
+	})
+	return
+}
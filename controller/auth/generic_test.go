@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+func TestLookupClaim(t *testing.T) {
+	Convey("look up the claims of the userinfo", t, func() {
+		claims := make(map[string]any)
+		decoder := json.NewDecoder(strings.NewReader(`{"sub":"abc","id":1000000,"score":1.5,"verified":true,
+			"data":{"user":{"id":42,"login":"alice"}},"groups":["admins","devs"],"team":"ops","empty":null}`))
+		decoder.UseNumber()
+		So(decoder.Decode(&claims), ShouldBeNil)
+
+		So(lookupClaim(claims, "sub"), ShouldEqual, "abc")
+		So(lookupClaim(claims, "id"), ShouldEqual, "1000000")
+		So(lookupClaim(claims, "score"), ShouldEqual, "1.5")
+		So(lookupClaim(claims, "verified"), ShouldEqual, "true")
+		So(lookupClaim(claims, "data.user.id"), ShouldEqual, "42")
+		So(lookupClaim(claims, "data.user.login"), ShouldEqual, "alice")
+		So(lookupClaim(claims, "data.user.missing"), ShouldEqual, "")
+		So(lookupClaim(claims, "sub.nested"), ShouldEqual, "")
+		So(lookupClaim(claims, "data"), ShouldEqual, "")
+		So(lookupClaim(claims, "empty"), ShouldEqual, "")
+		So(lookupClaim(claims, ""), ShouldEqual, "")
+		So(lookupClaim(map[string]any{"id": float64(1000000)}, "id"), ShouldEqual, "1000000")
+
+		So(lookupClaimValues(claims, "groups"), ShouldResemble, []string{"admins", "devs"})
+		So(lookupClaimValues(claims, "team"), ShouldResemble, []string{"ops"})
+		So(lookupClaimValues(claims, "missing"), ShouldBeNil)
+	})
+}
+
+// newTestOAuthServer is an identity provider which issues "token-<code>" and returns the userinfo of the code
+func newTestOAuthServer(userinfo map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("client_secret") != "client-secret" || r.PostForm.Get("grant_type") != "authorization_code" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "token-" + r.PostForm.Get("code"), "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		code := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer token-")
+		_, _ = w.Write([]byte(userinfo[code]))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestGenericOAuth(t *testing.T) {
+	Convey("log in with a provider of the registry", t, func() {
+		modeltest.SetupDB(t)
+		server := newTestOAuthServer(map[string]string{
+			"alice":     `{"data":{"id":1001,"login":"alice","email":"alice@example.com","name":"Alice"}}`,
+			"nosubject": `{"data":{"login":"nobody"}}`,
+		})
+		defer server.Close()
+		provider := &model.OAuthProvider{
+			Name:                  "gitea",
+			DisplayName:           "Gitea",
+			ClientId:              "client-id",
+			ClientSecret:          "client-secret",
+			AuthorizationEndpoint: server.URL + "/authorize",
+			TokenEndpoint:         server.URL + "/token",
+			UserinfoEndpoint:      server.URL + "/userinfo",
+			UserIdClaim:           "data.id",
+			UsernameClaim:         "data.login",
+			EmailClaim:            "data.email",
+			DisplayNameClaim:      "data.name",
+		}
+		So(provider.Insert(), ShouldBeNil)
+		client := newTestClient(func(router *gin.Engine) {
+			router.GET("/api/oauth/state", GenerateOAuthCode)
+			router.GET("/api/oauth/provider/:name", GenericOAuth)
+		})
+		callback := func(name string, code string) (int, testResponse) {
+			_, response := client.get("/api/oauth/state")
+			var state string
+			So(json.Unmarshal(response.Data, &state), ShouldBeNil)
+			return client.get("/api/oauth/provider/" + name + "?" + url.Values{"code": {code}, "state": {state}}.Encode())
+		}
+
+		Convey("a new user registers, then logs in to the same account", func() {
+			_, response := callback("gitea", "alice")
+			So(response.Success, ShouldBeTrue)
+			identity, err := model.GetUserIdentity("gitea", "1001")
+			So(err, ShouldBeNil)
+			user, err := model.GetUserById(identity.UserId, false)
+			So(err, ShouldBeNil)
+			So(user.Username, ShouldEqual, "alice")
+			So(user.Email, ShouldEqual, "alice@example.com")
+			So(user.DisplayName, ShouldEqual, "Alice")
+
+			client.cookies = nil
+			_, response = callback("gitea", "alice")
+			So(response.Success, ShouldBeTrue)
+			var count int64
+			model.DB.Model(&model.User{}).Count(&count)
+			So(count, ShouldEqual, 1)
+		})
+		Convey("a logged-in user binds the identity of another provider, but not a linked one", func() {
+			_, response := callback("gitea", "alice")
+			So(response.Success, ShouldBeTrue)
+			_, response = callback("gitea", "alice")
+			So(response.Success, ShouldBeFalse)
+			So(response.Message, ShouldContainSubstring, "已被绑定")
+
+			other := *provider
+			other.Id = 0
+			other.Name = "gitlab"
+			So(other.Insert(), ShouldBeNil)
+			_, response = callback("gitlab", "alice")
+			So(response.Success, ShouldBeTrue)
+			gitea, _ := model.GetUserIdentity("gitea", "1001")
+			gitlab, err := model.GetUserIdentity("gitlab", "1001")
+			So(err, ShouldBeNil)
+			So(gitlab.UserId, ShouldEqual, gitea.UserId)
+		})
+		Convey("the state has to match", func() {
+			client.get("/api/oauth/state")
+			code, response := client.get("/api/oauth/provider/gitea?code=alice&state=forged")
+			So(code, ShouldEqual, http.StatusForbidden)
+			So(response.Success, ShouldBeFalse)
+		})
+		Convey("an unknown or disabled provider is refused", func() {
+			_, response := callback("unknown", "alice")
+			So(response.Success, ShouldBeFalse)
+			provider.Status = model.OAuthProviderStatusDisabled
+			So(provider.Update(), ShouldBeNil)
+			_, response = callback("gitea", "alice")
+			So(response.Success, ShouldBeFalse)
+		})
+		Convey("the userinfo has to hold the subject", func() {
+			_, response := callback("gitea", "nosubject")
+			So(response.Success, ShouldBeFalse)
+			So(response.Message, ShouldContainSubstring, "data.id")
+		})
+		Convey("new users can't register when the registration is closed", func() {
+			config.RegisterEnabled = false
+			defer func() { config.RegisterEnabled = true }()
+			_, response := callback("gitea", "alice")
+			So(response.Success, ShouldBeFalse)
+			_, err := model.GetUserIdentity("gitea", "1001")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGenericOAuthGroups(t *testing.T) {
+	Convey("sync the groups of a provider of the registry", t, func() {
+		modeltest.SetupDB(t)
+		userinfo := map[string]string{
+			"alice": `{"sub":"1","preferred_username":"alice","groups":["staff","admins"]}`,
+			"bob":   `{"sub":"2","preferred_username":"bob","groups":["guests"]}`,
+		}
+		server := newTestOAuthServer(userinfo)
+		defer server.Close()
+		provider := &model.OAuthProvider{
+			Name:                  "keycloak",
+			ClientId:              "client-id",
+			ClientSecret:          "client-secret",
+			AuthorizationEndpoint: server.URL + "/authorize",
+			TokenEndpoint:         server.URL + "/token",
+			UserinfoEndpoint:      server.URL + "/userinfo",
+			UserIdClaim:           "sub",
+			UsernameClaim:         "preferred_username",
+			GroupClaim:            "groups",
+			GroupMapping:          `{"staff":"vip"}`,
+			RoleMapping:           `{"admins":10}`,
+			RequiredGroup:         "staff",
+		}
+		So(provider.Validate(), ShouldBeNil)
+		So(provider.Insert(), ShouldBeNil)
+		client := newTestClient(func(router *gin.Engine) {
+			router.GET("/api/oauth/state", GenerateOAuthCode)
+			router.GET("/api/oauth/provider/:name", GenericOAuth)
+		})
+		callback := func(code string) testResponse {
+			client.cookies = nil
+			_, response := client.get("/api/oauth/state")
+			var state string
+			So(json.Unmarshal(response.Data, &state), ShouldBeNil)
+			_, response = client.get("/api/oauth/provider/keycloak?" + url.Values{"code": {code}, "state": {state}}.Encode())
+			return response
+		}
+		getUser := func(subject string) *model.User {
+			identity, err := model.GetUserIdentity("keycloak", subject)
+			So(err, ShouldBeNil)
+			user, err := model.GetUserById(identity.UserId, false)
+			So(err, ShouldBeNil)
+			return user
+		}
+
+		Convey("a new user gets the mapped group and role", func() {
+			So(callback("alice").Success, ShouldBeTrue)
+			user := getUser("1")
+			So(user.Group, ShouldEqual, "vip")
+			So(user.Role, ShouldEqual, model.RoleAdminUser)
+		})
+		Convey("a user outside the required group can't register", func() {
+			response := callback("bob")
+			So(response.Success, ShouldBeFalse)
+			_, err := model.GetUserIdentity("keycloak", "2")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("the groups are synced again at each login", func() {
+			So(callback("alice").Success, ShouldBeTrue)
+			So(model.DB.Model(&model.User{}).Where("id = ?", getUser("1").Id).Update("quota", 500).Error, ShouldBeNil)
+			userinfo["alice"] = `{"sub":"1","preferred_username":"alice","groups":["staff"]}`
+			So(callback("alice").Success, ShouldBeTrue)
+			user := getUser("1")
+			So(user.Role, ShouldEqual, model.RoleCommonUser)
+			So(user.Quota, ShouldEqual, 500)
+
+			userinfo["alice"] = `{"sub":"1","preferred_username":"alice","groups":[]}`
+			So(callback("alice").Success, ShouldBeFalse)
+			So(getUser("1").Status, ShouldEqual, model.UserStatusDisabled)
+		})
+		Convey("the mappings have to be json", func() {
+			provider.GroupMapping = "staff=vip"
+			So(provider.Validate(), ShouldNotBeNil)
+		})
+	})
+}
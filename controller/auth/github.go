@@ -93,8 +93,7 @@ func GitHubOAuth(c *gin.Context) {
 		})
 		return
 	}
-	username := session.Get("username")
-	if username != nil {
+	if controller.GetSessionUserId(c) != 0 {
 		GitHubBind(c)
 		return
 	}
@@ -138,6 +137,9 @@ func GitHubOAuth(c *gin.Context) {
 			user.Email = githubUser.Email
 			user.Role = model.RoleCommonUser
 			user.Status = model.UserStatusEnabled
+			if !controller.PrepareThirdPartyRegister(c, &user) {
+				return
+			}
 
 			if err := user.Insert(ctx, 0); err != nil {
 				c.JSON(http.StatusOK, gin.H{
@@ -146,6 +148,7 @@ func GitHubOAuth(c *gin.Context) {
 				})
 				return
 			}
+			controller.NotifyPendingUser(&user)
 		} else {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -155,6 +158,13 @@ func GitHubOAuth(c *gin.Context) {
 		}
 	}
 
+	if user.Status == model.UserStatusPending {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "账户正在等待管理员审核",
+			"success": false,
+		})
+		return
+	}
 	if user.Status != model.UserStatusEnabled {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "用户已被封禁",
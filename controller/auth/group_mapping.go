@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// syncMappedGroups applies the group and role mappings of a directory or an identity provider,
+// the first mapped group wins for the user group, the highest mapped role wins for the role,
+// and the users in no mapped group fall back to the defaults
+func syncMappedGroups(user *model.User, groups []string, groupMappingJSON string, roleMappingJSON string) error {
+	if groupMappingJSON != "" {
+		groupMapping := make(map[string]string)
+		err := json.Unmarshal([]byte(groupMappingJSON), &groupMapping)
+		if err != nil {
+			return err
+		}
+		user.Group = "default"
+		for _, group := range groups {
+			if mapped, ok := lookupFold(groupMapping, group); ok {
+				user.Group = mapped
+				break
+			}
+		}
+	}
+	if roleMappingJSON != "" && user.Role != model.RoleRootUser {
+		roleMapping := make(map[string]int)
+		err := json.Unmarshal([]byte(roleMappingJSON), &roleMapping)
+		if err != nil {
+			return err
+		}
+		user.Role = model.RoleCommonUser
+		for group, role := range roleMapping {
+			// the root user can't be granted from outside
+			if containsFold(groups, group) && role > user.Role && role < model.RoleRootUser {
+				user.Role = role
+			}
+		}
+	}
+	return nil
+}
+
+// applyGroupClaim checks the required group and syncs the mapped group and role,
+// it returns false if the user is not allowed to log in
+func applyGroupClaim(user *model.User, groups []string, requiredGroup string, groupMappingJSON string, roleMappingJSON string) bool {
+	if requiredGroup != "" && !containsFold(groups, requiredGroup) {
+		// the root user can't be locked out by the identity provider
+		return user.Role == model.RoleRootUser
+	}
+	err := syncMappedGroups(user, groups, groupMappingJSON, roleMappingJSON)
+	if err != nil {
+		logger.SysError("failed to apply group mapping: " + err.Error())
+	}
+	return true
+}
+
+func containsFold(groups []string, group string) bool {
+	for _, g := range groups {
+		if strings.EqualFold(g, group) {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupFold(mapping map[string]string, key string) (string, bool) {
+	for k, v := range mapping {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+func TestSyncMappedGroups(t *testing.T) {
+	Convey("apply the group and role mappings", t, func() {
+		groupMapping := `{"CN=Staff,DC=example":"vip","contractors":"trial"}`
+		roleMapping := `{"cn=admins,dc=example":10,"owners":100}`
+
+		Convey("the first mapped group and the highest role win, case-insensitively", func() {
+			user := &model.User{Group: "default", Role: model.RoleCommonUser}
+			err := syncMappedGroups(user, []string{"cn=staff,dc=example", "contractors", "CN=Admins,DC=example"}, groupMapping, roleMapping)
+			So(err, ShouldBeNil)
+			So(user.Group, ShouldEqual, "vip")
+			So(user.Role, ShouldEqual, model.RoleAdminUser)
+		})
+		Convey("the users in no mapped group fall back to the defaults", func() {
+			user := &model.User{Group: "vip", Role: model.RoleAdminUser}
+			So(syncMappedGroups(user, []string{"others"}, groupMapping, roleMapping), ShouldBeNil)
+			So(user.Group, ShouldEqual, "default")
+			So(user.Role, ShouldEqual, model.RoleCommonUser)
+		})
+		Convey("the root user is neither granted nor demoted", func() {
+			user := &model.User{Role: model.RoleCommonUser}
+			So(syncMappedGroups(user, []string{"owners"}, "", roleMapping), ShouldBeNil)
+			So(user.Role, ShouldEqual, model.RoleCommonUser)
+			root := &model.User{Role: model.RoleRootUser}
+			So(syncMappedGroups(root, nil, "", roleMapping), ShouldBeNil)
+			So(root.Role, ShouldEqual, model.RoleRootUser)
+		})
+		Convey("empty mappings keep the user as is", func() {
+			user := &model.User{Group: "vip", Role: model.RoleAdminUser}
+			So(syncMappedGroups(user, nil, "", ""), ShouldBeNil)
+			So(user.Group, ShouldEqual, "vip")
+			So(user.Role, ShouldEqual, model.RoleAdminUser)
+		})
+		Convey("the required group", func() {
+			user := &model.User{Role: model.RoleCommonUser}
+			So(applyGroupClaim(user, []string{"staff"}, "Staff", "", ""), ShouldBeTrue)
+			So(applyGroupClaim(user, []string{"guests"}, "staff", "", ""), ShouldBeFalse)
+			root := &model.User{Role: model.RoleRootUser}
+			So(applyGroupClaim(root, nil, "staff", "", ""), ShouldBeTrue)
+		})
+	})
+}
@@ -91,8 +91,7 @@ func LarkOAuth(c *gin.Context) {
 		})
 		return
 	}
-	username := session.Get("username")
-	if username != nil {
+	if controller.GetSessionUserId(c) != 0 {
 		LarkBind(c)
 		return
 	}
@@ -127,6 +126,9 @@ func LarkOAuth(c *gin.Context) {
 			}
 			user.Role = model.RoleCommonUser
 			user.Status = model.UserStatusEnabled
+			if !controller.PrepareThirdPartyRegister(c, &user) {
+				return
+			}
 
 			if err := user.Insert(ctx, 0); err != nil {
 				c.JSON(http.StatusOK, gin.H{
@@ -135,6 +137,7 @@ func LarkOAuth(c *gin.Context) {
 				})
 				return
 			}
+			controller.NotifyPendingUser(&user)
 		} else {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -144,6 +147,13 @@ func LarkOAuth(c *gin.Context) {
 		}
 	}
 
+	if user.Status == model.UserStatusPending {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "账户正在等待管理员审核",
+			"success": false,
+		})
+		return
+	}
 	if user.Status != model.UserStatusEnabled {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "用户已被封禁",
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/controller"
+	"github.com/songquanpeng/one-api/model"
+)
+
+type LdapLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LdapUser struct {
+	Username    string
+	Email       string
+	DisplayName string
+	Groups      []string
+}
+
+// dialLdap connects to the ldap server, it is replaced by a fake directory in the tests
+var dialLdap = dialLdapServer
+
+func dialLdapServer() (ldap.Client, error) {
+	conn, err := ldap.DialURL(config.LdapServerURL, ldap.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(5 * time.Second)
+	if config.LdapStartTLSEnabled {
+		err = conn.StartTLS(&tls.Config{ServerName: ldapServerName()})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func ldapServerName() string {
+	u, err := url.Parse(config.LdapServerURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// authenticateLdapUser looks up the entry of the login name with the service account, then binds as it to check the password
+func authenticateLdapUser(username string, password string) (*LdapUser, error) {
+	// an empty password is an unauthenticated bind, which most servers accept
+	if username == "" || password == "" {
+		return nil, errors.New("用户名或密码为空")
+	}
+	conn, err := dialLdap()
+	if err != nil {
+		logger.SysError("failed to connect to ldap server: " + err.Error())
+		return nil, errors.New("无法连接至 LDAP 服务器，请稍后重试！")
+	}
+	defer conn.Close()
+	if config.LdapBindDN != "" {
+		err = conn.Bind(config.LdapBindDN, config.LdapBindSecret)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		logger.SysError("failed to bind ldap service account: " + err.Error())
+		return nil, errors.New("LDAP 服务账号认证失败，请联系管理员")
+	}
+	attributes := []string{"dn", config.LdapUsernameAttribute, config.LdapEmailAttribute, config.LdapDisplayNameAttribute}
+	if config.LdapGroupAttribute != "" {
+		attributes = append(attributes, config.LdapGroupAttribute)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		config.LdapBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 5, false,
+		fmt.Sprintf(config.LdapUserFilter, ldap.EscapeFilter(username)),
+		attributes,
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		logger.SysError("failed to search ldap user: " + err.Error())
+		return nil, errors.New("用户名或密码错误")
+	}
+	if result == nil || len(result.Entries) != 1 {
+		return nil, errors.New("用户名或密码错误")
+	}
+	entry := result.Entries[0]
+	err = conn.Bind(entry.DN, password)
+	if err != nil {
+		return nil, errors.New("用户名或密码错误")
+	}
+	ldapUser := &LdapUser{
+		Username:    entry.GetAttributeValue(config.LdapUsernameAttribute),
+		Email:       entry.GetAttributeValue(config.LdapEmailAttribute),
+		DisplayName: entry.GetAttributeValue(config.LdapDisplayNameAttribute),
+	}
+	if config.LdapGroupAttribute != "" {
+		ldapUser.Groups = entry.GetAttributeValues(config.LdapGroupAttribute)
+	}
+	if ldapUser.Username == "" {
+		return nil, fmt.Errorf("LDAP 用户缺少属性 %s", config.LdapUsernameAttribute)
+	}
+	return ldapUser, nil
+}
+
+func LdapAuth(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !config.LdapEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通过 LDAP 登录以及注册",
+		})
+		return
+	}
+	var req LdapLoginRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	ldapUser, err := authenticateLdapUser(req.Username, req.Password)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user := model.User{
+		LdapId: strings.ToLower(ldapUser.Username),
+	}
+	exists := model.IsLdapIdAlreadyTaken(user.LdapId)
+	if exists {
+		err = user.FillUserByLdapId()
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	} else if !config.RegisterEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员关闭了新用户注册",
+		})
+		return
+	} else {
+		user.Username = ldapUser.Username
+		if len(user.Username) > 12 || model.IsUsernameAlreadyTaken(user.Username) {
+			user.Username = "ldap_" + strconv.Itoa(model.GetMaxUserId()+1)
+		}
+		user.Role = model.RoleCommonUser
+		if !controller.PrepareThirdPartyRegister(c, &user) {
+			return
+		}
+	}
+	// the profile is kept in sync with the directory at each login
+	if ldapUser.Email != "" {
+		user.Email = ldapUser.Email
+	}
+	user.DisplayName = ldapUser.DisplayName
+	if user.DisplayName == "" {
+		user.DisplayName = "LDAP User"
+	}
+	err = syncMappedGroups(&user, ldapUser.Groups, config.LdapGroupMapping, config.LdapRoleMapping)
+	if err != nil {
+		logger.SysError("failed to apply ldap group mapping: " + err.Error())
+	}
+	if exists {
+		err = user.UpdateColumns("email", "display_name", "group", "role")
+	} else {
+		err = user.Insert(ctx, 0)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !exists {
+		controller.NotifyPendingUser(&user)
+	}
+	if user.Status == model.UserStatusPending {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "账户正在等待管理员审核",
+			"success": false,
+		})
+		return
+	}
+	if user.Status != model.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	controller.SetupLogin(&user, c)
+}
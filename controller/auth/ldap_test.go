@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-ldap/ldap/v3"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+// fakeLdap is a directory with a service account, only the calls made by authenticateLdapUser are implemented
+type fakeLdap struct {
+	ldap.Client
+	entries   []*ldap.Entry
+	passwords map[string]string // by dn
+	filters   []string
+	dials     int
+}
+
+func (f *fakeLdap) dial() (ldap.Client, error) {
+	f.dials++
+	return f, nil
+}
+
+func (f *fakeLdap) Close() error {
+	return nil
+}
+
+func (f *fakeLdap) Bind(dn string, password string) error {
+	if password == "" || f.passwords[dn] != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	return nil
+}
+
+// Search matches the entries by the uid, the filter is expected to be the default (uid=%s)
+func (f *fakeLdap) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	f.filters = append(f.filters, request.Filter)
+	result := &ldap.SearchResult{}
+	for _, entry := range f.entries {
+		if request.Filter == "(uid="+entry.GetAttributeValue("uid")+")" {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	return result, nil
+}
+
+func newFakeLdap() *fakeLdap {
+	return &fakeLdap{
+		entries: []*ldap.Entry{
+			ldap.NewEntry("uid=alice,ou=people,dc=example,dc=org", map[string][]string{
+				"uid":      {"alice"},
+				"mail":     {"alice@example.org"},
+				"cn":       {"Alice"},
+				"memberOf": {"cn=staff,ou=groups,dc=example,dc=org", "cn=admins,ou=groups,dc=example,dc=org"},
+			}),
+			ldap.NewEntry("uid=twin,ou=people,dc=example,dc=org", map[string][]string{"uid": {"twin"}}),
+			ldap.NewEntry("uid=twin,ou=contractors,dc=example,dc=org", map[string][]string{"uid": {"twin"}}),
+		},
+		passwords: map[string]string{
+			"cn=admin,dc=example,dc=org":                "admin",
+			"uid=alice,ou=people,dc=example,dc=org":     "alice-password",
+			"uid=twin,ou=people,dc=example,dc=org":      "twin-password",
+			"uid=twin,ou=contractors,dc=example,dc=org": "twin-password",
+		},
+	}
+}
+
+func setupLdapConfig(t *testing.T, serverURL string) {
+	enabled, url, bindDN, bindSecret, baseDN := config.LdapEnabled, config.LdapServerURL, config.LdapBindDN, config.LdapBindSecret, config.LdapBaseDN
+	groupMapping, roleMapping := config.LdapGroupMapping, config.LdapRoleMapping
+	t.Cleanup(func() {
+		config.LdapEnabled, config.LdapServerURL, config.LdapBindDN, config.LdapBindSecret, config.LdapBaseDN = enabled, url, bindDN, bindSecret, baseDN
+		config.LdapGroupMapping, config.LdapRoleMapping = groupMapping, roleMapping
+	})
+	config.LdapEnabled = true
+	config.LdapServerURL = serverURL
+	config.LdapBindDN = "cn=admin,dc=example,dc=org"
+	config.LdapBindSecret = "admin"
+	config.LdapBaseDN = "dc=example,dc=org"
+	config.LdapGroupMapping = ""
+	config.LdapRoleMapping = ""
+}
+
+func TestAuthenticateLdapUser(t *testing.T) {
+	Convey("authenticate the users against the directory", t, func() {
+		setupLdapConfig(t, "ldap://localhost")
+		directory := newFakeLdap()
+		dialLdap = directory.dial
+		defer func() { dialLdap = dialLdapServer }()
+
+		Convey("a user logs in with the password of the entry", func() {
+			ldapUser, err := authenticateLdapUser("alice", "alice-password")
+			So(err, ShouldBeNil)
+			So(ldapUser.Username, ShouldEqual, "alice")
+			So(ldapUser.Email, ShouldEqual, "alice@example.org")
+			So(ldapUser.DisplayName, ShouldEqual, "Alice")
+			So(ldapUser.Groups, ShouldHaveLength, 2)
+		})
+		Convey("a wrong password is refused", func() {
+			_, err := authenticateLdapUser("alice", "wrong")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("an empty password is refused before connecting", func() {
+			// the servers accept an empty password as an unauthenticated bind
+			_, err := authenticateLdapUser("alice", "")
+			So(err, ShouldNotBeNil)
+			So(directory.dials, ShouldEqual, 0)
+		})
+		Convey("the login name is escaped in the filter", func() {
+			_, err := authenticateLdapUser("*)(uid=alice", "alice-password")
+			So(err, ShouldNotBeNil)
+			So(directory.filters, ShouldResemble, []string{`(uid=\2a\29\28uid=alice)`})
+			_, err = authenticateLdapUser("*", "alice-password")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("a login name matching several entries is refused", func() {
+			_, err := authenticateLdapUser("twin", "twin-password")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("an unknown login name is refused", func() {
+			_, err := authenticateLdapUser("mallory", "alice-password")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestLdapAuth(t *testing.T) {
+	Convey("log in with ldap", t, func() {
+		modeltest.SetupDB(t)
+		setupLdapConfig(t, "ldap://localhost")
+		config.LdapGroupMapping = `{"cn=staff,ou=groups,dc=example,dc=org":"vip"}`
+		config.LdapRoleMapping = `{"cn=admins,ou=groups,dc=example,dc=org":10}`
+		directory := newFakeLdap()
+		dialLdap = directory.dial
+		defer func() { dialLdap = dialLdapServer }()
+		client := newTestClient(func(router *gin.Engine) {
+			router.POST("/api/oauth/ldap", LdapAuth)
+		})
+		login := func(username string, password string) testResponse {
+			client.cookies = nil
+			_, response := client.post("/api/oauth/ldap", LdapLoginRequest{Username: username, Password: password})
+			return response
+		}
+
+		Convey("the group and the role of an existing user are synced at each login", func() {
+			user := &model.User{Username: "alice", LdapId: "alice", Role: model.RoleCommonUser, Group: "default", Status: model.UserStatusEnabled, AffCode: "alice", AccessToken: "alice"}
+			So(model.DB.Create(user).Error, ShouldBeNil)
+
+			So(login("alice", "alice-password").Success, ShouldBeTrue)
+			synced, err := model.GetUserById(user.Id, false)
+			So(err, ShouldBeNil)
+			So(synced.Group, ShouldEqual, "vip")
+			So(synced.Role, ShouldEqual, model.RoleAdminUser)
+			So(synced.Email, ShouldEqual, "alice@example.org")
+			So(synced.DisplayName, ShouldEqual, "Alice")
+
+			// removed from the groups in the directory
+			alice := directory.entries[0]
+			for i, attribute := range alice.Attributes {
+				if attribute.Name == "memberOf" {
+					alice.Attributes = append(alice.Attributes[:i], alice.Attributes[i+1:]...)
+					break
+				}
+			}
+			So(login("alice", "alice-password").Success, ShouldBeTrue)
+			synced, err = model.GetUserById(user.Id, false)
+			So(err, ShouldBeNil)
+			So(synced.Group, ShouldEqual, "default")
+			So(synced.Role, ShouldEqual, model.RoleCommonUser)
+		})
+		Convey("a new user registers", func() {
+			So(login("alice", "alice-password").Success, ShouldBeTrue)
+			So(model.IsLdapIdAlreadyTaken("alice"), ShouldBeTrue)
+		})
+		Convey("an empty password is refused", func() {
+			response := login("alice", "")
+			So(response.Success, ShouldBeFalse)
+			So(model.IsLdapIdAlreadyTaken("alice"), ShouldBeFalse)
+		})
+		Convey("the login is refused when ldap is disabled", func() {
+			config.LdapEnabled = false
+			So(login("alice", "alice-password").Success, ShouldBeFalse)
+			So(directory.dials, ShouldEqual, 0)
+		})
+	})
+}
+
+// TestLdapServer runs against a real directory, start it with
+// docker compose -f controller/auth/testdata/ldap/docker-compose.yml up -d
+// and set LDAP_TEST_URL=ldap://localhost:3389
+func TestLdapServer(t *testing.T) {
+	serverURL := os.Getenv("LDAP_TEST_URL")
+	if serverURL == "" {
+		t.Skip("LDAP_TEST_URL is not set")
+	}
+	Convey("authenticate the users against openldap", t, func() {
+		setupLdapConfig(t, serverURL)
+
+		ldapUser, err := authenticateLdapUser("alice", "alice-password")
+		So(err, ShouldBeNil)
+		So(ldapUser.Email, ShouldEqual, "alice@example.org")
+		groups := strings.ToLower(strings.Join(ldapUser.Groups, ";"))
+		So(groups, ShouldContainSubstring, "cn=admins,ou=groups,dc=example,dc=org")
+
+		_, err = authenticateLdapUser("alice", "wrong")
+		So(err, ShouldNotBeNil)
+		_, err = authenticateLdapUser("alice", "")
+		So(err, ShouldNotBeNil)
+		_, err = authenticateLdapUser("*", "alice-password")
+		So(err, ShouldNotBeNil)
+		_, err = authenticateLdapUser("twin", "twin-password")
+		So(err, ShouldNotBeNil)
+	})
+}
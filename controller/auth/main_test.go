@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+type testResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// testClient keeps the session cookie between the requests, like a browser
+type testClient struct {
+	router  *gin.Engine
+	cookies []*http.Cookie
+}
+
+func newTestClient(routes func(router *gin.Engine)) *testClient {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+	routes(router)
+	return &testClient{router: router}
+}
+
+func (client *testClient) get(path string) (int, testResponse) {
+	return client.do(http.MethodGet, path, nil)
+}
+
+// post sends the body as json
+func (client *testClient) post(path string, body any) (int, testResponse) {
+	jsonBytes, _ := json.Marshal(body)
+	return client.do(http.MethodPost, path, bytes.NewReader(jsonBytes))
+}
+
+func (client *testClient) do(method string, path string, body io.Reader) (int, testResponse) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	for _, c := range client.cookies {
+		req.AddCookie(c)
+	}
+	client.router.ServeHTTP(w, req)
+	if cookies := w.Result().Cookies(); len(cookies) > 0 {
+		client.cookies = cookies
+	}
+	response := testResponse{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
@@ -2,11 +2,14 @@ package auth
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -28,11 +31,12 @@ type OidcResponse struct {
 }
 
 type OidcUser struct {
-	OpenID            string `json:"sub"`
-	Email             string `json:"email"`
-	Name              string `json:"name"`
-	PreferredUsername string `json:"preferred_username"`
-	Picture           string `json:"picture"`
+	OpenID            string   `json:"sub"`
+	Email             string   `json:"email"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Picture           string   `json:"picture"`
+	Groups            []string `json:"-"`
 }
 
 func getOidcUserInfoByCode(code string) (*OidcUser, error) {
@@ -80,14 +84,52 @@ func getOidcUserInfoByCode(code string) (*OidcUser, error) {
 		logger.SysLog(err.Error())
 		return nil, errors.New("无法连接至 OIDC 服务器，请稍后重试！")
 	}
+	defer res2.Body.Close()
+	body, err := io.ReadAll(res2.Body)
+	if err != nil {
+		return nil, err
+	}
 	var oidcUser OidcUser
-	err = json.NewDecoder(res2.Body).Decode(&oidcUser)
+	err = json.Unmarshal(body, &oidcUser)
 	if err != nil {
 		return nil, err
 	}
+	if config.OidcGroupClaim != "" {
+		claims := make(map[string]any)
+		_ = json.Unmarshal(body, &claims)
+		oidcUser.Groups = lookupClaimValues(claims, config.OidcGroupClaim)
+		if oidcUser.Groups == nil && strings.HasPrefix(config.OidcTokenEndpoint, "https://") {
+			oidcUser.Groups = lookupClaimValues(getIdTokenClaims(oidcResponse.IDToken), config.OidcGroupClaim)
+		}
+	}
 	return &oidcUser, nil
 }
 
+// getIdTokenClaims reads the payload of the id token without checking the signature, which is only allowed
+// when the token comes straight from the token endpoint over tls, see OpenID Connect Core 1.0 section 3.1.3.7
+func getIdTokenClaims(idToken string) map[string]any {
+	claims := make(map[string]any)
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return claims
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims
+	}
+	_ = json.Unmarshal(payload, &claims)
+	return claims
+}
+
+// applyOidcGroups checks the required group and syncs the mapped group and role,
+// it returns false if the user is not allowed to log in
+func applyOidcGroups(user *model.User, groups []string) bool {
+	if config.OidcGroupClaim == "" {
+		return true
+	}
+	return applyGroupClaim(user, groups, config.OidcRequiredGroup, config.OidcGroupMapping, config.OidcRoleMapping)
+}
+
 func OidcAuth(c *gin.Context) {
 	ctx := c.Request.Context()
 	session := sessions.Default(c)
@@ -99,8 +141,7 @@ func OidcAuth(c *gin.Context) {
 		})
 		return
 	}
-	username := session.Get("username")
-	if username != nil {
+	if controller.GetSessionUserId(c) != 0 {
 		OidcBind(c)
 		return
 	}
@@ -132,8 +173,29 @@ func OidcAuth(c *gin.Context) {
 			})
 			return
 		}
+		// the groups are re-evaluated at each login so that the identity provider stays the source of truth
+		if config.OidcGroupClaim != "" && user.Status == model.UserStatusEnabled {
+			if !applyOidcGroups(&user, oidcUser.Groups) {
+				user.Status = model.UserStatusDisabled
+			}
+			err = user.UpdateColumns("group", "role", "status")
+			if err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": err.Error(),
+				})
+				return
+			}
+		}
 	} else {
 		if config.RegisterEnabled {
+			if !applyOidcGroups(&user, oidcUser.Groups) {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "您不在允许使用本系统的组中",
+				})
+				return
+			}
 			user.Email = oidcUser.Email
 			if oidcUser.PreferredUsername != "" {
 				user.Username = oidcUser.PreferredUsername
@@ -145,6 +207,9 @@ func OidcAuth(c *gin.Context) {
 			} else {
 				user.DisplayName = "OIDC User"
 			}
+			if !controller.PrepareThirdPartyRegister(c, &user) {
+				return
+			}
 			err := user.Insert(ctx, 0)
 			if err != nil {
 				c.JSON(http.StatusOK, gin.H{
@@ -153,6 +218,7 @@ func OidcAuth(c *gin.Context) {
 				})
 				return
 			}
+			controller.NotifyPendingUser(&user)
 		} else {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -162,6 +228,13 @@ func OidcAuth(c *gin.Context) {
 		}
 	}
 
+	if user.Status == model.UserStatusPending {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "账户正在等待管理员审核",
+			"success": false,
+		})
+		return
+	}
 	if user.Status != model.UserStatusEnabled {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "用户已被封禁",
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+func TestGetOidcUserInfoByCode(t *testing.T) {
+	Convey("read the groups of an oidc user", t, func() {
+		payload, _ := json.Marshal(map[string]any{"sub": "1", "groups": []string{"admins"}})
+		idToken := "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+		userinfo := `{"sub":"1","preferred_username":"alice"}`
+		mux := http.NewServeMux()
+		mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "token", "id_token": idToken})
+		})
+		mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(userinfo))
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		config.OidcTokenEndpoint = server.URL + "/token"
+		config.OidcUserinfoEndpoint = server.URL + "/userinfo"
+		config.OidcGroupClaim = "groups"
+		defer func() {
+			config.OidcTokenEndpoint, config.OidcUserinfoEndpoint, config.OidcGroupClaim = "", "", ""
+		}()
+
+		Convey("from the userinfo", func() {
+			userinfo = `{"sub":"1","groups":["staff"]}`
+			oidcUser, err := getOidcUserInfoByCode("code")
+			So(err, ShouldBeNil)
+			So(oidcUser.Groups, ShouldResemble, []string{"staff"})
+		})
+		Convey("not from an unverified id token sent over plain http", func() {
+			oidcUser, err := getOidcUserInfoByCode("code")
+			So(err, ShouldBeNil)
+			So(oidcUser.OpenID, ShouldEqual, "1")
+			So(oidcUser.Groups, ShouldBeEmpty)
+		})
+		Convey("the payload of the id token", func() {
+			So(getIdTokenClaims(idToken)["groups"], ShouldResemble, []any{"admins"})
+			So(getIdTokenClaims("not a token"), ShouldBeEmpty)
+		})
+	})
+}
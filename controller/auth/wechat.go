@@ -89,6 +89,9 @@ func WeChatAuth(c *gin.Context) {
 			user.DisplayName = "WeChat User"
 			user.Role = model.RoleCommonUser
 			user.Status = model.UserStatusEnabled
+			if !controller.PrepareThirdPartyRegister(c, &user) {
+				return
+			}
 
 			if err := user.Insert(ctx, 0); err != nil {
 				c.JSON(http.StatusOK, gin.H{
@@ -97,6 +100,7 @@ func WeChatAuth(c *gin.Context) {
 				})
 				return
 			}
+			controller.NotifyPendingUser(&user)
 		} else {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -106,6 +110,13 @@ func WeChatAuth(c *gin.Context) {
 		}
 	}
 
+	if user.Status == model.UserStatusPending {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "账户正在等待管理员审核",
+			"success": false,
+		})
+		return
+	}
 	if user.Status != model.UserStatusEnabled {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "用户已被封禁",
@@ -117,6 +128,13 @@ func WeChatAuth(c *gin.Context) {
 }
 
 func WeChatBind(c *gin.Context) {
+	if controller.IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌绑定微信账户",
+		})
+		return
+	}
 	if !config.WeChatAuthEnabled {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "管理员未开启通过微信登录以及注册",
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/ctxkey"
@@ -14,6 +16,7 @@ func GetSubscription(c *gin.Context) {
 	var err error
 	var token *model.Token
 	var expiredTime int64
+	var periodUsedQuota int64
 	if config.DisplayTokenStatEnabled {
 		tokenId := c.GetInt(ctxkey.TokenId)
 		token, err = model.GetTokenById(tokenId)
@@ -21,6 +24,16 @@ func GetSubscription(c *gin.Context) {
 			expiredTime = token.ExpiredTime
 			remainQuota = token.RemainQuota
 			usedQuota = token.UsedQuota
+			if token.PeriodQuota > 0 && token.HasBudget() {
+				periodUsedQuota, err = model.GetTokenPeriodUsedQuota(token, "")
+			}
+		}
+	} else if organizationId := c.GetInt(ctxkey.OrganizationId); organizationId != 0 {
+		var organization *model.Organization
+		organization, err = model.GetOrganizationById(organizationId)
+		if err == nil {
+			remainQuota = organization.Quota
+			usedQuota = organization.UsedQuota
 		}
 	} else {
 		userId := c.GetInt(ctxkey.Id)
@@ -58,6 +71,20 @@ func GetSubscription(c *gin.Context) {
 		SystemHardLimitUSD: amount,
 		AccessUntil:        expiredTime,
 	}
+	if token != nil && token.PeriodQuota > 0 && token.HasBudget() {
+		// the budget of the current period is the limit that matters for the token
+		periodAmount := float64(token.PeriodQuota)
+		periodUsedAmount := float64(periodUsedQuota)
+		if config.DisplayInCurrencyEnabled {
+			periodAmount /= config.QuotaPerUnit
+			periodUsedAmount /= config.QuotaPerUnit
+		}
+		subscription.SoftLimitUSD = periodAmount
+		subscription.HardLimitUSD = periodAmount
+		subscription.BudgetPeriod = token.BudgetPeriod
+		subscription.PeriodUsedUSD = periodUsedAmount
+		_, subscription.PeriodResetAt = model.GetBudgetPeriod(token.BudgetPeriod, time.Now())
+	}
 	c.JSON(200, subscription)
 	return
 }
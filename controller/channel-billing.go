@@ -28,6 +28,10 @@ type OpenAISubscriptionResponse struct {
 	HardLimitUSD       float64 `json:"hard_limit_usd"`
 	SystemHardLimitUSD float64 `json:"system_hard_limit_usd"`
 	AccessUntil        int64   `json:"access_until"`
+	// the fields below are only set for the tokens with a periodic budget
+	BudgetPeriod  string  `json:"budget_period,omitempty"`
+	PeriodUsedUSD float64 `json:"period_used_usd,omitempty"`
+	PeriodResetAt int64   `json:"period_reset_at,omitempty"`
 }
 
 type OpenAIUsageDailyCost struct {
@@ -309,6 +313,12 @@ func updateChannelOpenRouterBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelBalance(channel *model.Channel) (float64, error) {
+	// the balance apis need the plaintext key, the channel is not saved back so it stays in memory only
+	key, err := channel.GetKey()
+	if err != nil {
+		return 0, err
+	}
+	channel.Key = key
 	baseURL := channeltype.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() == "" {
 		channel.BaseURL = &baseURL
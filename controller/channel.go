@@ -3,6 +3,7 @@ package controller
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/model"
 	"net/http"
@@ -10,6 +11,16 @@ import (
 	"strings"
 )
 
+// redactChannels hides the credentials from the users who may read but not edit the channels
+func redactChannels(c *gin.Context, channels ...*model.Channel) {
+	if model.HasPermission(c.GetInt(ctxkey.Id), c.GetInt(ctxkey.Role), model.PermissionChannelWrite) {
+		return
+	}
+	for _, channel := range channels {
+		channel.RedactSecrets()
+	}
+}
+
 func GetAllChannels(c *gin.Context) {
 	p, _ := strconv.Atoi(c.Query("p"))
 	if p < 0 {
@@ -23,6 +34,7 @@ func GetAllChannels(c *gin.Context) {
 		})
 		return
 	}
+	redactChannels(c, channels...)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -41,6 +53,7 @@ func SearchChannels(c *gin.Context) {
 		})
 		return
 	}
+	redactChannels(c, channels...)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -66,6 +79,7 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	redactChannels(c, channel)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -103,6 +117,9 @@ func AddChannel(c *gin.Context) {
 		})
 		return
 	}
+	for i := range channels {
+		recordAuditLog(c, model.AuditActionChannelAdd, strconv.Itoa(channels[i].Id), model.ChannelAuditDiff(nil, &channels[i]))
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -112,8 +129,16 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	originChannel, err := model.GetChannelById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	channel := model.Channel{Id: id}
-	err := channel.Delete()
+	err = channel.Delete()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -121,6 +146,7 @@ func DeleteChannel(c *gin.Context) {
 		})
 		return
 	}
+	recordAuditLog(c, model.AuditActionChannelDelete, strconv.Itoa(id), model.ChannelAuditDiff(originChannel, nil))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -129,6 +155,14 @@ func DeleteChannel(c *gin.Context) {
 }
 
 func DeleteDisabledChannel(c *gin.Context) {
+	disabledChannels, err := model.GetAllChannels(0, 0, "disabled")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	rows, err := model.DeleteDisabledChannel()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -137,6 +171,9 @@ func DeleteDisabledChannel(c *gin.Context) {
 		})
 		return
 	}
+	for _, channel := range disabledChannels {
+		recordAuditLog(c, model.AuditActionChannelDelete, strconv.Itoa(channel.Id), model.ChannelAuditDiff(channel, nil))
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -155,6 +192,14 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	originChannel, err := model.GetChannelById(channel.Id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	err = channel.Update()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -163,6 +208,7 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	recordAuditLog(c, model.AuditActionChannelUpdate, strconv.Itoa(channel.Id), model.ChannelAuditDiff(originChannel, &channel))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+func TestRedactChannels(t *testing.T) {
+	Convey("redact the secrets of the channels", t, func() {
+		modeltest.SetupDB(t)
+		reader := &model.CustomRole{Name: "reader", Permissions: model.PermissionChannelRead}
+		So(reader.Insert(), ShouldBeNil)
+		auditor := createTestUser(t, "auditor", model.RoleCommonUser)
+		So(model.SetUserCustomRole(auditor.Id, "reader"), ShouldBeNil)
+		admin := createTestUser(t, "admin", model.RoleAdminUser)
+		redact := func(user *model.User) *model.Channel {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/channel/", nil)
+			c.Set(ctxkey.Id, user.Id)
+			c.Set(ctxkey.Role, user.Role)
+			channel := &model.Channel{Key: "sk-secret", Config: `{"region":"us-east-1","ak":"access","sk":"secret"}`}
+			redactChannels(c, channel)
+			return channel
+		}
+
+		Convey("the readers don't see the key and the credentials", func() {
+			channel := redact(auditor)
+			So(channel.Key, ShouldBeEmpty)
+			So(channel.Config, ShouldNotContainSubstring, "access")
+			So(channel.Config, ShouldNotContainSubstring, "secret")
+			So(channel.Config, ShouldContainSubstring, "us-east-1")
+		})
+		Convey("the writers see them", func() {
+			channel := redact(admin)
+			So(channel.Key, ShouldEqual, "sk-secret")
+			So(channel.Config, ShouldContainSubstring, "secret")
+		})
+	})
+}
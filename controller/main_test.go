@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+type testResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// callHandler runs the handler as the user, body is sent as json if not nil
+func callHandler(handler gin.HandlerFunc, user *model.User, method string, body any, params ...gin.Param) testResponse {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	var jsonBytes []byte
+	if body != nil {
+		jsonBytes, _ = json.Marshal(body)
+	}
+	c.Request = httptest.NewRequest(method, "/", bytes.NewReader(jsonBytes))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	if user != nil {
+		c.Set(ctxkey.Id, user.Id)
+		c.Set(ctxkey.Role, user.Role)
+		c.Set(ctxkey.Username, user.Username)
+	}
+	handler(c)
+	response := testResponse{}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &response) != nil {
+		response.Message = w.Body.String()
+	}
+	return response
+}
+
+// createTestUser creates a user with unique username, aff code and access token
+func createTestUser(t *testing.T, username string, role int) *model.User {
+	user := &model.User{Username: username, Role: role, Status: model.UserStatusEnabled, AffCode: username, AccessToken: username}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
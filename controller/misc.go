@@ -39,11 +39,13 @@ func GetStatus(c *gin.Context) {
 			"quota_per_unit":              config.QuotaPerUnit,
 			"display_in_currency":         config.DisplayInCurrencyEnabled,
 			"oidc":                        config.OidcEnabled,
+			"ldap":                        config.LdapEnabled,
 			"oidc_client_id":              config.OidcClientId,
 			"oidc_well_known":             config.OidcWellKnown,
 			"oidc_authorization_endpoint": config.OidcAuthorizationEndpoint,
 			"oidc_token_endpoint":         config.OidcTokenEndpoint,
 			"oidc_userinfo_endpoint":      config.OidcUserinfoEndpoint,
+			"register_mode":               config.RegisterMode,
 		},
 	})
 	return
@@ -11,6 +11,7 @@ import (
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/modelinfo"
 	"net/http"
 	"strings"
 )
@@ -40,6 +41,15 @@ type OpenAIModels struct {
 	Permission []OpenAIModelPermission `json:"permission"`
 	Root       string                  `json:"root"`
 	Parent     *string                 `json:"parent"`
+	*modelinfo.ModelInfo
+}
+
+// withModelInfo attaches the capabilities of the model, it returns a copy so the cached entries are kept intact
+func withModelInfo(openAIModel OpenAIModels) OpenAIModels {
+	if info, ok := modelinfo.GetModelInfo(openAIModel.Id); ok {
+		openAIModel.ModelInfo = &info
+	}
+	return openAIModel
 }
 
 var models []OpenAIModels
@@ -147,19 +157,19 @@ func ListModels(c *gin.Context) {
 	for _, model := range models {
 		if _, ok := modelSet[model.Id]; ok {
 			modelSet[model.Id] = false
-			availableOpenAIModels = append(availableOpenAIModels, model)
+			availableOpenAIModels = append(availableOpenAIModels, withModelInfo(model))
 		}
 	}
 	for modelName, ok := range modelSet {
 		if ok {
-			availableOpenAIModels = append(availableOpenAIModels, OpenAIModels{
+			availableOpenAIModels = append(availableOpenAIModels, withModelInfo(OpenAIModels{
 				Id:      modelName,
 				Object:  "model",
 				Created: 1626777600,
 				OwnedBy: "custom",
 				Root:    modelName,
 				Parent:  nil,
-			})
+			}))
 		}
 	}
 	c.JSON(200, gin.H{
@@ -171,7 +181,7 @@ func ListModels(c *gin.Context) {
 func RetrieveModel(c *gin.Context) {
 	modelId := c.Param("model")
 	if model, ok := modelsMap[modelId]; ok {
-		c.JSON(200, model)
+		c.JSON(200, withModelInfo(model))
 	} else {
 		Error := relaymodel.Error{
 			Message: fmt.Sprintf("The model '%s' does not exist", modelId),
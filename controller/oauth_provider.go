@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetAllOAuthProviders(c *gin.Context) {
+	providers, err := model.GetAllOAuthProviders()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    providers,
+	})
+	return
+}
+
+func GetOAuthProvider(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	provider, err := model.GetOAuthProviderById(id, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    provider,
+	})
+	return
+}
+
+func AddOAuthProvider(c *gin.Context) {
+	provider := model.OAuthProvider{}
+	err := c.ShouldBindJSON(&provider)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if provider.UserIdClaim == "" {
+		provider.UserIdClaim = "sub"
+	}
+	err = provider.Validate()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if provider.DisplayName == "" {
+		provider.DisplayName = provider.Name
+	}
+	cleanProvider := model.OAuthProvider{
+		Name:                  provider.Name,
+		DisplayName:           provider.DisplayName,
+		ClientId:              provider.ClientId,
+		ClientSecret:          provider.ClientSecret,
+		AuthorizationEndpoint: provider.AuthorizationEndpoint,
+		TokenEndpoint:         provider.TokenEndpoint,
+		UserinfoEndpoint:      provider.UserinfoEndpoint,
+		Scopes:                provider.Scopes,
+		UserIdClaim:           provider.UserIdClaim,
+		UsernameClaim:         provider.UsernameClaim,
+		EmailClaim:            provider.EmailClaim,
+		DisplayNameClaim:      provider.DisplayNameClaim,
+		GroupClaim:            provider.GroupClaim,
+		GroupMapping:          provider.GroupMapping,
+		RoleMapping:           provider.RoleMapping,
+		RequiredGroup:         provider.RequiredGroup,
+		Status:                model.OAuthProviderStatusEnabled,
+	}
+	err = cleanProvider.Insert()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanProvider.ClientSecret = ""
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanProvider,
+	})
+	return
+}
+
+func UpdateOAuthProvider(c *gin.Context) {
+	provider := model.OAuthProvider{}
+	err := c.ShouldBindJSON(&provider)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanProvider, err := model.GetOAuthProviderById(provider.Id, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if provider.Status != model.OAuthProviderStatusEnabled && provider.Status != model.OAuthProviderStatusDisabled {
+		provider.Status = cleanProvider.Status
+	}
+	// the name is referenced by the identities, so it can't be changed
+	// If you add more fields, please also update provider.Update()
+	cleanProvider.DisplayName = provider.DisplayName
+	cleanProvider.ClientId = provider.ClientId
+	cleanProvider.ClientSecret = provider.ClientSecret
+	cleanProvider.AuthorizationEndpoint = provider.AuthorizationEndpoint
+	cleanProvider.TokenEndpoint = provider.TokenEndpoint
+	cleanProvider.UserinfoEndpoint = provider.UserinfoEndpoint
+	cleanProvider.Scopes = provider.Scopes
+	cleanProvider.UserIdClaim = provider.UserIdClaim
+	cleanProvider.UsernameClaim = provider.UsernameClaim
+	cleanProvider.EmailClaim = provider.EmailClaim
+	cleanProvider.DisplayNameClaim = provider.DisplayNameClaim
+	cleanProvider.GroupClaim = provider.GroupClaim
+	cleanProvider.GroupMapping = provider.GroupMapping
+	cleanProvider.RoleMapping = provider.RoleMapping
+	cleanProvider.RequiredGroup = provider.RequiredGroup
+	cleanProvider.Status = provider.Status
+	err = cleanProvider.Validate()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	err = cleanProvider.Update()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanProvider.ClientSecret = ""
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanProvider,
+	})
+	return
+}
+
+func DeleteOAuthProvider(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	err := model.DeleteOAuthProviderById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+func GetSelfIdentities(c *gin.Context) {
+	identities, err := model.GetUserIdentities(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    identities,
+	})
+	return
+}
+
+func DeleteSelfIdentity(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌解绑账户",
+		})
+		return
+	}
+	id, _ := strconv.Atoi(c.Param("id"))
+	err := model.DeleteUserIdentity(c.GetInt(ctxkey.Id), id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
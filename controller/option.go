@@ -13,6 +13,11 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// isSecretOption also covers TurnstileSecretKey, whose value is only redacted in the audit logs
+func isSecretOption(key string) bool {
+	return strings.HasSuffix(key, "Token") || strings.Contains(key, "Secret")
+}
+
 func GetOptions(c *gin.Context) {
 	var options []*model.Option
 	config.OptionMapRWMutex.Lock()
@@ -45,6 +50,14 @@ func UpdateOption(c *gin.Context) {
 		return
 	}
 	switch option.Key {
+	case "RegisterMode":
+		if !config.ValidRegisterModes[option.Value] {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无效的注册模式",
+			})
+			return
+		}
 	case "Theme":
 		if !config.ValidThemes[option.Value] {
 			c.JSON(http.StatusOK, gin.H{
@@ -61,6 +74,38 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "LdapEnabled":
+		if option.Value == "true" && (config.LdapServerURL == "" || config.LdapBaseDN == "") {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 LDAP 登录，请先填入 LDAP 服务器地址以及 Base DN！",
+			})
+			return
+		}
+	case "LdapUserFilter":
+		if strings.Count(option.Value, "%s") != 1 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "LDAP 用户过滤器必须包含且仅包含一个 %s",
+			})
+			return
+		}
+	case "LdapGroupMapping", "LdapRoleMapping":
+		if option.Value != "" && !json.Valid([]byte(option.Value)) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "LDAP 组映射必须是合法的 JSON",
+			})
+			return
+		}
+	case "OidcGroupMapping", "OidcRoleMapping":
+		if option.Value != "" && !json.Valid([]byte(option.Value)) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "OIDC 组映射必须是合法的 JSON",
+			})
+			return
+		}
 	case "EmailDomainRestrictionEnabled":
 		if option.Value == "true" && len(config.EmailDomainWhitelist) == 0 {
 			c.JSON(http.StatusOK, gin.H{
@@ -86,6 +131,9 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	config.OptionMapRWMutex.RLock()
+	originValue := config.OptionMap[option.Key]
+	config.OptionMapRWMutex.RUnlock()
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -94,6 +142,11 @@ func UpdateOption(c *gin.Context) {
 		})
 		return
 	}
+	var redacted []string
+	if isSecretOption(option.Key) {
+		redacted = append(redacted, "value")
+	}
+	recordAuditLog(c, model.AuditActionOptionUpdate, option.Key, model.AuditDiff(gin.H{"value": originValue}, gin.H{"value": option.Value}, redacted...))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
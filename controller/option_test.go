@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+func TestUpdateOptionAudit(t *testing.T) {
+	Convey("the audit log of an option update", t, func() {
+		modeltest.SetupDB(t)
+		root := createTestUser(t, "root", model.RoleRootUser)
+		optionMap, smtpToken, gitHubClientSecret, turnstileSecretKey := config.OptionMap, config.SMTPToken, config.GitHubClientSecret, config.TurnstileSecretKey
+		config.OptionMap = map[string]string{"Notice": "old notice", "SMTPToken": "old-smtp-token",
+			"GitHubClientSecret": "old-github-secret", "TurnstileSecretKey": "old-turnstile-secret"}
+		Reset(func() {
+			config.OptionMap, config.SMTPToken, config.GitHubClientSecret, config.TurnstileSecretKey = optionMap, smtpToken, gitHubClientSecret, turnstileSecretKey
+		})
+		update := func(key string, value string) *model.AuditLog {
+			response := callHandler(UpdateOption, root, http.MethodPut, model.Option{Key: key, Value: value})
+			So(response.Success, ShouldBeTrue)
+			auditLogs, err := model.GetAuditLogs(model.AuditActionOptionUpdate, "", key, 0, 0, 0, 1)
+			So(err, ShouldBeNil)
+			So(auditLogs, ShouldHaveLength, 1)
+			return auditLogs[0]
+		}
+
+		Convey("records the value of a plain option", func() {
+			auditLog := update("Notice", "new notice")
+			So(auditLog.Username, ShouldEqual, "root")
+			So(auditLog.Diff, ShouldEqual, `{"value":{"old":"old notice","new":"new notice"}}`)
+		})
+		Convey("redacts the value of a secret option", func() {
+			for _, key := range []string{"SMTPToken", "GitHubClientSecret", "TurnstileSecretKey"} {
+				So(isSecretOption(key), ShouldBeTrue)
+				auditLog := update(key, "new-"+key)
+				So(auditLog.Diff, ShouldEqual, `{"value":{"old":"[REDACTED]","new":"[REDACTED]"}}`)
+			}
+			So(config.GitHubClientSecret, ShouldEqual, "new-GitHubClientSecret")
+		})
+	})
+}
@@ -0,0 +1,678 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// getOrganizationRole returns the id of the organization in the path and the role of the current user in it,
+// the users who can manage the organizations of the site act as the owner of every organization
+func getOrganizationRole(c *gin.Context) (int, string, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, "", err
+	}
+	if model.HasPermission(c.GetInt(ctxkey.Id), c.GetInt(ctxkey.Role), model.PermissionOrganizationManage) {
+		if _, err = model.GetOrganizationById(id); err != nil {
+			return 0, "", errors.New("组织不存在")
+		}
+		return id, model.OrganizationRoleOwner, nil
+	}
+	member, err := model.GetOrganizationMember(id, c.GetInt(ctxkey.Id))
+	if err != nil {
+		return 0, "", errors.New("您不是该组织的成员")
+	}
+	return id, member.Role, nil
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	organizations, err := model.GetAllOrganizations(p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organizations,
+	})
+	return
+}
+
+func GetUserOrganizations(c *gin.Context) {
+	organizations, err := model.GetUserOrganizations(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organizations,
+	})
+	return
+}
+
+func GetOrganization(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	organization, err := model.GetOrganizationById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": model.UserOrganization{
+			Organization: *organization,
+			Role:         role,
+		},
+	})
+	return
+}
+
+func AddOrganization(c *gin.Context) {
+	organization := model.Organization{}
+	err := c.ShouldBindJSON(&organization)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(organization.Name) == 0 || len(organization.Name) > 30 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "组织名称长度必须在1-30之间",
+		})
+		return
+	}
+	cleanOrganization, err := model.CreateOrganization(organization.Name, c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanOrganization,
+	})
+	return
+}
+
+func UpdateOrganization(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if role != model.OrganizationRoleOwner {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "只有组织所有者可以修改组织",
+		})
+		return
+	}
+	organization := model.Organization{}
+	err = c.ShouldBindJSON(&organization)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(organization.Name) == 0 || len(organization.Name) > 30 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "组织名称长度必须在1-30之间",
+		})
+		return
+	}
+	if organization.Status != model.OrganizationStatusEnabled && organization.Status != model.OrganizationStatusDisabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的状态",
+		})
+		return
+	}
+	cleanOrganization, err := model.GetOrganizationById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// If you add more fields, please also update organization.Update()
+	cleanOrganization.Name = organization.Name
+	cleanOrganization.Status = organization.Status
+	err = cleanOrganization.Update()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanOrganization,
+	})
+	return
+}
+
+func DeleteOrganization(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if role != model.OrganizationRoleOwner {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "只有组织所有者可以删除组织",
+		})
+		return
+	}
+	err = model.DeleteOrganizationById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	id, _, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	members, err := model.GetOrganizationMembers(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    members,
+	})
+	return
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !model.IsOrganizationManager(role) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权管理组织成员",
+		})
+		return
+	}
+	member := model.OrganizationMember{}
+	err = c.ShouldBindJSON(&member)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanMember, err := model.GetOrganizationMember(id, member.UserId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "该用户不是组织成员",
+		})
+		return
+	}
+	if member.QuotaLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "成员额度上限不能为负数",
+		})
+		return
+	}
+	if member.Role != cleanMember.Role {
+		// there is always exactly one owner
+		if role != model.OrganizationRoleOwner || !model.IsValidOrganizationRole(member.Role) ||
+			member.Role == model.OrganizationRoleOwner || cleanMember.Role == model.OrganizationRoleOwner {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权修改该成员的角色",
+			})
+			return
+		}
+	}
+	if role != model.OrganizationRoleOwner && cleanMember.Role != model.OrganizationRoleMember {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "组织管理员只能管理普通成员",
+		})
+		return
+	}
+	// If you add more fields, please also update member.Update()
+	cleanMember.Role = member.Role
+	cleanMember.QuotaLimit = member.QuotaLimit
+	err = cleanMember.Update()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanMember,
+	})
+	return
+}
+
+// DeleteOrganizationMember removes a member, or lets the current user leave the organization
+func DeleteOrganizationMember(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	member, err := model.GetOrganizationMember(id, userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "该用户不是组织成员",
+		})
+		return
+	}
+	if member.Role == model.OrganizationRoleOwner {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法移除组织所有者",
+		})
+		return
+	}
+	self := userId == c.GetInt(ctxkey.Id)
+	if !self && (!model.IsOrganizationManager(role) || (role != model.OrganizationRoleOwner && member.Role != model.OrganizationRoleMember)) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权移除该成员",
+		})
+		return
+	}
+	err = model.DeleteOrganizationMember(id, userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+func GetOrganizationInvitations(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !model.IsOrganizationManager(role) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权管理组织成员",
+		})
+		return
+	}
+	invitations, err := model.GetOrganizationInvitations(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invitations,
+	})
+	return
+}
+
+func AddOrganizationInvitation(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !model.IsOrganizationManager(role) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权管理组织成员",
+		})
+		return
+	}
+	invitation := model.OrganizationInvitation{}
+	err = c.ShouldBindJSON(&invitation)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if invitation.Role == "" {
+		invitation.Role = model.OrganizationRoleMember
+	}
+	if invitation.Role != model.OrganizationRoleMember && invitation.Role != model.OrganizationRoleAdmin {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的角色",
+		})
+		return
+	}
+	if invitation.Role == model.OrganizationRoleAdmin && role != model.OrganizationRoleOwner {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "只有组织所有者可以邀请管理员",
+		})
+		return
+	}
+	if invitation.QuotaLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "成员额度上限不能为负数",
+		})
+		return
+	}
+	if invitation.ExpiredTime == 0 {
+		invitation.ExpiredTime = -1
+	}
+	if invitation.ExpiredTime != -1 && invitation.ExpiredTime < helper.GetTimestamp() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "过期时间不能早于当前时间",
+		})
+		return
+	}
+	cleanInvitation := model.OrganizationInvitation{
+		OrganizationId: id,
+		Role:           invitation.Role,
+		QuotaLimit:     invitation.QuotaLimit,
+		InviterId:      c.GetInt(ctxkey.Id),
+		ExpiredTime:    invitation.ExpiredTime,
+	}
+	err = cleanInvitation.Insert()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanInvitation,
+	})
+	return
+}
+
+func DeleteOrganizationInvitation(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !model.IsOrganizationManager(role) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权管理组织成员",
+		})
+		return
+	}
+	invitationId, _ := strconv.Atoi(c.Param("invitation_id"))
+	err = model.DeleteOrganizationInvitation(id, invitationId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+type joinOrganizationRequest struct {
+	Code string `json:"code"`
+}
+
+func JoinOrganization(c *gin.Context) {
+	req := joinOrganizationRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	member, err := model.AcceptOrganizationInvitation(req.Code, c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    member,
+	})
+	return
+}
+
+type organizationQuotaRequest struct {
+	Quota int64 `json:"quota"`
+}
+
+// ContributeOrganizationQuota moves quota of the current user into the pool of the organization
+func ContributeOrganizationQuota(c *gin.Context) {
+	id, _, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	req := organizationQuotaRequest{}
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	err = model.TransferQuotaToOrganization(userId, id, req.Quota)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx := c.Request.Context()
+	_ = model.CacheUpdateUserQuota(ctx, userId)
+	model.RecordLog(ctx, userId, model.LogTypeManage, fmt.Sprintf("向组织 #%d 转入额度 %s", id, common.LogQuota(req.Quota)))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// TopUpOrganization adds quota to the pool of the organization, only for the administrators of the site
+func TopUpOrganization(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	req := organizationQuotaRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	organization, err := model.GetOrganizationById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "组织不存在",
+		})
+		return
+	}
+	err = model.IncreaseOrganizationQuota(id, req.Quota)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.RecordLog(c.Request.Context(), c.GetInt(ctxkey.Id), model.LogTypeManage, fmt.Sprintf("管理员为组织 #%d 增加额度 %s", id, common.LogQuota(req.Quota)))
+	recordAuditLog(c, model.AuditActionOrganizationTopup, strconv.Itoa(id), model.AuditDiff(
+		gin.H{"quota": organization.Quota},
+		gin.H{"quota": organization.Quota + req.Quota},
+	))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// GetOrganizationLogs returns the consume logs of the organization, a member only sees the own logs
+func GetOrganizationLogs(c *gin.Context) {
+	id, role, err := getOrganizationRole(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	if !model.IsOrganizationManager(role) {
+		userId = c.GetInt(ctxkey.Id)
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	modelName := c.Query("model_name")
+	logs, err := model.GetOrganizationLogs(id, userId, startTimestamp, endTimestamp, modelName, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logs,
+	})
+	return
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+func organizationParam(id int) gin.Param {
+	return gin.Param{Key: "id", Value: strconv.Itoa(id)}
+}
+
+func TestOrganizationMembers(t *testing.T) {
+	Convey("manage the members of an organization", t, func() {
+		modeltest.SetupDB(t)
+		owner := createTestUser(t, "owner", model.RoleCommonUser)
+		admin := createTestUser(t, "admin", model.RoleCommonUser)
+		member := createTestUser(t, "member", model.RoleCommonUser)
+		outsider := createTestUser(t, "outsider", model.RoleCommonUser)
+		organization, err := model.CreateOrganization("acme", owner.Id)
+		So(err, ShouldBeNil)
+		So(model.DB.Create(&model.OrganizationMember{OrganizationId: organization.Id, UserId: admin.Id, Role: model.OrganizationRoleAdmin}).Error, ShouldBeNil)
+		So(model.DB.Create(&model.OrganizationMember{OrganizationId: organization.Id, UserId: member.Id, Role: model.OrganizationRoleMember}).Error, ShouldBeNil)
+		id := organizationParam(organization.Id)
+
+		Convey("an outsider can't see the organization", func() {
+			response := callHandler(GetOrganization, outsider, http.MethodGet, nil, id)
+			So(response.Success, ShouldBeFalse)
+		})
+		Convey("a site administrator acts as the owner", func() {
+			root := createTestUser(t, "root", model.RoleRootUser)
+			response := callHandler(GetOrganization, root, http.MethodGet, nil, id)
+			So(response.Success, ShouldBeTrue)
+			userOrganization := model.UserOrganization{}
+			So(json.Unmarshal(response.Data, &userOrganization), ShouldBeNil)
+			So(userOrganization.Role, ShouldEqual, model.OrganizationRoleOwner)
+		})
+		Convey("the owner promotes a member", func() {
+			response := callHandler(UpdateOrganizationMember, owner, http.MethodPut,
+				gin.H{"user_id": member.Id, "role": model.OrganizationRoleAdmin, "quota_limit": 100}, id)
+			So(response.Success, ShouldBeTrue)
+			updated, _ := model.GetOrganizationMember(organization.Id, member.Id)
+			So(updated.Role, ShouldEqual, model.OrganizationRoleAdmin)
+			So(updated.QuotaLimit, ShouldEqual, 100)
+		})
+		Convey("nobody becomes a second owner", func() {
+			response := callHandler(UpdateOrganizationMember, owner, http.MethodPut,
+				gin.H{"user_id": member.Id, "role": model.OrganizationRoleOwner}, id)
+			So(response.Success, ShouldBeFalse)
+		})
+		Convey("an admin caps members but can't change roles or manage admins", func() {
+			response := callHandler(UpdateOrganizationMember, admin, http.MethodPut,
+				gin.H{"user_id": member.Id, "role": model.OrganizationRoleMember, "quota_limit": 50}, id)
+			So(response.Success, ShouldBeTrue)
+			response = callHandler(UpdateOrganizationMember, admin, http.MethodPut,
+				gin.H{"user_id": member.Id, "role": model.OrganizationRoleAdmin}, id)
+			So(response.Success, ShouldBeFalse)
+			response = callHandler(UpdateOrganizationMember, admin, http.MethodPut,
+				gin.H{"user_id": owner.Id, "role": model.OrganizationRoleOwner, "quota_limit": 1}, id)
+			So(response.Success, ShouldBeFalse)
+		})
+		Convey("a member can't manage the members", func() {
+			response := callHandler(UpdateOrganizationMember, member, http.MethodPut,
+				gin.H{"user_id": member.Id, "role": model.OrganizationRoleMember, "quota_limit": 0}, id)
+			So(response.Success, ShouldBeFalse)
+			response = callHandler(DeleteOrganizationMember, member, http.MethodDelete, nil,
+				id, gin.Param{Key: "user_id", Value: strconv.Itoa(admin.Id)})
+			So(response.Success, ShouldBeFalse)
+		})
+		Convey("a member leaves, the owner can't be removed", func() {
+			response := callHandler(DeleteOrganizationMember, member, http.MethodDelete, nil,
+				id, gin.Param{Key: "user_id", Value: strconv.Itoa(member.Id)})
+			So(response.Success, ShouldBeTrue)
+			_, err := model.GetOrganizationMember(organization.Id, member.Id)
+			So(err, ShouldNotBeNil)
+			response = callHandler(DeleteOrganizationMember, owner, http.MethodDelete, nil,
+				id, gin.Param{Key: "user_id", Value: strconv.Itoa(owner.Id)})
+			So(response.Success, ShouldBeFalse)
+		})
+	})
+}
+
+func TestOrganizationInvitations(t *testing.T) {
+	Convey("invite users to an organization", t, func() {
+		modeltest.SetupDB(t)
+		owner := createTestUser(t, "owner", model.RoleCommonUser)
+		admin := createTestUser(t, "admin", model.RoleCommonUser)
+		invitee := createTestUser(t, "invitee", model.RoleCommonUser)
+		organization, err := model.CreateOrganization("acme", owner.Id)
+		So(err, ShouldBeNil)
+		So(model.DB.Create(&model.OrganizationMember{OrganizationId: organization.Id, UserId: admin.Id, Role: model.OrganizationRoleAdmin}).Error, ShouldBeNil)
+		id := organizationParam(organization.Id)
+
+		Convey("only the owner invites admins", func() {
+			response := callHandler(AddOrganizationInvitation, admin, http.MethodPost, gin.H{"role": model.OrganizationRoleAdmin}, id)
+			So(response.Success, ShouldBeFalse)
+			response = callHandler(AddOrganizationInvitation, owner, http.MethodPost, gin.H{"role": model.OrganizationRoleAdmin}, id)
+			So(response.Success, ShouldBeTrue)
+		})
+		Convey("an invitation is used once", func() {
+			response := callHandler(AddOrganizationInvitation, admin, http.MethodPost, gin.H{"quota_limit": 10}, id)
+			So(response.Success, ShouldBeTrue)
+			invitation := model.OrganizationInvitation{}
+			So(json.Unmarshal(response.Data, &invitation), ShouldBeNil)
+			So(invitation.Role, ShouldEqual, model.OrganizationRoleMember)
+
+			response = callHandler(JoinOrganization, invitee, http.MethodPost, gin.H{"code": invitation.Code})
+			So(response.Success, ShouldBeTrue)
+			member, err := model.GetOrganizationMember(organization.Id, invitee.Id)
+			So(err, ShouldBeNil)
+			So(member.QuotaLimit, ShouldEqual, 10)
+
+			other := createTestUser(t, "other", model.RoleCommonUser)
+			response = callHandler(JoinOrganization, other, http.MethodPost, gin.H{"code": invitation.Code})
+			So(response.Success, ShouldBeFalse)
+		})
+		Convey("an expired invitation is refused", func() {
+			response := callHandler(AddOrganizationInvitation, owner, http.MethodPost, gin.H{"expired_time": 1}, id)
+			So(response.Success, ShouldBeFalse)
+		})
+	})
+}
+
+func TestContributeOrganizationQuota(t *testing.T) {
+	Convey("contribute quota to an organization", t, func() {
+		modeltest.SetupDB(t)
+		owner := createTestUser(t, "owner", model.RoleCommonUser)
+		So(model.DB.Model(owner).Update("quota", 100).Error, ShouldBeNil)
+		organization, err := model.CreateOrganization("acme", owner.Id)
+		So(err, ShouldBeNil)
+		id := organizationParam(organization.Id)
+
+		Convey("the quota moves from the user to the pool", func() {
+			response := callHandler(ContributeOrganizationQuota, owner, http.MethodPost, gin.H{"quota": 60}, id)
+			So(response.Success, ShouldBeTrue)
+			quota, _ := model.GetOrganizationQuota(organization.Id)
+			So(quota, ShouldEqual, 60)
+			userQuota, _ := model.GetUserQuota(owner.Id)
+			So(userQuota, ShouldEqual, 40)
+		})
+		Convey("the user can't contribute more than the own quota", func() {
+			response := callHandler(ContributeOrganizationQuota, owner, http.MethodPost, gin.H{"quota": 101}, id)
+			So(response.Success, ShouldBeFalse)
+			quota, _ := model.GetOrganizationQuota(organization.Id)
+			So(quota, ShouldEqual, 0)
+		})
+		Convey("an outsider can't contribute", func() {
+			outsider := createTestUser(t, "outsider", model.RoleCommonUser)
+			response := callHandler(ContributeOrganizationQuota, outsider, http.MethodPost, gin.H{"quota": 1}, id)
+			So(response.Success, ShouldBeFalse)
+		})
+	})
+}
+
+func TestTopUpOrganization(t *testing.T) {
+	Convey("top up the pool of an organization", t, func() {
+		modeltest.SetupDB(t)
+		root := createTestUser(t, "root", model.RoleRootUser)
+		owner := createTestUser(t, "owner", model.RoleCommonUser)
+		organization, err := model.CreateOrganization("acme", owner.Id)
+		So(err, ShouldBeNil)
+		So(model.IncreaseOrganizationQuota(organization.Id, 40), ShouldBeNil)
+		id := organizationParam(organization.Id)
+
+		Convey("the quota is added and the top-up is audited", func() {
+			response := callHandler(TopUpOrganization, root, http.MethodPost, gin.H{"quota": 60}, id)
+			So(response.Success, ShouldBeTrue)
+			quota, _ := model.GetOrganizationQuota(organization.Id)
+			So(quota, ShouldEqual, 100)
+			auditLogs, err := model.GetAuditLogs(model.AuditActionOrganizationTopup, "", strconv.Itoa(organization.Id), 0, 0, 0, 1)
+			So(err, ShouldBeNil)
+			So(auditLogs, ShouldHaveLength, 1)
+			So(auditLogs[0].Username, ShouldEqual, "root")
+			So(auditLogs[0].Diff, ShouldEqual, `{"quota":{"old":40,"new":100}}`)
+		})
+		Convey("an unknown organization is refused", func() {
+			response := callHandler(TopUpOrganization, root, http.MethodPost, gin.H{"quota": 60}, organizationParam(404))
+			So(response.Success, ShouldBeFalse)
+			auditLogs, _ := model.GetAuditLogs(model.AuditActionOrganizationTopup, "", "", 0, 0, 0, 10)
+			So(auditLogs, ShouldBeEmpty)
+		})
+	})
+}
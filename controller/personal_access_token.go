@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// IsPersonalAccessTokenRequest tells whether the request is authenticated by a personal access token,
+// which may not mint credentials nor change the account, otherwise a leaked token could take over the account
+func IsPersonalAccessTokenRequest(c *gin.Context) bool {
+	_, ok := c.Get(ctxkey.PersonalAccessTokenId)
+	return ok
+}
+
+func GetSelfPersonalAccessTokens(c *gin.Context) {
+	pats, err := model.GetUserPersonalAccessTokens(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pats,
+	})
+	return
+}
+
+func AddPersonalAccessToken(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌创建访问令牌",
+		})
+		return
+	}
+	pat := model.PersonalAccessToken{}
+	err := c.ShouldBindJSON(&pat)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(pat.Name) == 0 || len(pat.Name) > 64 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "令牌名称长度必须在1-64之间",
+		})
+		return
+	}
+	if pat.ExpiredTime != -1 && pat.ExpiredTime <= helper.GetTimestamp() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "过期时间必须晚于当前时间，或为 -1 表示永不过期",
+		})
+		return
+	}
+	scopes, err := model.ValidateScopes(pat.Scopes)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	role := c.GetInt(ctxkey.Role)
+	cleanPat := model.PersonalAccessToken{
+		UserId:      userId,
+		Name:        pat.Name,
+		Scopes:      scopes,
+		ExpiredTime: pat.ExpiredTime,
+	}
+	for _, permission := range model.AllPermissions {
+		if cleanPat.HasScope(permission) && !model.HasPermission(userId, role, permission) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "您没有权限 " + permission + "，无法将其授予访问令牌",
+			})
+			return
+		}
+	}
+	key, err := cleanPat.Insert()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// the key is only shown once
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"id":           cleanPat.Id,
+			"name":         cleanPat.Name,
+			"scopes":       cleanPat.Scopes,
+			"expired_time": cleanPat.ExpiredTime,
+			"key":          key,
+		},
+	})
+	return
+}
+
+func DeletePersonalAccessToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	err := model.DeletePersonalAccessToken(c.GetInt(ctxkey.Id), id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+func TestPersonalAccessTokenRequest(t *testing.T) {
+	Convey("a request authenticated by a personal access token", t, func() {
+		modeltest.SetupDB(t)
+		user := createTestUser(t, "alice", model.RoleCommonUser)
+		So(model.DB.Model(user).Update("password", "hashed").Error, ShouldBeNil)
+		withPersonalAccessToken := func(handler gin.HandlerFunc) gin.HandlerFunc {
+			return func(c *gin.Context) {
+				c.Set(ctxkey.PersonalAccessTokenId, 1)
+				handler(c)
+			}
+		}
+
+		handlers := map[string]gin.HandlerFunc{
+			"UpdateSelf":              UpdateSelf,
+			"DeleteSelf":              DeleteSelf,
+			"GenerateAccessToken":     GenerateAccessToken,
+			"AddPersonalAccessToken":  AddPersonalAccessToken,
+			"DeleteOtherSelfSessions": DeleteOtherSelfSessions,
+			"DeleteSelfSession":       DeleteSelfSession,
+			"DeleteSelfIdentity":      DeleteSelfIdentity,
+			"SetupTwoFactor":          SetupTwoFactor,
+			"EnableTwoFactor":         EnableTwoFactor,
+			"DisableTwoFactor":        DisableTwoFactor,
+			"RegenerateRecoveryCodes": RegenerateRecoveryCodes,
+			"EmailBind":               EmailBind,
+		}
+		for name, handler := range handlers {
+			Convey("can't change the credentials nor the account by "+name, func() {
+				response := callHandler(withPersonalAccessToken(handler), user, http.MethodPost, gin.H{"password": "newpassword"})
+				So(response.Success, ShouldBeFalse)
+				So(response.Message, ShouldStartWith, "无法使用访问令牌")
+				stored, err := model.GetUserById(user.Id, true)
+				So(err, ShouldBeNil)
+				So(stored.Password, ShouldEqual, "hashed")
+				So(stored.Status, ShouldEqual, model.UserStatusEnabled)
+				So(stored.AccessToken, ShouldEqual, user.AccessToken)
+			})
+		}
+		Convey("can still read the own data", func() {
+			response := callHandler(withPersonalAccessToken(GetSelf), user, http.MethodGet, nil)
+			So(response.Success, ShouldBeTrue)
+		})
+	})
+}
@@ -127,6 +127,12 @@ func AddRedemption(c *gin.Context) {
 		}
 		keys = append(keys, key)
 	}
+	// the codes themselves are left out, they are as good as quota
+	recordAuditLog(c, model.AuditActionRedemptionAdd, redemption.Name, model.AuditDiff(nil, gin.H{
+		"name":  redemption.Name,
+		"quota": redemption.Quota,
+		"count": len(keys),
+	}))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+	"github.com/songquanpeng/one-api/model"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+// PrepareThirdPartyRegister applies the register mode to a user registering with a third party account,
+// which can't carry an invite, it responds and returns false if the user may not register
+func PrepareThirdPartyRegister(c *gin.Context, user *model.User) bool {
+	switch config.RegisterMode {
+	case config.RegisterModeInvite:
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员开启了邀请注册，请通过邀请链接注册",
+		})
+		return false
+	case config.RegisterModeApproval:
+		user.Status = model.UserStatusPending
+	}
+	return true
+}
+
+// NotifyPendingUser tells the root user that the user waits for approval, it does nothing for the other users
+func NotifyPendingUser(user *model.User) {
+	if user.Status != model.UserStatusPending {
+		return
+	}
+	by := message.ByEmail
+	if config.MessagePusherAddress != "" {
+		by = message.ByMessagePusher
+	} else if config.RootUserEmail == "" {
+		config.RootUserEmail = model.GetRootUserEmail()
+	}
+	subject := fmt.Sprintf("新用户 %s 等待审核", user.Username)
+	content := fmt.Sprintf("新用户 %s（ID：%d）已注册，请前往用户管理页面批准或删除该用户。", user.Username, user.Id)
+	go func() {
+		err := message.Notify(by, subject, content, content)
+		if err != nil {
+			logger.SysError("failed to notify the pending user: " + err.Error())
+		}
+	}()
+}
+
+func getRegistrationInviteLink(code string) string {
+	return fmt.Sprintf("%s/register?invite=%s", config.ServerAddress, code)
+}
+
+func GetPendingUsers(c *gin.Context) {
+	users, err := model.GetPendingUsers()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    users,
+	})
+	return
+}
+
+func GetAllRegistrationInvites(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	invites, err := model.GetAllRegistrationInvites(p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invites,
+	})
+	return
+}
+
+func AddRegistrationInvite(c *gin.Context) {
+	invite := model.RegistrationInvite{}
+	err := c.ShouldBindJSON(&invite)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if invite.Group == "" {
+		invite.Group = "default"
+	}
+	if _, ok := billingratio.GroupRatio[invite.Group]; !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "分组 " + invite.Group + " 不存在",
+		})
+		return
+	}
+	if len(invite.Remark) > 64 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "备注长度不能超过 64",
+		})
+		return
+	}
+	if invite.Quota < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "额度不能为负数",
+		})
+		return
+	}
+	if invite.ExpiredTime == 0 {
+		invite.ExpiredTime = -1
+	}
+	if invite.ExpiredTime != -1 && invite.ExpiredTime <= helper.GetTimestamp() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "过期时间必须晚于当前时间，或为 -1 表示永不过期",
+		})
+		return
+	}
+	cleanInvite := model.RegistrationInvite{
+		Remark:      invite.Remark,
+		Group:       invite.Group,
+		Quota:       invite.Quota,
+		CreatorId:   c.GetInt(ctxkey.Id),
+		ExpiredTime: invite.ExpiredTime,
+	}
+	err = cleanInvite.Insert()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// the code is as good as an account, so it is redacted
+	recordAuditLog(c, model.AuditActionRegistrationInviteAdd, strconv.Itoa(cleanInvite.Id), model.AuditDiff(nil, &cleanInvite, "code"))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"invite": cleanInvite,
+			"link":   getRegistrationInviteLink(cleanInvite.Code),
+		},
+	})
+	return
+}
+
+func DeleteRegistrationInvite(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	err := model.DeleteRegistrationInviteById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
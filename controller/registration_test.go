@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+func setRegisterMode(mode string) {
+	registerMode := config.RegisterMode
+	config.RegisterMode = mode
+	Reset(func() { config.RegisterMode = registerMode })
+}
+
+func register(username string, inviteCode string) testResponse {
+	return callHandler(Register, nil, http.MethodPost, gin.H{"username": username, "password": "password123", "invite_code": inviteCode})
+}
+
+func getUserByUsername(username string) *model.User {
+	user := &model.User{}
+	So(model.DB.Where("username = ?", username).First(user).Error, ShouldBeNil)
+	return user
+}
+
+func TestClaimRegistrationInvite(t *testing.T) {
+	Convey("claim a registration invite", t, func() {
+		modeltest.SetupDB(t)
+		invite := &model.RegistrationInvite{Group: "vip", Quota: 100}
+		So(invite.Insert(), ShouldBeNil)
+
+		Convey("an invite is claimed once", func() {
+			claimed, err := model.ClaimRegistrationInvite(invite.Code)
+			So(err, ShouldBeNil)
+			So(claimed.Id, ShouldEqual, invite.Id)
+			So(claimed.UsedTime, ShouldNotEqual, 0)
+			_, err = model.ClaimRegistrationInvite(invite.Code)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("a released invite can be claimed again", func() {
+			_, err := model.ClaimRegistrationInvite(invite.Code)
+			So(err, ShouldBeNil)
+			So(model.ReleaseRegistrationInvite(invite.Id), ShouldBeNil)
+			_, err = model.ClaimRegistrationInvite(invite.Code)
+			So(err, ShouldBeNil)
+		})
+		Convey("an invite which has a user is not released", func() {
+			claimed, err := model.ClaimRegistrationInvite(invite.Code)
+			So(err, ShouldBeNil)
+			So(claimed.SetUsedUser(1), ShouldBeNil)
+			So(model.ReleaseRegistrationInvite(invite.Id), ShouldBeNil)
+			_, err = model.ClaimRegistrationInvite(invite.Code)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("an empty, unknown or expired code is refused", func() {
+			_, err := model.ClaimRegistrationInvite("")
+			So(err, ShouldNotBeNil)
+			_, err = model.ClaimRegistrationInvite("unknown")
+			So(err, ShouldNotBeNil)
+			expired := &model.RegistrationInvite{ExpiredTime: 1}
+			So(expired.Insert(), ShouldBeNil)
+			_, err = model.ClaimRegistrationInvite(expired.Code)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRegisterWithInvite(t *testing.T) {
+	Convey("register with a registration invite", t, func() {
+		modeltest.SetupDB(t)
+		setRegisterMode(config.RegisterModeInvite)
+		invite := &model.RegistrationInvite{Group: "vip", Quota: 100}
+		So(invite.Insert(), ShouldBeNil)
+
+		Convey("the user gets the group and the quota of the invite", func() {
+			response := register("alice", invite.Code)
+			So(response.Success, ShouldBeTrue)
+			user := getUserByUsername("alice")
+			So(user.Group, ShouldEqual, "vip")
+			So(user.Status, ShouldEqual, model.UserStatusEnabled)
+			quota, _ := model.GetUserQuota(user.Id)
+			So(quota, ShouldEqual, config.QuotaForNewUser+100)
+			So(model.DB.First(invite, invite.Id).Error, ShouldBeNil)
+			So(invite.UsedUserId, ShouldEqual, user.Id)
+
+			response = register("bob", invite.Code)
+			So(response.Success, ShouldBeFalse)
+		})
+		Convey("the user can't register without an invite", func() {
+			response := register("alice", "")
+			So(response.Success, ShouldBeFalse)
+			So(model.IsUsernameAlreadyTaken("alice"), ShouldBeFalse)
+		})
+		Convey("the invite is released when the registration fails", func() {
+			createTestUser(t, "alice", model.RoleCommonUser)
+			response := register("alice", invite.Code)
+			So(response.Success, ShouldBeFalse)
+			response = register("bob", invite.Code)
+			So(response.Success, ShouldBeTrue)
+		})
+	})
+}
+
+func TestRegisterApproval(t *testing.T) {
+	Convey("register in the approval mode", t, func() {
+		modeltest.SetupDB(t)
+		setRegisterMode(config.RegisterModeApproval)
+		root := createTestUser(t, "root", model.RoleRootUser)
+
+		Convey("the new user is pending until approved", func() {
+			response := register("alice", "")
+			So(response.Success, ShouldBeTrue)
+			alice := getUserByUsername("alice")
+			So(alice.Status, ShouldEqual, model.UserStatusPending)
+			response = callHandler(Login, nil, http.MethodPost, LoginRequest{Username: "alice", Password: "password123"})
+			So(response.Success, ShouldBeFalse)
+			So(response.Message, ShouldContainSubstring, "审核")
+
+			response = callHandler(GetPendingUsers, root, http.MethodGet, nil)
+			So(response.Success, ShouldBeTrue)
+			var pending []*model.User
+			So(json.Unmarshal(response.Data, &pending), ShouldBeNil)
+			So(pending, ShouldHaveLength, 1)
+			So(pending[0].Id, ShouldEqual, alice.Id)
+
+			response = callHandler(ManageUser, root, http.MethodPost, ManageRequest{Username: "alice", Action: "approve"})
+			So(response.Success, ShouldBeTrue)
+			So(getUserByUsername("alice").Status, ShouldEqual, model.UserStatusEnabled)
+			response = callHandler(GetPendingUsers, root, http.MethodGet, nil)
+			So(json.Unmarshal(response.Data, &pending), ShouldBeNil)
+			So(pending, ShouldBeEmpty)
+		})
+		Convey("a user with an invite skips the approval", func() {
+			invite := &model.RegistrationInvite{}
+			So(invite.Insert(), ShouldBeNil)
+			response := register("alice", invite.Code)
+			So(response.Success, ShouldBeTrue)
+			So(getUserByUsername("alice").Status, ShouldEqual, model.UserStatusEnabled)
+		})
+		Convey("only a pending user is approved", func() {
+			user := createTestUser(t, "bob", model.RoleCommonUser)
+			So(model.DB.Model(user).Update("status", model.UserStatusDisabled).Error, ShouldBeNil)
+			response := callHandler(ManageUser, root, http.MethodPost, ManageRequest{Username: "bob", Action: "approve"})
+			So(response.Success, ShouldBeFalse)
+			So(getUserByUsername("bob").Status, ShouldEqual, model.UserStatusDisabled)
+		})
+	})
+}
@@ -0,0 +1,780 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+// the scim endpoints follow RFC 7643 and RFC 7644 instead of the usual success/message/data shape,
+// so that identity providers such as Okta and Entra ID can provision the users directly
+
+const (
+	scimUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	scimDefaultCount = 100
+)
+
+var scimEqFilterPattern = regexp.MustCompile(`^(\w+)\s+eq\s+"([^"]*)"$`)
+var scimMemberPathPattern = regexp.MustCompile(`^members\[value\s+eq\s+"([^"]*)"\]$`)
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimUserRequest struct {
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName"`
+	Name        scimName    `json:"name"`
+	Emails      []scimEmail `json:"emails"`
+	Active      *bool       `json:"active"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimGroupRequest struct {
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members"`
+}
+
+type scimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type scimPatchRequest struct {
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+func scimJSON(c *gin.Context, status int, obj any) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, obj)
+}
+
+func scimError(c *gin.Context, status int, scimType string, detail string) {
+	body := gin.H{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	scimJSON(c, status, body)
+}
+
+func scimLocation(resourceType string, id string) string {
+	return fmt.Sprintf("%s/scim/v2/%s/%s", config.ServerAddress, resourceType, id)
+}
+
+func scimListResponse(resources []gin.H, total int64, startIndex int) gin.H {
+	return gin.H{
+		"schemas":      []string{scimListResponseSchema},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	}
+}
+
+// getScimPagination reads the 1-based startIndex and the count of RFC 7644 section 3.4.2.4
+func getScimPagination(c *gin.Context) (startIndex int, count int) {
+	startIndex, _ = strconv.Atoi(c.Query("startIndex"))
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil || count > scimDefaultCount {
+		count = scimDefaultCount
+	}
+	if count < 0 {
+		count = 0
+	}
+	return startIndex, count
+}
+
+// parseScimFilter only supports the `attribute eq "value"` filters, which are what the identity providers send
+func parseScimFilter(filter string) (attribute string, value string, err error) {
+	matches := scimEqFilterPattern.FindStringSubmatch(strings.TrimSpace(filter))
+	if matches == nil {
+		return "", "", errors.New("only the eq filters are supported")
+	}
+	return strings.ToLower(matches[1]), matches[2], nil
+}
+
+func parseScimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	// Entra ID sends "True" and "False" as strings
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(s)
+}
+
+func scimUserResource(user *model.User, userName string) gin.H {
+	id := strconv.Itoa(user.Id)
+	resource := gin.H{
+		"schemas":     []string{scimUserSchema},
+		"id":          id,
+		"userName":    userName,
+		"displayName": user.DisplayName,
+		"name": scimName{
+			Formatted: user.DisplayName,
+		},
+		"active": user.Status == model.UserStatusEnabled,
+		"groups": []scimMember{{Value: user.Group, Display: user.Group}},
+		"meta": gin.H{
+			"resourceType": "User",
+			"location":     scimLocation("Users", id),
+		},
+	}
+	if user.Email != "" {
+		resource["emails"] = []scimEmail{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	return resource
+}
+
+func scimGroupResource(group string, users []*model.User) gin.H {
+	members := make([]scimMember, 0, len(users))
+	for _, user := range users {
+		members = append(members, scimMember{Value: strconv.Itoa(user.Id), Display: user.Username})
+	}
+	return gin.H{
+		"schemas":     []string{scimGroupSchema},
+		"id":          group,
+		"displayName": group,
+		"members":     members,
+		"meta": gin.H{
+			"resourceType": "Group",
+			"location":     scimLocation("Groups", group),
+		},
+	}
+}
+
+// getScimUser only returns the users provisioned through scim, the other accounts are not visible to the client
+func getScimUser(c *gin.Context) (*model.User, *model.UserIdentity, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return nil, nil, false
+	}
+	identity, err := model.GetScimIdentityByUserId(id)
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return nil, nil, false
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil || user.Status == model.UserStatusDeleted {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return nil, nil, false
+	}
+	return user, identity, true
+}
+
+func applyScimUser(user *model.User, req *scimUserRequest) {
+	displayName := req.DisplayName
+	if displayName == "" {
+		displayName = req.Name.Formatted
+	}
+	if displayName == "" {
+		displayName = strings.TrimSpace(req.Name.GivenName + " " + req.Name.FamilyName)
+	}
+	if displayName != "" {
+		user.DisplayName = displayName
+	}
+	for _, email := range req.Emails {
+		if email.Primary || user.Email == "" || len(req.Emails) == 1 {
+			user.Email = email.Value
+		}
+	}
+	if req.Active != nil {
+		if *req.Active {
+			user.Status = model.UserStatusEnabled
+		} else {
+			user.Status = model.UserStatusDisabled
+		}
+	}
+}
+
+// saveScimUser writes only the columns managed by the provider, it also turns off the tokens
+// of the deactivated users, they stay off after a reactivation
+func saveScimUser(user *model.User) error {
+	if user.Role == model.RoleRootUser && user.Status != model.UserStatusEnabled {
+		return errors.New("the root user can't be deactivated")
+	}
+	err := user.UpdateColumns("display_name", "email", "status")
+	if err != nil {
+		return err
+	}
+	if user.Status == model.UserStatusDisabled {
+		return model.DisableUserTokens(user.Id)
+	}
+	return nil
+}
+
+func ScimGetServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimDefaultCount},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "the ScimToken option",
+		}},
+	})
+}
+
+func ScimGetUsers(c *gin.Context) {
+	startIndex, count := getScimPagination(c)
+	var identities []*model.UserIdentity
+	var total int64
+	if filter := c.Query("filter"); filter != "" {
+		attribute, value, err := parseScimFilter(filter)
+		if err != nil || attribute != "username" {
+			scimError(c, http.StatusBadRequest, "invalidFilter", "only the userName eq filter is supported")
+			return
+		}
+		identity, err := model.GetScimIdentityByUserName(value)
+		if err == nil {
+			identities = append(identities, identity)
+			total = 1
+		}
+	} else {
+		var err error
+		identities, total, err = model.GetScimIdentities(startIndex-1, count)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+	}
+	resources := make([]gin.H, 0, len(identities))
+	for _, identity := range identities {
+		user, err := model.GetUserById(identity.UserId, false)
+		if err != nil {
+			continue
+		}
+		resources = append(resources, scimUserResource(user, identity.Subject))
+	}
+	scimJSON(c, http.StatusOK, scimListResponse(resources, total, startIndex))
+}
+
+func ScimGetUser(c *gin.Context) {
+	user, identity, ok := getScimUser(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, scimUserResource(user, identity.Subject))
+}
+
+// ScimCreateUser links the existing account with the same email, so that the users who signed up
+// through sso before the provisioning was set up are not duplicated, the administrators are never linked
+// as the provider could then deactivate them or take them over
+func ScimCreateUser(c *gin.Context) {
+	var req scimUserRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil || req.UserName == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+	if _, err := model.GetScimIdentityByUserName(req.UserName); err == nil {
+		scimError(c, http.StatusConflict, "uniqueness", "userName is already taken")
+		return
+	}
+	user := model.User{}
+	applyScimUser(&user, &req)
+	exists := false
+	if user.Email != "" && model.IsEmailAlreadyTaken(user.Email) {
+		existing := model.User{Email: user.Email}
+		err = existing.FillUserByEmail()
+		if err == nil {
+			if existing.Role >= model.RoleAdminUser {
+				scimError(c, http.StatusConflict, "uniqueness", "the email belongs to an administrator")
+				return
+			}
+			if _, err := model.GetScimIdentityByUserId(existing.Id); err == nil {
+				scimError(c, http.StatusConflict, "uniqueness", "the email belongs to another provisioned user")
+				return
+			}
+			applyScimUser(&existing, &req)
+			user = existing
+			exists = true
+		}
+	}
+	if exists {
+		err = saveScimUser(&user)
+	} else {
+		user.Username = req.UserName
+		if len(user.Username) > 12 || model.IsUsernameAlreadyTaken(user.Username) {
+			user.Username = "scim_" + strconv.Itoa(model.GetMaxUserId()+1)
+		}
+		if user.DisplayName == "" {
+			user.DisplayName = req.UserName
+		}
+		user.Role = model.RoleCommonUser
+		if user.Status == 0 {
+			user.Status = model.UserStatusEnabled
+		}
+		err = user.Insert(c.Request.Context(), 0)
+		if err == nil && user.Status == model.UserStatusDisabled {
+			err = saveScimUser(&user)
+		}
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	identity := model.UserIdentity{
+		UserId:   user.Id,
+		Provider: model.ScimIdentityProvider,
+		Subject:  req.UserName,
+	}
+	err = identity.Insert()
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	logger.SysLogf("scim provisioned user %d as %s", user.Id, req.UserName)
+	scimJSON(c, http.StatusCreated, scimUserResource(&user, identity.Subject))
+}
+
+func ScimReplaceUser(c *gin.Context) {
+	user, identity, ok := getScimUser(c)
+	if !ok {
+		return
+	}
+	var req scimUserRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	applyScimUser(user, &req)
+	err = updateScimUser(user, identity, req.UserName)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	scimJSON(c, http.StatusOK, scimUserResource(user, identity.Subject))
+}
+
+func ScimPatchUser(c *gin.Context) {
+	user, identity, ok := getScimUser(c)
+	if !ok {
+		return
+	}
+	var req scimPatchRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	userName := identity.Subject
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op == "remove" {
+			// none of the supported attributes can be cleared
+			continue
+		}
+		if op != "add" && op != "replace" {
+			scimError(c, http.StatusBadRequest, "invalidSyntax", "unsupported op: "+operation.Op)
+			return
+		}
+		attributes := map[string]json.RawMessage{}
+		if operation.Path == "" {
+			err = json.Unmarshal(operation.Value, &attributes)
+			if err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+				return
+			}
+		} else {
+			attributes[operation.Path] = operation.Value
+		}
+		for path, value := range attributes {
+			err = patchScimUserAttribute(user, &userName, path, value)
+			if err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+				return
+			}
+		}
+	}
+	err = updateScimUser(user, identity, userName)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	scimJSON(c, http.StatusOK, scimUserResource(user, identity.Subject))
+}
+
+func patchScimUserAttribute(user *model.User, userName *string, path string, value json.RawMessage) error {
+	lowerPath := strings.ToLower(path)
+	var s string
+	switch {
+	case lowerPath == "active":
+		active, err := parseScimBool(value)
+		if err != nil {
+			return errors.New("active must be a boolean")
+		}
+		applyScimUser(user, &scimUserRequest{Active: &active})
+	case lowerPath == "username":
+		if err := json.Unmarshal(value, &s); err != nil {
+			return errors.New("userName must be a string")
+		}
+		*userName = s
+	case lowerPath == "displayname", lowerPath == "name.formatted":
+		if err := json.Unmarshal(value, &s); err != nil {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		user.DisplayName = s
+	case lowerPath == "emails":
+		var emails []scimEmail
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return errors.New("emails must be a list")
+		}
+		user.Email = ""
+		applyScimUser(user, &scimUserRequest{Emails: emails})
+	case strings.HasPrefix(lowerPath, "emails["):
+		// e.g. emails[type eq "work"].value
+		if err := json.Unmarshal(value, &s); err != nil {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		user.Email = s
+	}
+	// the other attributes are not stored, they are ignored as RFC 7644 allows
+	return nil
+}
+
+func updateScimUser(user *model.User, identity *model.UserIdentity, userName string) error {
+	if userName != "" && userName != identity.Subject {
+		other, err := model.GetScimIdentityByUserName(userName)
+		if err == nil && other.Id != identity.Id {
+			return errors.New("userName is already taken")
+		}
+		err = identity.UpdateSubject(userName)
+		if err != nil {
+			return err
+		}
+	}
+	return saveScimUser(user)
+}
+
+func ScimDeleteUser(c *gin.Context) {
+	user, _, ok := getScimUser(c)
+	if !ok {
+		return
+	}
+	if user.Role == model.RoleRootUser {
+		scimError(c, http.StatusBadRequest, "mutability", "the root user can't be deleted")
+		return
+	}
+	err := model.DisableUserTokens(user.Id)
+	if err == nil {
+		err = user.Delete()
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	logger.SysLogf("scim deleted user %d", user.Id)
+	c.Status(http.StatusNoContent)
+}
+
+// the groups are the groups of the group ratio, a user belongs to exactly one of them,
+// so adding a member moves the user and removing it moves the user back to the default group
+
+func isScimGroup(group string) bool {
+	_, ok := billingratio.GroupRatio[group]
+	return ok
+}
+
+func getScimGroupNames() []string {
+	groups := make([]string, 0, len(billingratio.GroupRatio))
+	for group := range billingratio.GroupRatio {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func ScimGetGroups(c *gin.Context) {
+	startIndex, count := getScimPagination(c)
+	groups := getScimGroupNames()
+	if filter := c.Query("filter"); filter != "" {
+		attribute, value, err := parseScimFilter(filter)
+		if err != nil || attribute != "displayname" {
+			scimError(c, http.StatusBadRequest, "invalidFilter", "only the displayName eq filter is supported")
+			return
+		}
+		groups = nil
+		if isScimGroup(value) {
+			groups = []string{value}
+		}
+	}
+	total := int64(len(groups))
+	if startIndex-1 < len(groups) {
+		groups = groups[startIndex-1:]
+	} else {
+		groups = nil
+	}
+	if len(groups) > count {
+		groups = groups[:count]
+	}
+	excludeMembers := strings.Contains(c.Query("excludedAttributes"), "members")
+	resources := make([]gin.H, 0, len(groups))
+	for _, group := range groups {
+		var users []*model.User
+		if !excludeMembers {
+			var err error
+			users, err = model.GetScimUsersByGroup(group)
+			if err != nil {
+				scimError(c, http.StatusInternalServerError, "", err.Error())
+				return
+			}
+		}
+		resource := scimGroupResource(group, users)
+		if excludeMembers {
+			delete(resource, "members")
+		}
+		resources = append(resources, resource)
+	}
+	scimJSON(c, http.StatusOK, scimListResponse(resources, total, startIndex))
+}
+
+func ScimGetGroup(c *gin.Context) {
+	group := c.Param("id")
+	if !isScimGroup(group) {
+		scimError(c, http.StatusNotFound, "", "group not found")
+		return
+	}
+	users, err := model.GetScimUsersByGroup(group)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimJSON(c, http.StatusOK, scimGroupResource(group, users))
+}
+
+// ScimCreateGroup can't define a new group, as a group needs a ratio, it only adopts the configured ones
+func ScimCreateGroup(c *gin.Context) {
+	var req scimGroupRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil || req.DisplayName == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+	if !isScimGroup(req.DisplayName) {
+		scimError(c, http.StatusBadRequest, "invalidValue", "the group must be configured in the group ratio first")
+		return
+	}
+	err = setScimGroupMembers(req.DisplayName, req.Members, true)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	users, err := model.GetScimUsersByGroup(req.DisplayName)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimJSON(c, http.StatusCreated, scimGroupResource(req.DisplayName, users))
+}
+
+func ScimReplaceGroup(c *gin.Context) {
+	group := c.Param("id")
+	if !isScimGroup(group) {
+		scimError(c, http.StatusNotFound, "", "group not found")
+		return
+	}
+	var req scimGroupRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	err = setScimGroupMembers(group, req.Members, true)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	ScimGetGroup(c)
+}
+
+func ScimPatchGroup(c *gin.Context) {
+	group := c.Param("id")
+	if !isScimGroup(group) {
+		scimError(c, http.StatusNotFound, "", "group not found")
+		return
+	}
+	var req scimPatchRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		path := strings.TrimSpace(operation.Path)
+		var members []scimMember
+		if matches := scimMemberPathPattern.FindStringSubmatch(path); matches != nil {
+			// e.g. members[value eq "12"]
+			members = []scimMember{{Value: matches[1]}}
+			path = "members"
+		} else if strings.EqualFold(path, "members") && len(operation.Value) > 0 {
+			err = json.Unmarshal(operation.Value, &members)
+			if err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+				return
+			}
+		}
+		if !strings.EqualFold(path, "members") {
+			// the display name is the name of the group and can't be changed
+			continue
+		}
+		switch op {
+		case "add":
+			err = setScimGroupMembers(group, members, false)
+		case "replace":
+			err = setScimGroupMembers(group, members, true)
+		case "remove":
+			if len(members) == 0 {
+				err = setScimGroupMembers(group, nil, true)
+			} else {
+				err = removeScimGroupMembers(group, members)
+			}
+		default:
+			err = errors.New("unsupported op: " + operation.Op)
+		}
+		if err != nil {
+			scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+	ScimGetGroup(c)
+}
+
+// ScimDeleteGroup moves the members back to the default group, the group itself stays in the group ratio
+func ScimDeleteGroup(c *gin.Context) {
+	group := c.Param("id")
+	if !isScimGroup(group) {
+		scimError(c, http.StatusNotFound, "", "group not found")
+		return
+	}
+	err := setScimGroupMembers(group, nil, true)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func getScimMemberIds(members []scimMember) ([]int, error) {
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member: %s", member.Value)
+		}
+		if _, err := model.GetScimIdentityByUserId(id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("member %s is not a provisioned user", member.Value)
+			}
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// setScimGroupMembers adds the members to the group, and with replace, moves the other provisioned users out of it
+func setScimGroupMembers(group string, members []scimMember, replace bool) error {
+	ids, err := getScimMemberIds(members)
+	if err != nil {
+		return err
+	}
+	if replace {
+		keep := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			keep[id] = true
+		}
+		users, err := model.GetScimUsersByGroup(group)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if keep[user.Id] || group == "default" {
+				continue
+			}
+			err = model.SetUserGroup(user.Id, "default")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range ids {
+		err = model.SetUserGroup(id, group)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeScimGroupMembers(group string, members []scimMember) error {
+	ids, err := getScimMemberIds(members)
+	if err != nil {
+		return err
+	}
+	if group == "default" {
+		return nil
+	}
+	for _, id := range ids {
+		userGroup, err := model.GetUserGroup(id)
+		if err != nil {
+			return err
+		}
+		if userGroup != group {
+			continue
+		}
+		err = model.SetUserGroup(id, "default")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+// callScim runs the scim handler and decodes the scim resource it returns
+func callScim(handler gin.HandlerFunc, method string, target string, body any, params ...gin.Param) (int, map[string]any) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	var jsonBytes []byte
+	if body != nil {
+		jsonBytes, _ = json.Marshal(body)
+	}
+	c.Request = httptest.NewRequest(method, target, bytes.NewReader(jsonBytes))
+	c.Request.Header.Set("Content-Type", "application/scim+json")
+	c.Params = params
+	handler(c)
+	resource := make(map[string]any)
+	_ = json.Unmarshal(w.Body.Bytes(), &resource)
+	return c.Writer.Status(), resource
+}
+
+func scimIdParam(id any) gin.Param {
+	return gin.Param{Key: "id", Value: id.(string)}
+}
+
+func TestScimUsers(t *testing.T) {
+	Convey("provision users through scim", t, func() {
+		modeltest.SetupDB(t)
+		status, created := callScim(ScimCreateUser, http.MethodPost, "/scim/v2/Users", gin.H{
+			"userName":    "alice@example.com",
+			"displayName": "Alice",
+			"emails":      []gin.H{{"value": "alice@example.com", "primary": true}},
+		})
+		So(status, ShouldEqual, http.StatusCreated)
+		So(created["userName"], ShouldEqual, "alice@example.com")
+		So(created["active"], ShouldBeTrue)
+		id := scimIdParam(created["id"])
+
+		Convey("the userName is unique, case-insensitively", func() {
+			status, _ := callScim(ScimCreateUser, http.MethodPost, "/scim/v2/Users", gin.H{"userName": "Alice@Example.com"})
+			So(status, ShouldEqual, http.StatusConflict)
+		})
+		Convey("the user is found by the userName filter", func() {
+			status, list := callScim(ScimGetUsers, http.MethodGet, `/scim/v2/Users?filter=userName+eq+"alice@example.com"`, nil)
+			So(status, ShouldEqual, http.StatusOK)
+			So(list["totalResults"], ShouldEqual, 1)
+			status, _ = callScim(ScimGetUsers, http.MethodGet, `/scim/v2/Users?filter=displayName+eq+"Alice"`, nil)
+			So(status, ShouldEqual, http.StatusBadRequest)
+		})
+		Convey("a deactivation disables the user and the tokens", func() {
+			userId, _ := strconv.Atoi(id.Value)
+			token := &model.Token{UserId: userId, Key: "scimtokenkey", Name: "scim", Status: model.TokenStatusEnabled, ExpiredTime: -1}
+			So(token.Insert(), ShouldBeNil)
+			status, patched := callScim(ScimPatchUser, http.MethodPatch, "/scim/v2/Users/"+id.Value, gin.H{
+				"Operations": []gin.H{{"op": "Replace", "value": gin.H{"active": "False"}}},
+			}, id)
+			So(status, ShouldEqual, http.StatusOK)
+			So(patched["active"], ShouldBeFalse)
+			user, _ := model.GetUserById(userId, false)
+			So(user.Status, ShouldEqual, model.UserStatusDisabled)
+			token, _ = model.GetTokenById(token.Id)
+			So(token.Status, ShouldEqual, model.TokenStatusDisabled)
+		})
+		Convey("a reactivation enables the user again", func() {
+			userId, _ := strconv.Atoi(id.Value)
+			for _, active := range []bool{false, true} {
+				status, _ := callScim(ScimPatchUser, http.MethodPatch, "/scim/v2/Users/"+id.Value, gin.H{
+					"Operations": []gin.H{{"op": "replace", "path": "active", "value": active}},
+				}, id)
+				So(status, ShouldEqual, http.StatusOK)
+			}
+			user, _ := model.GetUserById(userId, false)
+			So(user.Status, ShouldEqual, model.UserStatusEnabled)
+		})
+		Convey("an update writes only the attributes of the provider", func() {
+			userId, _ := strconv.Atoi(id.Value)
+			// e.g. a top-up made while the provider had the user loaded
+			So(model.DB.Model(&model.User{}).Where("id = ?", userId).Update("quota", 500).Error, ShouldBeNil)
+			status, _ := callScim(ScimPatchUser, http.MethodPatch, "/scim/v2/Users/"+id.Value, gin.H{
+				"Operations": []gin.H{{"op": "replace", "path": "displayName", "value": "Alice Smith"}},
+			}, id)
+			So(status, ShouldEqual, http.StatusOK)
+			user, _ := model.GetUserById(userId, false)
+			So(user.DisplayName, ShouldEqual, "Alice Smith")
+			So(user.Quota, ShouldEqual, 500)
+		})
+		Convey("an existing account with the same email is linked", func() {
+			existing := createTestUser(t, "carol", model.RoleCommonUser)
+			So(model.DB.Model(existing).Update("email", "carol@example.com").Error, ShouldBeNil)
+			status, created := callScim(ScimCreateUser, http.MethodPost, "/scim/v2/Users", gin.H{
+				"userName": "carol@example.com",
+				"emails":   []gin.H{{"value": "carol@example.com"}},
+			})
+			So(status, ShouldEqual, http.StatusCreated)
+			So(created["id"], ShouldEqual, strconv.Itoa(existing.Id))
+		})
+		Convey("the account of an administrator is not linked", func() {
+			for _, role := range []int{model.RoleAdminUser, model.RoleRootUser} {
+				admin := createTestUser(t, "admin"+strconv.Itoa(role), role)
+				email := admin.Username + "@example.com"
+				So(model.DB.Model(admin).Update("email", email).Error, ShouldBeNil)
+				status, _ := callScim(ScimCreateUser, http.MethodPost, "/scim/v2/Users", gin.H{
+					"userName": email,
+					"emails":   []gin.H{{"value": email}},
+				})
+				So(status, ShouldEqual, http.StatusConflict)
+				_, err := model.GetScimIdentityByUserId(admin.Id)
+				So(err, ShouldNotBeNil)
+			}
+		})
+		Convey("the accounts which were not provisioned are not visible", func() {
+			other := createTestUser(t, "bob", model.RoleCommonUser)
+			status, _ := callScim(ScimGetUser, http.MethodGet, "/scim/v2/Users", nil, gin.Param{Key: "id", Value: strconv.Itoa(other.Id)})
+			So(status, ShouldEqual, http.StatusNotFound)
+		})
+		Convey("a deleted user is gone", func() {
+			status, _ := callScim(ScimDeleteUser, http.MethodDelete, "/scim/v2/Users/"+id.Value, nil, id)
+			So(status, ShouldEqual, http.StatusNoContent)
+			status, _ = callScim(ScimGetUser, http.MethodGet, "/scim/v2/Users/"+id.Value, nil, id)
+			So(status, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
+
+func TestScimGroups(t *testing.T) {
+	Convey("manage the group members through scim", t, func() {
+		modeltest.SetupDB(t)
+		provision := func(userName string) int {
+			status, created := callScim(ScimCreateUser, http.MethodPost, "/scim/v2/Users", gin.H{"userName": userName})
+			So(status, ShouldEqual, http.StatusCreated)
+			id, _ := strconv.Atoi(scimIdParam(created["id"]).Value)
+			return id
+		}
+		alice := provision("alice")
+		bob := provision("bob")
+		// an account which was not provisioned, e.g. the admin, in the same group
+		local := createTestUser(t, "local", model.RoleAdminUser)
+		So(model.SetUserGroup(local.Id, "vip"), ShouldBeNil)
+		vip := gin.Param{Key: "id", Value: "vip"}
+		group := func(userId int) string {
+			g, _ := model.GetUserGroup(userId)
+			return g
+		}
+		members := func(resource map[string]any) []string {
+			var values []string
+			list, _ := resource["members"].([]any)
+			for _, member := range list {
+				values = append(values, member.(map[string]any)["value"].(string))
+			}
+			return values
+		}
+
+		Convey("adding members moves the users and lists only the provisioned ones", func() {
+			status, resource := callScim(ScimPatchGroup, http.MethodPatch, "/scim/v2/Groups/vip", gin.H{
+				"Operations": []gin.H{{"op": "add", "path": "members", "value": []gin.H{{"value": strconv.Itoa(alice)}}}},
+			}, vip)
+			So(status, ShouldEqual, http.StatusOK)
+			So(members(resource), ShouldResemble, []string{strconv.Itoa(alice)})
+			So(group(alice), ShouldEqual, "vip")
+		})
+		Convey("replacing the members leaves the other accounts alone", func() {
+			So(model.SetUserGroup(alice, "vip"), ShouldBeNil)
+			status, resource := callScim(ScimReplaceGroup, http.MethodPut, "/scim/v2/Groups/vip", gin.H{
+				"displayName": "vip",
+				"members":     []gin.H{{"value": strconv.Itoa(bob)}},
+			}, vip)
+			So(status, ShouldEqual, http.StatusOK)
+			So(members(resource), ShouldResemble, []string{strconv.Itoa(bob)})
+			So(group(alice), ShouldEqual, "default")
+			So(group(bob), ShouldEqual, "vip")
+			So(group(local.Id), ShouldEqual, "vip")
+		})
+		Convey("deleting the group leaves the other accounts alone", func() {
+			So(model.SetUserGroup(alice, "vip"), ShouldBeNil)
+			status, _ := callScim(ScimDeleteGroup, http.MethodDelete, "/scim/v2/Groups/vip", nil, vip)
+			So(status, ShouldEqual, http.StatusNoContent)
+			So(group(alice), ShouldEqual, "default")
+			So(group(local.Id), ShouldEqual, "vip")
+		})
+		Convey("an account which was not provisioned can't be added", func() {
+			status, _ := callScim(ScimPatchGroup, http.MethodPatch, "/scim/v2/Groups/vip", gin.H{
+				"Operations": []gin.H{{"op": "add", "path": "members", "value": []gin.H{{"value": strconv.Itoa(local.Id)}}}},
+			}, vip)
+			So(status, ShouldEqual, http.StatusBadRequest)
+		})
+		Convey("removing a member moves it back to the default group", func() {
+			So(model.SetUserGroup(alice, "vip"), ShouldBeNil)
+			status, _ := callScim(ScimPatchGroup, http.MethodPatch, "/scim/v2/Groups/vip", gin.H{
+				"Operations": []gin.H{{"op": "remove", "path": `members[value eq "` + strconv.Itoa(alice) + `"]`}},
+			}, vip)
+			So(status, ShouldEqual, http.StatusOK)
+			So(group(alice), ShouldEqual, "default")
+		})
+		Convey("a group which is not configured is not found", func() {
+			status, _ := callScim(ScimGetGroup, http.MethodGet, "/scim/v2/Groups/unknown", nil, gin.Param{Key: "id", Value: "unknown"})
+			So(status, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// GetSessionUserId returns the user of the current dashboard session, or 0 if it is missing or revoked
+func GetSessionUserId(c *gin.Context) int {
+	sessionId, _ := sessions.Default(c).Get("session_id").(string)
+	userSession, err := model.ValidateUserSession(sessionId)
+	if err != nil {
+		return 0
+	}
+	return userSession.UserId
+}
+
+func getSessionsData(userSessions []*model.UserSession, currentSessionId string) []gin.H {
+	data := make([]gin.H, 0, len(userSessions))
+	for _, userSession := range userSessions {
+		data = append(data, gin.H{
+			"id":               userSession.Id,
+			"ip":               userSession.Ip,
+			"user_agent":       userSession.UserAgent,
+			"created_time":     userSession.CreatedTime,
+			"last_active_time": userSession.LastActiveTime,
+			"expired_time":     userSession.ExpiredTime,
+			"current":          currentSessionId != "" && userSession.SessionId == currentSessionId,
+		})
+	}
+	return data
+}
+
+func GetSelfSessions(c *gin.Context) {
+	userSessions, err := model.GetUserSessions(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	sessionId, _ := sessions.Default(c).Get("session_id").(string)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    getSessionsData(userSessions, sessionId),
+	})
+	return
+}
+
+func DeleteSelfSession(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌注销会话",
+		})
+		return
+	}
+	id, _ := strconv.Atoi(c.Param("id"))
+	err := model.RevokeUserSession(c.GetInt(ctxkey.Id), id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// DeleteOtherSelfSessions logs out everywhere else, the current session is kept
+func DeleteOtherSelfSessions(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌注销会话",
+		})
+		return
+	}
+	sessionId, _ := sessions.Default(c).Get("session_id").(string)
+	err := model.RevokeUserSessions(c.GetInt(ctxkey.Id), sessionId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+func GetUserSessions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	userSessions, err := model.GetUserSessions(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    getSessionsData(userSessions, ""),
+	})
+	return
+}
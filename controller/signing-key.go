@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetAllSigningKeys(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	keys, err := model.GetAllSigningKeys(userId, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    keys,
+	})
+	return
+}
+
+func GetSigningKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	key, err := model.GetSigningKeyById(id, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    key,
+	})
+	return
+}
+
+func AddSigningKey(c *gin.Context) {
+	key := model.SigningKey{}
+	err := c.ShouldBindJSON(&key)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(key.Name) == 0 || len(key.Name) > 30 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "密钥名称长度必须在1-30之间",
+		})
+		return
+	}
+	if !model.IsValidSigningAlgorithm(key.Algorithm) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "不支持的签名算法，可选值为 HS256、RS256 和 ES256",
+		})
+		return
+	}
+	if _, err = model.GetUserById(key.UserId, false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "用户不存在",
+		})
+		return
+	}
+	cleanKey := model.SigningKey{
+		UserId:      key.UserId,
+		Name:        key.Name,
+		Algorithm:   key.Algorithm,
+		CreatedTime: helper.GetTimestamp(),
+	}
+	if key.Algorithm == model.SigningAlgorithmHS256 {
+		cleanKey.Secret = key.Secret
+		if cleanKey.Secret == "" {
+			cleanKey.Secret = random.GetRandomString(48)
+		}
+		if len(cleanKey.Secret) < 32 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "HS256 密钥长度不能少于 32 位",
+			})
+			return
+		}
+	} else {
+		cleanKey.PublicKey = key.PublicKey
+		if err = cleanKey.ValidateKeyMaterial(); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "公钥格式错误：" + err.Error(),
+			})
+			return
+		}
+	}
+	secret := cleanKey.Secret
+	err = cleanKey.Insert()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// the plaintext secret is only returned here
+	cleanKey.Secret = secret
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanKey,
+	})
+	return
+}
+
+func UpdateSigningKey(c *gin.Context) {
+	key := model.SigningKey{}
+	err := c.ShouldBindJSON(&key)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanKey, err := model.GetSigningKeyById(key.Id, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if key.Status != model.SigningKeyStatusEnabled && key.Status != model.SigningKeyStatusDisabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的状态",
+		})
+		return
+	}
+	if len(key.Name) == 0 || len(key.Name) > 30 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "密钥名称长度必须在1-30之间",
+		})
+		return
+	}
+	// If you add more fields, please also update key.Update()
+	cleanKey.Name = key.Name
+	cleanKey.Status = key.Status
+	err = cleanKey.Update()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanKey,
+	})
+	return
+}
+
+func DeleteSigningKey(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	err := model.DeleteSigningKeyById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
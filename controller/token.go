@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/config"
@@ -9,8 +10,10 @@ import (
 	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/common/random"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 func GetAllTokens(c *gin.Context) {
@@ -117,6 +120,34 @@ func validateToken(c *gin.Context, token model.Token) error {
 			return fmt.Errorf("无效的网段：%s", err.Error())
 		}
 	}
+	if token.Endpoints != nil && *token.Endpoints != "" {
+		for _, endpoint := range strings.Split(*token.Endpoints, ",") {
+			if !relaymode.IsValidName(strings.TrimSpace(endpoint)) {
+				return fmt.Errorf("无效的端点：%s", endpoint)
+			}
+		}
+	}
+	if !model.IsValidBudgetPeriod(token.BudgetPeriod) {
+		return fmt.Errorf("无效的额度重置周期：%s", token.BudgetPeriod)
+	}
+	if token.PeriodQuota < 0 {
+		return fmt.Errorf("周期额度不能为负数")
+	}
+	if token.ModelQuotas != nil && *token.ModelQuotas != "" {
+		var modelQuotas map[string]int64
+		err := json.Unmarshal([]byte(*token.ModelQuotas), &modelQuotas)
+		if err != nil {
+			return fmt.Errorf("无效的模型额度：%s", err.Error())
+		}
+	}
+	if token.EndUserQuota < 0 {
+		return fmt.Errorf("终端用户额度不能为负数")
+	}
+	if token.OrganizationId != 0 {
+		if _, err := model.GetOrganizationMember(token.OrganizationId, c.GetInt(ctxkey.Id)); err != nil {
+			return fmt.Errorf("您不是该组织的成员")
+		}
+	}
 	return nil
 }
 
@@ -140,16 +171,27 @@ func AddToken(c *gin.Context) {
 	}
 
 	cleanToken := model.Token{
-		UserId:         c.GetInt(ctxkey.Id),
-		Name:           token.Name,
-		Key:            random.GenerateKey(),
-		CreatedTime:    helper.GetTimestamp(),
-		AccessedTime:   helper.GetTimestamp(),
-		ExpiredTime:    token.ExpiredTime,
-		RemainQuota:    token.RemainQuota,
-		UnlimitedQuota: token.UnlimitedQuota,
-		Models:         token.Models,
-		Subnet:         token.Subnet,
+		UserId:           c.GetInt(ctxkey.Id),
+		Name:             token.Name,
+		Key:              random.GenerateKey(),
+		CreatedTime:      helper.GetTimestamp(),
+		AccessedTime:     helper.GetTimestamp(),
+		ExpiredTime:      token.ExpiredTime,
+		RemainQuota:      token.RemainQuota,
+		UnlimitedQuota:   token.UnlimitedQuota,
+		Models:           token.Models,
+		Subnet:           token.Subnet,
+		Endpoints:        token.Endpoints,
+		ContextFit:       token.ContextFit,
+		RPMLimit:         token.RPMLimit,
+		TPMLimit:         token.TPMLimit,
+		ConcurrencyLimit: token.ConcurrencyLimit,
+		BudgetPeriod:     token.BudgetPeriod,
+		PeriodQuota:      token.PeriodQuota,
+		ModelQuotas:      token.ModelQuotas,
+		EndUserRPMLimit:  token.EndUserRPMLimit,
+		EndUserQuota:     token.EndUserQuota,
+		OrganizationId:   token.OrganizationId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -239,6 +281,17 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.Models = token.Models
 		cleanToken.Subnet = token.Subnet
+		cleanToken.Endpoints = token.Endpoints
+		cleanToken.ContextFit = token.ContextFit
+		cleanToken.RPMLimit = token.RPMLimit
+		cleanToken.TPMLimit = token.TPMLimit
+		cleanToken.ConcurrencyLimit = token.ConcurrencyLimit
+		cleanToken.BudgetPeriod = token.BudgetPeriod
+		cleanToken.PeriodQuota = token.PeriodQuota
+		cleanToken.ModelQuotas = token.ModelQuotas
+		cleanToken.EndUserRPMLimit = token.EndUserRPMLimit
+		cleanToken.EndUserQuota = token.EndUserQuota
+		cleanToken.OrganizationId = token.OrganizationId
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// pendingTwoFactorKey holds the id of the challenge of a login waiting for the second step
+const pendingTwoFactorKey = "pending_two_factor"
+
+type twoFactorRequest struct {
+	Code string `json:"code"`
+}
+
+func clearPendingTwoFactor(session sessions.Session) {
+	session.Delete(pendingTwoFactorKey)
+	_ = session.Save()
+}
+
+// VerifyTwoFactorLogin is the second step of the login for the users with two-factor authentication enabled
+func VerifyTwoFactorLogin(c *gin.Context) {
+	var req twoFactorRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil || req.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "未提供验证码",
+			"success": false,
+		})
+		return
+	}
+	session := sessions.Default(c)
+	challengeId, _ := session.Get(pendingTwoFactorKey).(string)
+	id, err := model.VerifyTwoFactorChallenge(challengeId, req.Code)
+	if err != nil {
+		if errors.Is(err, model.ErrTwoFactorChallengeExpired) {
+			clearPendingTwoFactor(session)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	clearPendingTwoFactor(session)
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	if user.Status != model.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	completeLogin(user, c)
+}
+
+func SetupTwoFactor(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌修改两步验证设置",
+		})
+		return
+	}
+	secret, uri, err := model.SetupTwoFactor(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"secret": secret,
+			"uri":    uri,
+		},
+	})
+	return
+}
+
+func EnableTwoFactor(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌修改两步验证设置",
+		})
+		return
+	}
+	var req twoFactorRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	codes, err := model.EnableTwoFactor(c.GetInt(ctxkey.Id), req.Code)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set("two_factor", true)
+	_ = session.Save()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+	return
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌修改两步验证设置",
+		})
+		return
+	}
+	var req twoFactorRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	err = model.VerifyTwoFactor(userId, req.Code)
+	if err == nil {
+		err = model.DisableTwoFactor(userId)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set("two_factor", false)
+	_ = session.Save()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌修改两步验证设置",
+		})
+		return
+	}
+	var req twoFactorRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	err = model.VerifyTwoFactor(userId, req.Code)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	codes, err := model.RegenerateRecoveryCodes(userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+	return
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/i18n"
+	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/random"
 	"github.com/songquanpeng/one-api/model"
 )
@@ -64,14 +65,56 @@ func Login(c *gin.Context) {
 	SetupLogin(&user, c)
 }
 
-// setup session & cookies and then return user info
+// setup session & cookies and then return user info,
+// the users with two-factor authentication enabled have to pass VerifyTwoFactorLogin first
 func SetupLogin(user *model.User, c *gin.Context) {
+	if user.TwoFactorEnabled {
+		challenge, err := model.CreateTwoFactorChallenge(user.Id)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": err.Error(),
+				"success": false,
+			})
+			return
+		}
+		session := sessions.Default(c)
+		session.Set(pendingTwoFactorKey, challenge.ChallengeId)
+		err = session.Save()
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "无法保存会话信息，请重试",
+				"success": false,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "",
+			"success": true,
+			"data": gin.H{
+				"require_two_factor": true,
+			},
+		})
+		return
+	}
+	completeLogin(user, c)
+}
+
+// setupSession starts a server-side session, the role and the status are read from the user at each request
+func setupSession(user *model.User, c *gin.Context) error {
+	userSession, err := model.CreateUserSession(user.Id, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return err
+	}
 	session := sessions.Default(c)
+	session.Set("session_id", userSession.SessionId)
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
-	session.Set("role", user.Role)
-	session.Set("status", user.Status)
-	err := session.Save()
+	session.Set("two_factor", user.TwoFactorEnabled)
+	return session.Save()
+}
+
+func completeLogin(user *model.User, c *gin.Context) {
+	err := setupSession(user, c)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "无法保存会话信息，请重试",
@@ -95,6 +138,16 @@ func SetupLogin(user *model.User, c *gin.Context) {
 
 func Logout(c *gin.Context) {
 	session := sessions.Default(c)
+	if sessionId, ok := session.Get("session_id").(string); ok {
+		err := model.RevokeUserSessionBySessionId(sessionId)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": err.Error(),
+				"success": false,
+			})
+			return
+		}
+	}
 	session.Clear()
 	err := session.Save()
 	if err != nil {
@@ -158,6 +211,18 @@ func Register(c *gin.Context) {
 			return
 		}
 	}
+	// an invite is also honored in the other modes, it puts the user in its group and skips the approval
+	var invite *model.RegistrationInvite
+	if config.RegisterMode == config.RegisterModeInvite || user.InviteCode != "" {
+		invite, err = model.ClaimRegistrationInvite(user.InviteCode)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	affCode := user.AffCode // this code is the inviter's code, not the user's own code
 	inviterId, _ := model.GetUserIdByAffCode(affCode)
 	cleanUser := model.User{
@@ -169,13 +234,38 @@ func Register(c *gin.Context) {
 	if config.EmailVerificationEnabled {
 		cleanUser.Email = user.Email
 	}
+	if invite != nil {
+		cleanUser.Group = invite.Group
+	} else if config.RegisterMode == config.RegisterModeApproval {
+		cleanUser.Status = model.UserStatusPending
+	}
 	if err := cleanUser.Insert(ctx, inviterId); err != nil {
+		if invite != nil {
+			_ = model.ReleaseRegistrationInvite(invite.Id)
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
 		})
 		return
 	}
+	if invite != nil {
+		if err := invite.SetUsedUser(cleanUser.Id); err != nil {
+			logger.Error(ctx, "failed to set the user of the registration invite: "+err.Error())
+		}
+		if invite.Quota > 0 {
+			_ = model.IncreaseUserQuota(cleanUser.Id, invite.Quota)
+			model.RecordLog(ctx, cleanUser.Id, model.LogTypeSystem, fmt.Sprintf("使用注册邀请赠送 %s", common.LogQuota(invite.Quota)))
+		}
+	}
+	if cleanUser.Status == model.UserStatusPending {
+		NotifyPendingUser(&cleanUser)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "注册成功，请等待管理员审核",
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -265,7 +355,7 @@ func GetUserDashboard(c *gin.Context) {
 	startOfDay := now.Truncate(24*time.Hour).AddDate(0, 0, -6).Unix()
 	endOfDay := now.Truncate(24 * time.Hour).Add(24*time.Hour - time.Second).Unix()
 
-	dashboards, err := model.SearchLogsByDayAndModel(id, int(startOfDay), int(endOfDay))
+	dashboards, err := model.SearchLogsByDayAndModel(id, int(startOfDay), int(endOfDay), c.Query("tag"))
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -283,6 +373,13 @@ func GetUserDashboard(c *gin.Context) {
 }
 
 func GenerateAccessToken(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌生成系统访问令牌",
+		})
+		return
+	}
 	id := c.GetInt(ctxkey.Id)
 	user, err := model.GetUserById(id, true)
 	if err != nil {
@@ -364,6 +461,15 @@ func GetSelf(c *gin.Context) {
 	return
 }
 
+func GetSelfPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetUserPermissions(c.GetInt(ctxkey.Id), c.GetInt(ctxkey.Role)),
+	})
+	return
+}
+
 func UpdateUser(c *gin.Context) {
 	ctx := c.Request.Context()
 	var updatedUser model.User
@@ -408,6 +514,22 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if updatedUser.CustomRole != originUser.CustomRole {
+		if !model.HasPermission(c.GetInt(ctxkey.Id), myRole, model.PermissionRoleManage) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权分配角色",
+			})
+			return
+		}
+		if err := model.SetUserCustomRole(updatedUser.Id, updatedUser.CustomRole); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
@@ -422,6 +544,13 @@ func UpdateUser(c *gin.Context) {
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(ctx, originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
 	}
+	if user, err := model.GetUserById(updatedUser.Id, false); err == nil {
+		diff := model.AuditDiff(originUser, user, userAuditRedacted...)
+		if updatePassword {
+			diff["password"] = &model.AuditChange{Old: model.AuditRedacted, New: model.AuditRedacted}
+		}
+		recordAuditLog(c, model.AuditActionUserUpdate, strconv.Itoa(user.Id), diff)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -430,6 +559,13 @@ func UpdateUser(c *gin.Context) {
 }
 
 func UpdateSelf(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌修改个人信息",
+		})
+		return
+	}
 	var user model.User
 	err := json.NewDecoder(c.Request.Body).Decode(&user)
 	if err != nil {
@@ -468,6 +604,19 @@ func UpdateSelf(c *gin.Context) {
 		})
 		return
 	}
+	session := sessions.Default(c)
+	if updatePassword && session.Get("session_id") != nil {
+		// the password change logged out all the sessions, keep the current one going
+		twoFactor, _ := session.Get("two_factor").(bool)
+		err := setupSession(&model.User{Id: cleanUser.Id, Username: cleanUser.Username, TwoFactorEnabled: twoFactor}, c)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -509,9 +658,17 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
+	recordAuditLog(c, model.AuditActionUserDelete, strconv.Itoa(id), model.AuditDiff(originUser, nil, userAuditRedacted...))
 }
 
 func DeleteSelf(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌注销账户",
+		})
+		return
+	}
 	id := c.GetInt("id")
 	user, _ := model.GetUserById(id, false)
 
@@ -580,6 +737,7 @@ func CreateUser(c *gin.Context) {
 		})
 		return
 	}
+	recordAuditLog(c, model.AuditActionUserCreate, strconv.Itoa(cleanUser.Id), model.AuditDiff(nil, &cleanUser, userAuditRedacted...))
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -625,6 +783,7 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	originUser := user
 	switch req.Action {
 	case "disable":
 		user.Status = model.UserStatusDisabled
@@ -637,6 +796,15 @@ func ManageUser(c *gin.Context) {
 		}
 	case "enable":
 		user.Status = model.UserStatusEnabled
+	case "approve":
+		if user.Status != model.UserStatusPending {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "该用户不在待审核状态",
+			})
+			return
+		}
+		user.Status = model.UserStatusEnabled
 	case "delete":
 		if user.Role == model.RoleRootUser {
 			c.JSON(http.StatusOK, gin.H{
@@ -684,6 +852,32 @@ func ManageUser(c *gin.Context) {
 			return
 		}
 		user.Role = model.RoleCommonUser
+	case "reset_2fa":
+		// for the users who lost both the authenticator and the recovery codes
+		if err := model.DisableTwoFactor(user.Id); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		user.TwoFactorEnabled = false
+		// the sessions remember that the second factor was passed, so they have to log in again
+		if err := model.RevokeUserSessions(user.Id, ""); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "logout":
+		if err := model.RevokeUserSessions(user.Id, ""); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 
 	if err := user.Update(false); err != nil {
@@ -693,6 +887,7 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	recordAuditLog(c, model.AuditActionUserManage(req.Action), strconv.Itoa(user.Id), model.AuditDiff(&originUser, &user, userAuditRedacted...))
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -706,6 +901,13 @@ func ManageUser(c *gin.Context) {
 }
 
 func EmailBind(c *gin.Context) {
+	if IsPersonalAccessTokenRequest(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法使用访问令牌绑定邮箱",
+		})
+		return
+	}
 	email := c.Query("email")
 	code := c.Query("code")
 	if !common.VerifyCodeWithKey(email, code, common.EmailVerificationPurpose) {
@@ -796,6 +998,32 @@ func AdminTopUp(c *gin.Context) {
 		})
 		return
 	}
+	user, err := model.GetUserById(req.UserId, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	myRole := c.GetInt(ctxkey.Role)
+	if myRole != model.RoleRootUser {
+		if user.Id == c.GetInt(ctxkey.Id) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法为自己充值",
+			})
+			return
+		}
+		if myRole <= user.Role {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权为同权限等级或更高权限等级的用户充值",
+			})
+			return
+		}
+	}
+	originQuota := user.Quota
 	err = model.IncreaseUserQuota(req.UserId, int64(req.Quota))
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -808,6 +1036,10 @@ func AdminTopUp(c *gin.Context) {
 		req.Remark = fmt.Sprintf("通过 API 充值 %s", common.LogQuota(int64(req.Quota)))
 	}
 	model.RecordTopupLog(ctx, req.UserId, req.Remark, req.Quota)
+	recordAuditLog(c, model.AuditActionUserTopup, strconv.Itoa(req.UserId), model.AuditDiff(
+		gin.H{"quota": originQuota},
+		gin.H{"quota": originQuota + int64(req.Quota), "remark": req.Remark},
+	))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
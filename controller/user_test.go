@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+func TestAdminTopUp(t *testing.T) {
+	Convey("top up the quota of a user", t, func() {
+		modeltest.SetupDB(t)
+		root := createTestUser(t, "root", model.RoleRootUser)
+		admin := createTestUser(t, "admin", model.RoleAdminUser)
+		otherAdmin := createTestUser(t, "other-admin", model.RoleAdminUser)
+		user := createTestUser(t, "user", model.RoleCommonUser)
+		quotaOf := func(user *model.User) int64 {
+			quota, err := model.GetUserQuota(user.Id)
+			So(err, ShouldBeNil)
+			return quota
+		}
+
+		Convey("an administrator tops up a common user", func() {
+			response := callHandler(AdminTopUp, admin, http.MethodPost, adminTopUpRequest{UserId: user.Id, Quota: 100})
+			So(response.Success, ShouldBeTrue)
+			So(quotaOf(user), ShouldEqual, 100)
+		})
+		Convey("an administrator can't top up another administrator", func() {
+			response := callHandler(AdminTopUp, admin, http.MethodPost, adminTopUpRequest{UserId: otherAdmin.Id, Quota: 100})
+			So(response.Success, ShouldBeFalse)
+			So(quotaOf(otherAdmin), ShouldEqual, 0)
+		})
+		Convey("an administrator can't top up themselves", func() {
+			response := callHandler(AdminTopUp, admin, http.MethodPost, adminTopUpRequest{UserId: admin.Id, Quota: 100})
+			So(response.Success, ShouldBeFalse)
+			So(quotaOf(admin), ShouldEqual, 0)
+		})
+		Convey("the root user tops up anyone", func() {
+			response := callHandler(AdminTopUp, root, http.MethodPost, adminTopUpRequest{UserId: admin.Id, Quota: 100})
+			So(response.Success, ShouldBeTrue)
+			response = callHandler(AdminTopUp, root, http.MethodPost, adminTopUpRequest{UserId: root.Id, Quota: 100})
+			So(response.Success, ShouldBeTrue)
+			So(quotaOf(admin), ShouldEqual, 100)
+			So(quotaOf(root), ShouldEqual, 100)
+		})
+		Convey("an unknown user can't be topped up", func() {
+			response := callHandler(AdminTopUp, root, http.MethodPost, adminTopUpRequest{UserId: 404, Quota: 100})
+			So(response.Success, ShouldBeFalse)
+		})
+	})
+}
+
+func TestManageUserResetTwoFactor(t *testing.T) {
+	Convey("reset the two-factor authentication of a user", t, func() {
+		modeltest.SetupDB(t)
+		root := createTestUser(t, "root", model.RoleRootUser)
+		user := createTestUser(t, "user", model.RoleCommonUser)
+		So(model.DB.Model(user).Updates(map[string]interface{}{"two_factor_enabled": true, "two_factor_secret": "secret"}).Error, ShouldBeNil)
+		_, err := model.CreateUserSession(user.Id, "127.0.0.1", "test")
+		So(err, ShouldBeNil)
+
+		response := callHandler(ManageUser, root, http.MethodPost, ManageRequest{Username: user.Username, Action: "reset_2fa"})
+		So(response.Success, ShouldBeTrue)
+		updatedUser, err := model.GetUserById(user.Id, true)
+		So(err, ShouldBeNil)
+		So(updatedUser.TwoFactorEnabled, ShouldBeFalse)
+		// the sessions which passed the second factor are revoked
+		sessions, err := model.GetUserSessions(user.Id)
+		So(err, ShouldBeNil)
+		So(sessions, ShouldBeEmpty)
+	})
+}
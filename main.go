@@ -12,8 +12,10 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 
 	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/blacklist"
 	"github.com/songquanpeng/one-api/common/client"
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/encryption"
 	"github.com/songquanpeng/one-api/common/i18n"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/controller"
@@ -38,11 +40,18 @@ func main() {
 		logger.SysLog("running in debug mode")
 	}
 
+	err := encryption.Init()
+	if err != nil {
+		logger.FatalLog("failed to load channel encryption key: " + err.Error())
+	}
+	if encryption.Enabled() {
+		logger.SysLog("channel encryption enabled")
+	}
+
 	// Initialize SQL Database
 	model.InitDB()
 	model.InitLogDB()
 
-	var err error
 	err = model.CreateRootAccountIfNeed()
 	if err != nil {
 		logger.FatalLog("database init error: " + err.Error())
@@ -54,11 +63,20 @@ func main() {
 		}
 	}()
 
+	if *common.ReencryptChannels {
+		_, err = model.ReencryptChannels()
+		if err != nil {
+			logger.FatalLog("failed to re-encrypt channels: " + err.Error())
+		}
+		return
+	}
+
 	// Initialize Redis
 	err = common.InitRedisClient()
 	if err != nil {
 		logger.FatalLog("failed to initialize Redis: " + err.Error())
 	}
+	blacklist.Init()
 
 	// Initialize options
 	model.InitOptionMap()
@@ -108,7 +126,12 @@ func main() {
 	server.Use(middleware.Language())
 	middleware.SetUpLogger(server)
 	// Initialize session store
+	// the cookie only holds the id of the server-side session and the state of the login flows
 	store := cookie.NewStore([]byte(config.SessionSecret))
+	store.Options(sessions.Options{
+		Path:   "/",
+		MaxAge: config.SessionExpirationSeconds,
+	})
 	server.Use(sessions.Sessions("session", store))
 
 	router.SetRouter(server, buildFS)
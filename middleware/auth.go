@@ -1,24 +1,52 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
+	"github.com/songquanpeng/one-api/relay/relaymode"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
-func authHelper(c *gin.Context, minRole int) {
+func authHelper(c *gin.Context, minRole int, permission string) {
 	session := sessions.Default(c)
 	username := session.Get("username")
-	role := session.Get("role")
-	id := session.Get("id")
-	status := session.Get("status")
-	if username == nil {
+	var role, id, status any
+	var pat *model.PersonalAccessToken
+	twoFactor, _ := session.Get("two_factor").(bool)
+	if username != nil {
+		// the cookie only identifies the server-side session, the cached user is dropped whenever it changes
+		sessionId, _ := session.Get("session_id").(string)
+		userSession, err := model.ValidateUserSession(sessionId)
+		var user *model.User
+		if err == nil {
+			user, err = model.CacheGetUserAuth(userSession.UserId)
+		}
+		if err != nil {
+			session.Clear()
+			_ = session.Save()
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "会话已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+		username = user.Username
+		role = user.Role
+		id = user.Id
+		status = user.Status
+	} else {
 		// Check access token
 		accessToken := c.Request.Header.Get("Authorization")
 		if accessToken == "" {
@@ -29,13 +57,28 @@ func authHelper(c *gin.Context, minRole int) {
 			c.Abort()
 			return
 		}
-		user := model.ValidateAccessToken(accessToken)
+		var user *model.User
+		if key := strings.TrimPrefix(accessToken, "Bearer "); model.IsPersonalAccessTokenKey(key) {
+			var err error
+			pat, user, err = model.ValidatePersonalAccessToken(key, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "无权进行此操作，" + err.Error(),
+				})
+				c.Abort()
+				return
+			}
+		} else {
+			user = model.ValidateAccessToken(accessToken)
+		}
 		if user != nil && user.Username != "" {
 			// Token is valid
 			username = user.Username
 			role = user.Role
 			id = user.Id
 			status = user.Status
+			twoFactor = user.TwoFactorEnabled
 		} else {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -64,6 +107,38 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	if config.AdminTwoFactorRequiredEnabled && !twoFactor && (minRole >= model.RoleAdminUser || permission != "") {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员需要先启用两步验证",
+		})
+		c.Abort()
+		return
+	}
+	if pat != nil {
+		// a personal access token never exceeds its scopes, the routes without a permission need the self scope
+		scope := permission
+		if scope == "" {
+			scope = model.PersonalAccessTokenScopeSelf
+		}
+		if !pat.HasScope(scope) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，访问令牌缺少权限范围 " + scope,
+			})
+			c.Abort()
+			return
+		}
+		c.Set(ctxkey.PersonalAccessTokenId, pat.Id)
+	}
+	if permission != "" && !model.HasPermission(id.(int), role.(int), permission) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权进行此操作，缺少权限 " + permission,
+		})
+		c.Abort()
+		return
+	}
 	c.Set("username", username)
 	c.Set("role", role)
 	c.Set("id", id)
@@ -72,19 +147,43 @@ func authHelper(c *gin.Context, minRole int) {
 
 func UserAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, model.RoleCommonUser)
+		authHelper(c, model.RoleCommonUser, "")
 	}
 }
 
 func AdminAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, model.RoleAdminUser)
+		authHelper(c, model.RoleAdminUser, "")
 	}
 }
 
 func RootAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, model.RoleRootUser)
+		authHelper(c, model.RoleRootUser, "")
+	}
+}
+
+// PermissionAuth lets in the users granted the permission, by either the role level or the custom role
+func PermissionAuth(permission string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, model.RoleCommonUser, permission)
+	}
+}
+
+// ScimAuth checks the bearer token of the scim client, the errors follow RFC 7644 section 3.12
+func ScimAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+		if config.ScimToken == "" || subtle.ConstantTimeCompare([]byte(key), []byte(config.ScimToken)) != 1 {
+			c.Header("Content-Type", "application/scim+json")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+				"status":  strconv.Itoa(http.StatusUnauthorized),
+				"detail":  "invalid scim token",
+			})
+			return
+		}
+		c.Next()
 	}
 }
 
@@ -93,10 +192,18 @@ func TokenAuth() func(c *gin.Context) {
 		ctx := c.Request.Context()
 		key := c.Request.Header.Get("Authorization")
 		key = strings.TrimPrefix(key, "Bearer ")
-		key = strings.TrimPrefix(key, "sk-")
-		parts := strings.Split(key, "-")
-		key = parts[0]
-		token, err := model.ValidateUserToken(key)
+		var token *model.Token
+		var claims *model.RelayTokenClaims
+		var parts []string
+		var err error
+		if model.IsRelayJWT(key) {
+			token, claims, err = model.ValidateRelayJWT(key)
+		} else {
+			key = strings.TrimPrefix(key, "sk-")
+			parts = strings.Split(key, "-")
+			key = parts[0]
+			token, err = model.ValidateUserToken(key)
+		}
 		if err != nil {
 			abortWithMessage(c, http.StatusUnauthorized, err.Error())
 			return
@@ -116,6 +223,13 @@ func TokenAuth() func(c *gin.Context) {
 			abortWithMessage(c, http.StatusForbidden, "用户已被封禁")
 			return
 		}
+		if token.Endpoints != nil && *token.Endpoints != "" {
+			endpoint := relaymode.GetName(relaymode.GetByPath(c.Request.URL.Path))
+			if endpoint != "" && !isEndpointInList(endpoint, *token.Endpoints) {
+				abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("该令牌无权访问端点：%s", endpoint))
+				return
+			}
+		}
 		requestModel, err := getRequestModel(c)
 		if err != nil && shouldCheckModel(c) {
 			abortWithMessage(c, http.StatusBadRequest, err.Error())
@@ -129,9 +243,72 @@ func TokenAuth() func(c *gin.Context) {
 				return
 			}
 		}
+		if claims != nil && len(claims.Models) > 0 {
+			models := getRelayJWTModels(claims, token)
+			if models == "" {
+				abortWithMessage(c, http.StatusForbidden, "该 JWT 无可用模型")
+				return
+			}
+			c.Set(ctxkey.AvailableModels, models)
+			if requestModel != "" && !isModelInList(requestModel, models) {
+				abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("该 JWT 无权使用模型：%s", requestModel))
+				return
+			}
+		}
+		err = model.CheckTokenBudget(token, requestModel, 0)
+		if err != nil {
+			abortWithMessage(c, http.StatusForbidden, err.Error())
+			return
+		}
+		endUser, tags := getRequestAttribution(c)
+		// the end user of a jwt is set by the backend that signed it, so the client can't override it
+		if claims != nil && claims.Subject != "" {
+			endUser = claims.Subject
+		}
+		if len(tags) > 0 {
+			ctx = helper.SetTags(ctx, tags)
+		}
+		if endUser != "" {
+			c.Set(ctxkey.EndUserId, endUser)
+			ctx = helper.SetEndUser(ctx, endUser)
+			if token.EndUserQuota > 0 {
+				ctx = ratelimit.WithQuotaCap(ctx, token.EndUserQuotaCap(endUser))
+			}
+		}
+		if token.OrganizationId != 0 {
+			err = model.CheckOrganizationQuota(token.OrganizationId, token.UserId, 0)
+			if err != nil {
+				abortWithMessage(c, http.StatusForbidden, err.Error())
+				return
+			}
+			c.Set(ctxkey.OrganizationId, token.OrganizationId)
+			ctx = helper.SetOrganizationId(ctx, token.OrganizationId)
+		}
+		if claims != nil && claims.Quota > 0 {
+			// the consumed quota of the request is counted against the cap of the jwt
+			ctx = ratelimit.WithQuotaCap(ctx, claims.QuotaCap())
+		}
+		c.Request = c.Request.WithContext(ctx)
+		err = ratelimit.CheckQuotaCaps(ctx, 0)
+		if err != nil {
+			abortWithMessage(c, http.StatusForbidden, err.Error())
+			return
+		}
 		c.Set(ctxkey.Id, token.UserId)
 		c.Set(ctxkey.TokenId, token.Id)
 		c.Set(ctxkey.TokenName, token.Name)
+		contextFit := token.ContextFit
+		// the request header takes precedence over the token setting
+		if header := c.GetHeader("X-Context-Fit"); header != "" {
+			contextFit, _ = strconv.ParseBool(header)
+		}
+		c.Set(ctxkey.ContextFit, contextFit)
+		c.Set(ctxkey.TokenRateLimit, ratelimit.Limit{
+			RPM:         token.RPMLimit,
+			TPM:         token.TPMLimit,
+			Concurrency: token.ConcurrencyLimit,
+		})
+		c.Set(ctxkey.EndUserRateLimit, ratelimit.Limit{RPM: token.EndUserRPMLimit})
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set(ctxkey.SpecificChannelId, parts[1])
@@ -0,0 +1,265 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/model/modeltest"
+)
+
+func TestTokenAuthEndpoints(t *testing.T) {
+	Convey("a token restricted to endpoints", t, func() {
+		modeltest.SetupDB(t)
+		gin.SetMode(gin.TestMode)
+		user := &model.User{Username: "endpoint", Status: model.UserStatusEnabled, AffCode: "endpoint"}
+		So(model.DB.Create(user).Error, ShouldBeNil)
+		endpoints := "chat,audio"
+		token := &model.Token{UserId: user.Id, Key: "endpointtokenkey", Name: "endpoint", Status: model.TokenStatusEnabled,
+			ExpiredTime: -1, UnlimitedQuota: true, Endpoints: &endpoints}
+		So(token.Insert(), ShouldBeNil)
+
+		router := gin.New()
+		router.Use(TokenAuth())
+		handler := func(c *gin.Context) { c.Status(http.StatusOK) }
+		router.POST("/v1/chat/completions", handler)
+		router.POST("/v1/embeddings", handler)
+		router.POST("/v1/images/generations", handler)
+		router.POST("/v1/audio/transcriptions", handler)
+		relay := func(path string, body string) int {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-"+token.Key)
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		So(relay("/v1/chat/completions", `{"model":"gpt-4o"}`), ShouldEqual, http.StatusOK)
+		So(relay("/v1/audio/transcriptions", `{"model":"whisper-1"}`), ShouldEqual, http.StatusOK)
+		So(relay("/v1/embeddings", `{"model":"text-embedding-3-small"}`), ShouldEqual, http.StatusForbidden)
+		So(relay("/v1/images/generations", `{"model":"dall-e-3"}`), ShouldEqual, http.StatusForbidden)
+	})
+}
+
+func TestPermissionAuth(t *testing.T) {
+	Convey("routes guarded by a permission", t, func() {
+		modeltest.SetupDB(t)
+		gin.SetMode(gin.TestMode)
+		createUser := func(username string, role int, customRole string) *model.User {
+			user := &model.User{Username: username, Role: role, Status: model.UserStatusEnabled, AffCode: username,
+				AccessToken: username + "-access-token", CustomRole: customRole}
+			So(model.DB.Create(user).Error, ShouldBeNil)
+			return user
+		}
+		reader := &model.CustomRole{Name: "reader", Permissions: model.PermissionChannelRead + "," + model.PermissionLogRead}
+		So(reader.Insert(), ShouldBeNil)
+		member := createUser("member", model.RoleCommonUser, "")
+		admin := createUser("admin", model.RoleAdminUser, "")
+		root := createUser("root", model.RoleRootUser, "")
+		auditor := createUser("auditor", model.RoleCommonUser, "reader")
+
+		router := gin.New()
+		router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+		handler := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"success": true}) }
+		router.GET("/channel", PermissionAuth(model.PermissionChannelRead), handler)
+		router.GET("/channel/write", PermissionAuth(model.PermissionChannelWrite), handler)
+		router.GET("/option", PermissionAuth(model.PermissionOptionManage), handler)
+		allowed := func(user *model.User, path string) bool {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", user.AccessToken)
+			router.ServeHTTP(w, req)
+			return strings.Contains(w.Body.String(), `"success":true`)
+		}
+
+		Convey("by the role level", func() {
+			So(allowed(member, "/channel"), ShouldBeFalse)
+			So(allowed(admin, "/channel"), ShouldBeTrue)
+			So(allowed(admin, "/channel/write"), ShouldBeTrue)
+			So(allowed(admin, "/option"), ShouldBeFalse)
+			So(allowed(root, "/option"), ShouldBeTrue)
+		})
+		Convey("by the custom role", func() {
+			So(allowed(auditor, "/channel"), ShouldBeTrue)
+			So(allowed(auditor, "/channel/write"), ShouldBeFalse)
+			So(allowed(auditor, "/option"), ShouldBeFalse)
+		})
+		Convey("a change of the custom role applies at once", func() {
+			reader.Permissions = model.PermissionLogRead
+			So(reader.Update(), ShouldBeNil)
+			So(allowed(auditor, "/channel"), ShouldBeFalse)
+			So(model.SetUserCustomRole(member.Id, "reader"), ShouldBeNil)
+			So(allowed(member, "/channel"), ShouldBeFalse)
+			reader.Permissions = model.PermissionChannelRead
+			So(reader.Update(), ShouldBeNil)
+			So(allowed(member, "/channel"), ShouldBeTrue)
+			So(model.DeleteCustomRoleById(reader.Id), ShouldBeNil)
+			So(allowed(member, "/channel"), ShouldBeFalse)
+		})
+	})
+}
+
+func TestUserAuthSession(t *testing.T) {
+	Convey("a dashboard session", t, func() {
+		modeltest.SetupDB(t)
+		gin.SetMode(gin.TestMode)
+		user := &model.User{Username: "alice", Password: "12345678", Role: model.RoleAdminUser, Status: model.UserStatusEnabled,
+			AffCode: "alice", AccessToken: "alice-access-token"}
+		So(model.DB.Create(user).Error, ShouldBeNil)
+
+		router := gin.New()
+		router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+		router.GET("/login", func(c *gin.Context) {
+			userSession, err := model.CreateUserSession(user.Id, c.ClientIP(), c.Request.UserAgent())
+			if err != nil {
+				c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
+				return
+			}
+			session := sessions.Default(c)
+			session.Set("username", user.Username)
+			session.Set("session_id", userSession.SessionId)
+			_ = session.Save()
+			c.JSON(http.StatusOK, gin.H{"success": true})
+		})
+		handler := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"success": true}) }
+		router.GET("/self", UserAuth(), handler)
+		router.GET("/admin", AdminAuth(), handler)
+		var cookies []*http.Cookie
+		get := func(path string) (int, string) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			for _, c := range cookies {
+				req.AddCookie(c)
+			}
+			router.ServeHTTP(w, req)
+			if setCookies := w.Result().Cookies(); len(setCookies) > 0 {
+				cookies = setCookies
+			}
+			return w.Code, w.Body.String()
+		}
+		_, body := get("/login")
+		So(body, ShouldContainSubstring, `"success":true`)
+		_, body = get("/admin")
+		So(body, ShouldContainSubstring, `"success":true`)
+
+		Convey("is revoked when the user is disabled", func() {
+			user.Status = model.UserStatusDisabled
+			So(user.Update(false), ShouldBeNil)
+			code, body := get("/self")
+			So(code, ShouldEqual, http.StatusUnauthorized)
+			So(body, ShouldContainSubstring, "会话已失效")
+			user.Status = model.UserStatusEnabled
+			So(user.Update(false), ShouldBeNil)
+		})
+		Convey("is revoked when the user is demoted", func() {
+			user.Role = model.RoleCommonUser
+			So(user.Update(false), ShouldBeNil)
+			code, body := get("/admin")
+			So(code, ShouldEqual, http.StatusUnauthorized)
+			So(body, ShouldContainSubstring, "会话已失效")
+			_, body = get("/login")
+			So(body, ShouldContainSubstring, `"success":true`)
+			_, body = get("/admin")
+			So(body, ShouldContainSubstring, "权限不足")
+			_, body = get("/self")
+			So(body, ShouldContainSubstring, `"success":true`)
+		})
+		Convey("is revoked when the password changes", func() {
+			user.Password = "87654321"
+			So(user.Update(true), ShouldBeNil)
+			code, _ := get("/self")
+			So(code, ShouldEqual, http.StatusUnauthorized)
+		})
+		Convey("stays valid when the user is promoted", func() {
+			user.Role = model.RoleRootUser
+			So(user.Update(false), ShouldBeNil)
+			_, body := get("/admin")
+			So(body, ShouldContainSubstring, `"success":true`)
+		})
+	})
+}
+
+func TestPersonalAccessTokenAuth(t *testing.T) {
+	Convey("a personal access token", t, func() {
+		modeltest.SetupDB(t)
+		gin.SetMode(gin.TestMode)
+		createUser := func(username string, role int) *model.User {
+			user := &model.User{Username: username, Role: role, Status: model.UserStatusEnabled, AffCode: username,
+				AccessToken: username + "-access-token"}
+			So(model.DB.Create(user).Error, ShouldBeNil)
+			return user
+		}
+		createToken := func(user *model.User, scopes string, expiredTime int64) string {
+			key, err := (&model.PersonalAccessToken{UserId: user.Id, Name: scopes, Scopes: scopes, ExpiredTime: expiredTime}).Insert()
+			So(err, ShouldBeNil)
+			return key
+		}
+		member := createUser("member", model.RoleCommonUser)
+		admin := createUser("admin", model.RoleAdminUser)
+
+		router := gin.New()
+		router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+		handler := func(c *gin.Context) {
+			_, ok := c.Get(ctxkey.PersonalAccessTokenId)
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": ok})
+		}
+		router.GET("/self", UserAuth(), handler)
+		router.GET("/admin", AdminAuth(), handler)
+		router.GET("/channel", PermissionAuth(model.PermissionChannelRead), handler)
+		router.GET("/channel/write", PermissionAuth(model.PermissionChannelWrite), handler)
+		get := func(key string, path string) string {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+key)
+			router.ServeHTTP(w, req)
+			return w.Body.String()
+		}
+
+		Convey("with the self scope only reaches the routes without a permission", func() {
+			key := createToken(admin, model.PersonalAccessTokenScopeSelf, -1)
+			So(get(key, "/self"), ShouldContainSubstring, `"data":true`)
+			So(get(key, "/admin"), ShouldContainSubstring, `"success":true`)
+			So(get(key, "/channel"), ShouldContainSubstring, "缺少权限范围 "+model.PermissionChannelRead)
+		})
+		Convey("with a permission scope only reaches the routes of the permission", func() {
+			key := createToken(admin, model.PermissionChannelRead, -1)
+			So(get(key, "/channel"), ShouldContainSubstring, `"success":true`)
+			So(get(key, "/channel/write"), ShouldContainSubstring, "缺少权限范围 "+model.PermissionChannelWrite)
+			So(get(key, "/self"), ShouldContainSubstring, "缺少权限范围 "+model.PersonalAccessTokenScopeSelf)
+		})
+		Convey("never exceeds what its user is granted", func() {
+			key := createToken(member, model.PersonalAccessTokenScopeSelf+","+model.PermissionChannelRead, -1)
+			So(get(key, "/self"), ShouldContainSubstring, `"success":true`)
+			So(get(key, "/admin"), ShouldContainSubstring, "权限不足")
+			So(get(key, "/channel"), ShouldContainSubstring, "缺少权限 "+model.PermissionChannelRead)
+		})
+		Convey("is refused once expired or deleted", func() {
+			key := createToken(admin, model.PersonalAccessTokenScopeSelf, 1)
+			So(get(key, "/self"), ShouldContainSubstring, "访问令牌已过期")
+			key = createToken(admin, model.PersonalAccessTokenScopeSelf, -1)
+			So(model.DeleteUserPersonalAccessTokens(admin.Id), ShouldBeNil)
+			So(get(key, "/self"), ShouldContainSubstring, "无效的访问令牌")
+		})
+		Convey("is refused when its user is disabled", func() {
+			key := createToken(member, model.PersonalAccessTokenScopeSelf, -1)
+			So(model.DB.Model(member).Update("status", model.UserStatusDisabled).Error, ShouldBeNil)
+			So(get(key, "/self"), ShouldContainSubstring, "用户已被封禁")
+		})
+		Convey("is not marked on the requests by the access token", func() {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/self", nil)
+			req.Header.Set("Authorization", member.AccessToken)
+			router.ServeHTTP(w, req)
+			So(w.Body.String(), ShouldContainSubstring, `"data":false`)
+		})
+	})
+}
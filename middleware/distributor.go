@@ -43,8 +43,10 @@ func Distribute() func(c *gin.Context) {
 			}
 		} else {
 			requestModel = c.GetString(ctxkey.RequestModel)
+			requirement := getRequestRequirement(c)
+			c.Set(ctxkey.ModelRequirement, requirement)
 			var err error
-			channel, err = model.CacheGetRandomSatisfiedChannel(userGroup, requestModel, false)
+			channel, err = model.CacheGetRandomSatisfiedChannel(userGroup, requestModel, false, requirement)
 			if err != nil {
 				message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", userGroup, requestModel)
 				if channel != nil {
@@ -56,12 +58,25 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		logger.Debugf(ctx, "user id %d, user group: %s, request model: %s, using channel #%d", userId, userGroup, requestModel, channel.Id)
-		SetupContextForSelectedChannel(c, channel, requestModel)
+		if err := SetupContextForSelectedChannel(c, channel, requestModel); err != nil {
+			logger.Error(ctx, err.Error())
+			abortWithMessage(c, http.StatusInternalServerError, "渠道配置错误，请联系管理员")
+			return
+		}
 		c.Next()
 	}
 }
 
-func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) {
+// SetupContextForSelectedChannel is where the channel key and credentials get decrypted for the adaptors
+func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) error {
+	key, err := channel.GetKey()
+	if err != nil {
+		return err
+	}
+	cfg, err := channel.LoadConfig()
+	if err != nil {
+		return err
+	}
 	c.Set(ctxkey.Channel, channel.Type)
 	c.Set(ctxkey.ChannelId, channel.Id)
 	c.Set(ctxkey.ChannelName, channel.Name)
@@ -70,9 +85,8 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	}
 	c.Set(ctxkey.ModelMapping, channel.GetModelMapping())
 	c.Set(ctxkey.OriginalModel, modelName) // for retry
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 	c.Set(ctxkey.BaseURL, channel.GetBaseURL())
-	cfg, _ := channel.LoadConfig()
 	// this is for backward compatibility
 	if channel.Other != nil {
 		switch channel.Type {
@@ -99,4 +113,5 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 		}
 	}
 	c.Set(ctxkey.Config, cfg)
+	return nil
 }
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
+)
+
+type rateLimitSubject struct {
+	name  string
+	key   string
+	limit ratelimit.Limit
+}
+
+func abortWithRateLimit(c *gin.Context, limitType string, message string) {
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": gin.H{
+			"message": helper.MessageWithRequestId(message, c.GetString(helper.RequestIdKey)),
+			"type":    limitType,
+			"param":   nil,
+			"code":    "rate_limit_exceeded",
+		},
+	})
+	c.Abort()
+	logger.Warn(c.Request.Context(), message)
+}
+
+// setRateLimitHeaders reports the tightest of the limits
+func setRateLimitHeaders(c *gin.Context, name string, statuses []ratelimit.Status) {
+	if len(statuses) == 0 {
+		return
+	}
+	tightest := statuses[0]
+	for _, status := range statuses[1:] {
+		if status.Remaining < tightest.Remaining {
+			tightest = status
+		}
+	}
+	c.Header("x-ratelimit-limit-"+name, strconv.Itoa(tightest.Limit))
+	c.Header("x-ratelimit-remaining-"+name, strconv.Itoa(tightest.Remaining))
+	c.Header("x-ratelimit-reset-"+name, fmt.Sprintf("%ds", tightest.Reset))
+}
+
+// RelayRateLimit limits the requests per minute, tokens per minute and concurrent requests
+// of the token and of the user, the limits of users are configured by group.
+// The requests per minute of each end user of the token can be limited too.
+func RelayRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userId := c.GetInt(ctxkey.Id)
+		tokenLimit, _ := c.Value(ctxkey.TokenRateLimit).(ratelimit.Limit)
+		userGroup, err := model.CacheGetUserGroup(userId)
+		if err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		subjects := []rateLimitSubject{
+			{name: "token", key: fmt.Sprintf("token:%d", c.GetInt(ctxkey.TokenId)), limit: tokenLimit},
+			{name: "user", key: fmt.Sprintf("user:%d", userId), limit: ratelimit.GetGroupRateLimit(userGroup)},
+		}
+		if endUser := c.GetString(ctxkey.EndUserId); endUser != "" {
+			endUserLimit, _ := c.Value(ctxkey.EndUserRateLimit).(ratelimit.Limit)
+			subjects = append(subjects, rateLimitSubject{
+				name:  "end user " + endUser,
+				key:   fmt.Sprintf("endUser:%d:%s", c.GetInt(ctxkey.TokenId), endUser),
+				limit: endUserLimit,
+			})
+		}
+		limited := false
+		for _, subject := range subjects {
+			if !subject.limit.IsEmpty() {
+				limited = true
+			}
+		}
+		if !limited {
+			c.Next()
+			return
+		}
+
+		var requestStatuses, tokenStatuses []ratelimit.Status
+		for _, subject := range subjects {
+			if subject.limit.RPM > 0 {
+				status, ok, err := ratelimit.CheckRequests(ctx, subject.key, subject.limit.RPM)
+				if err != nil {
+					abortWithMessage(c, http.StatusInternalServerError, err.Error())
+					return
+				}
+				requestStatuses = append(requestStatuses, status)
+				if !ok {
+					setRateLimitHeaders(c, "requests", requestStatuses)
+					abortWithRateLimit(c, "requests", fmt.Sprintf("Rate limit reached for %s on requests per minute: Limit %d, please try again in %ds", subject.name, status.Limit, status.Reset))
+					return
+				}
+			}
+			if subject.limit.TPM > 0 {
+				status, ok, err := ratelimit.CheckTokens(ctx, subject.key, subject.limit.TPM)
+				if err != nil {
+					abortWithMessage(c, http.StatusInternalServerError, err.Error())
+					return
+				}
+				tokenStatuses = append(tokenStatuses, status)
+				if !ok {
+					setRateLimitHeaders(c, "tokens", tokenStatuses)
+					abortWithRateLimit(c, "tokens", fmt.Sprintf("Rate limit reached for %s on tokens per minute: Limit %d, please try again in %ds", subject.name, status.Limit, status.Reset))
+					return
+				}
+			}
+		}
+		setRateLimitHeaders(c, "requests", requestStatuses)
+		setRateLimitHeaders(c, "tokens", tokenStatuses)
+
+		for _, subject := range subjects {
+			if subject.limit.Concurrency <= 0 {
+				continue
+			}
+			ok, err := ratelimit.Acquire(ctx, subject.key, subject.limit.Concurrency)
+			if err != nil {
+				abortWithMessage(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if !ok {
+				abortWithRateLimit(c, "requests", fmt.Sprintf("Too many concurrent requests for %s: Limit %d", subject.name, subject.limit.Concurrency))
+				return
+			}
+			key := subject.key
+			defer func() {
+				// the request context may be canceled by the client, the slot must be released anyway
+				if err := ratelimit.Release(context.Background(), key); err != nil {
+					logger.Error(ctx, "failed to release concurrency slot: "+err.Error())
+				}
+			}()
+		}
+
+		c.Next()
+
+		usedTokens := c.GetInt(ctxkey.UsedTokens)
+		if usedTokens <= 0 {
+			return
+		}
+		for _, subject := range subjects {
+			if subject.limit.TPM <= 0 {
+				continue
+			}
+			if err := ratelimit.RecordTokens(context.Background(), subject.key, usedTokens); err != nil {
+				logger.Error(ctx, "failed to record used tokens: "+err.Error())
+			}
+		}
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/modelinfo"
+	"sort"
 	"strings"
 )
 
@@ -49,6 +53,28 @@ func getRequestModel(c *gin.Context) (string, error) {
 	return modelRequest.Model, nil
 }
 
+// getRequestRequirement returns the model capabilities required by a chat completion request
+func getRequestRequirement(c *gin.Context) modelinfo.Requirement {
+	if !strings.HasPrefix(c.Request.URL.Path, "/v1/chat/completions") {
+		return modelinfo.Requirement{}
+	}
+	var request relaymodel.GeneralOpenAIRequest
+	err := common.UnmarshalBodyReusable(c, &request)
+	if err != nil {
+		return modelinfo.Requirement{}
+	}
+	return modelinfo.GetRequirement(&request)
+}
+
+func isEndpointInList(endpoint string, endpoints string) bool {
+	for _, e := range strings.Split(endpoints, ",") {
+		if strings.TrimSpace(e) == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
 func isModelInList(modelName string, models string) bool {
 	modelList := strings.Split(models, ",")
 	for _, model := range modelList {
@@ -58,3 +84,75 @@ func isModelInList(modelName string, models string) bool {
 	}
 	return false
 }
+
+// getRelayJWTModels returns the models of the jwt that its parent token may use
+func getRelayJWTModels(claims *model.RelayTokenClaims, token *model.Token) string {
+	var models []string
+	for _, modelName := range claims.Models {
+		if token.GetModels() == "" || isModelInList(modelName, token.GetModels()) {
+			models = append(models, modelName)
+		}
+	}
+	return strings.Join(models, ",")
+}
+
+const (
+	maxEndUserLength = 64
+	maxTagLength     = 64
+	maxTags          = 10
+)
+
+// attributionRequest holds the fields of the request body the usage is attributed by
+type attributionRequest struct {
+	User     string         `json:"user" form:"user"`
+	Metadata map[string]any `json:"metadata" form:"-"`
+}
+
+func truncateString(s string, length int) string {
+	if runes := []rune(s); len(runes) > length {
+		return string(runes[:length])
+	}
+	return s
+}
+
+// getRequestAttribution returns the end user and the tags of the request, the X-End-User and
+// X-OneAPI-Tags headers take precedence over the user and metadata fields of the request body
+func getRequestAttribution(c *gin.Context) (string, []string) {
+	endUser := c.GetHeader(helper.EndUserKey)
+	tagsHeader := c.GetHeader(helper.TagsKey)
+	var request attributionRequest
+	if endUser == "" || tagsHeader == "" {
+		_ = common.UnmarshalBodyReusable(c, &request)
+	}
+	if endUser == "" {
+		endUser = request.User
+	}
+	var rawTags []string
+	if tagsHeader != "" {
+		rawTags = strings.Split(tagsHeader, ",")
+	} else {
+		// metadata {"project": "search"} becomes the tag project:search
+		for key, value := range request.Metadata {
+			rawTags = append(rawTags, fmt.Sprintf("%s:%v", key, value))
+		}
+		sort.Strings(rawTags)
+	}
+	return truncateString(endUser, maxEndUserLength), normalizeTags(rawTags)
+}
+
+func normalizeTags(rawTags []string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range rawTags {
+		tag = truncateString(strings.TrimSpace(tag), maxTagLength)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == maxTags {
+			break
+		}
+	}
+	return tags
+}
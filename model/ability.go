@@ -9,6 +9,7 @@ import (
 
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/utils"
+	"github.com/songquanpeng/one-api/relay/modelinfo"
 )
 
 type Ability struct {
@@ -19,7 +20,7 @@ type Ability struct {
 	Priority  *int64 `json:"priority" gorm:"bigint;default:0;index"`
 }
 
-func GetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority bool) (*Channel, error) {
+func GetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority bool, requirement modelinfo.Requirement) (*Channel, error) {
 	ability := Ability{}
 	groupCol := "`group`"
 	trueVal := "1"
@@ -27,6 +28,27 @@ func GetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority b
 		groupCol = `"group"`
 		trueVal = "true"
 	}
+	if !requirement.IsEmpty() {
+		// the capabilities depend on the model mapping of each channel, so we have to check them one by one
+		var channelIds []int
+		err := DB.Model(&Ability{}).Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model).Pluck("channel_id", &channelIds).Error
+		if err != nil {
+			return nil, err
+		}
+		var channels []*Channel
+		err = DB.Where("id in ?", channelIds).Find(&channels).Error
+		if err != nil {
+			return nil, err
+		}
+		channels = filterChannelsByRequirement(channels, model, requirement)
+		if len(channels) == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		sort.Slice(channels, func(i, j int) bool {
+			return channels[i].GetPriority() > channels[j].GetPriority()
+		})
+		return pickChannelByPriority(channels, ignoreFirstPriority), nil
+	}
 
 	var err error = nil
 	var channelQuery *gorm.DB
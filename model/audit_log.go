@@ -0,0 +1,147 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	AuditActionChannelAdd            = "channel.add"
+	AuditActionChannelUpdate         = "channel.update"
+	AuditActionChannelDelete         = "channel.delete"
+	AuditActionOptionUpdate          = "option.update"
+	AuditActionUserCreate            = "user.create"
+	AuditActionUserUpdate            = "user.update"
+	AuditActionUserDelete            = "user.delete"
+	AuditActionUserTopup             = "user.topup"
+	AuditActionOrganizationTopup     = "organization.topup"
+	AuditActionRedemptionAdd         = "redemption.add"
+	AuditActionRegistrationInviteAdd = "registration_invite.add"
+	auditActionUserManagePrefix      = "user." // followed by the manage action, e.g. user.disable
+)
+
+// AuditRedacted replaces the value of a secret in the diff, so that the change is recorded but not the secret
+const AuditRedacted = "[REDACTED]"
+
+// AuditLog is a mutating admin action, kept apart from the logs so that it isn't cleaned up with them
+type AuditLog struct {
+	Id        int    `json:"id"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	UserId    int    `json:"user_id" gorm:"index"` // the actor
+	Username  string `json:"username" gorm:"index;default:''"`
+	Ip        string `json:"ip" gorm:"default:''"`
+	RequestId string `json:"request_id" gorm:"default:''"`
+	Action    string `json:"action" gorm:"type:varchar(64);index"`
+	Target    string `json:"target" gorm:"type:varchar(64);index;default:''"` // e.g. the channel id or the option key
+	Diff      string `json:"diff" gorm:"type:text"`                           // json object of field to AuditChange
+}
+
+type AuditChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+func AuditActionUserManage(action string) string {
+	return auditActionUserManagePrefix + action
+}
+
+func toAuditFields(v any) map[string]any {
+	fields := make(map[string]any)
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		logger.SysError("failed to marshal audit fields: " + err.Error())
+		return fields
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	if err = decoder.Decode(&fields); err != nil {
+		logger.SysError("failed to unmarshal audit fields: " + err.Error())
+	}
+	return fields
+}
+
+func redactAuditValue(v any) any {
+	if v == nil || v == "" {
+		return v
+	}
+	return AuditRedacted
+}
+
+// AuditDiff returns the json fields which differ between before and after, nil before means created and nil after deleted,
+// the values of the redacted fields are replaced by AuditRedacted
+func AuditDiff(before any, after any, redacted ...string) map[string]*AuditChange {
+	oldFields := toAuditFields(before)
+	newFields := toAuditFields(after)
+	diff := make(map[string]*AuditChange)
+	for key, value := range newFields {
+		if old, ok := oldFields[key]; !ok || !reflect.DeepEqual(old, value) {
+			diff[key] = &AuditChange{Old: oldFields[key], New: value}
+		}
+	}
+	for key, old := range oldFields {
+		if _, ok := newFields[key]; !ok {
+			diff[key] = &AuditChange{Old: old}
+		}
+	}
+	for _, key := range redacted {
+		if change, ok := diff[key]; ok {
+			change.Old = redactAuditValue(change.Old)
+			change.New = redactAuditValue(change.New)
+		}
+	}
+	return diff
+}
+
+func RecordAuditLog(ctx context.Context, userId int, ip string, action string, target string, diff map[string]*AuditChange) {
+	if diff == nil {
+		diff = make(map[string]*AuditChange)
+	}
+	diffBytes, err := json.Marshal(diff)
+	if err != nil {
+		logger.Error(ctx, "failed to marshal audit diff: "+err.Error())
+		return
+	}
+	auditLog := &AuditLog{
+		CreatedAt: helper.GetTimestamp(),
+		UserId:    userId,
+		Username:  GetUsernameById(userId),
+		Ip:        ip,
+		RequestId: helper.GetRequestID(ctx),
+		Action:    action,
+		Target:    target,
+		Diff:      string(diffBytes),
+	}
+	err = LOG_DB.Create(auditLog).Error
+	if err != nil {
+		logger.Error(ctx, "failed to record audit log: "+err.Error())
+	}
+}
+
+func GetAuditLogs(action string, username string, target string, startTimestamp int64, endTimestamp int64, startIdx int, num int) (auditLogs []*AuditLog, err error) {
+	tx := LOG_DB.Model(&AuditLog{})
+	if action != "" {
+		tx = tx.Where("action = ?", action)
+	}
+	if username != "" {
+		tx = tx.Where("username = ?", username)
+	}
+	if target != "" {
+		tx = tx.Where("target = ?", target)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&auditLogs).Error
+	return auditLogs, err
+}
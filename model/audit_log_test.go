@@ -0,0 +1,100 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/encryption"
+)
+
+func TestAuditDiff(t *testing.T) {
+	Convey("AuditDiff", t, func() {
+		type item struct {
+			Name     string `json:"name"`
+			Quota    int64  `json:"quota"`
+			Password string `json:"password"`
+		}
+
+		Convey("only lists the changed fields", func() {
+			diff := AuditDiff(&item{Name: "a", Quota: 1}, &item{Name: "a", Quota: 2})
+			So(diff, ShouldHaveLength, 1)
+			So(diff["quota"].Old, ShouldEqual, json.Number("1"))
+			So(diff["quota"].New, ShouldEqual, json.Number("2"))
+		})
+		Convey("lists all the fields on creation and deletion", func() {
+			diff := AuditDiff(nil, &item{Name: "a"})
+			So(diff, ShouldHaveLength, 3)
+			So(diff["name"].Old, ShouldBeNil)
+			So(diff["name"].New, ShouldEqual, "a")
+			So(AuditDiff((*item)(nil), nil), ShouldBeEmpty)
+			diff = AuditDiff(&item{Name: "a"}, nil)
+			So(diff["name"].Old, ShouldEqual, "a")
+			So(diff["name"].New, ShouldBeNil)
+		})
+		Convey("redacts the secrets but records that they changed", func() {
+			diff := AuditDiff(&item{Password: "old-password"}, &item{Password: "new-password"}, "password")
+			So(diff["password"].Old, ShouldEqual, AuditRedacted)
+			So(diff["password"].New, ShouldEqual, AuditRedacted)
+			diff = AuditDiff(&item{}, &item{Password: "new-password"}, "password")
+			So(diff["password"].Old, ShouldEqual, "")
+			So(diff["password"].New, ShouldEqual, AuditRedacted)
+			So(AuditDiff(&item{Password: "same"}, &item{Password: "same"}, "password"), ShouldBeEmpty)
+		})
+		Convey("redacts the secret options", func() {
+			diff := AuditDiff(map[string]any{"value": "old-secret"}, map[string]any{"value": "new-secret"}, "value")
+			jsonBytes, err := json.Marshal(diff)
+			So(err, ShouldBeNil)
+			So(string(jsonBytes), ShouldNotContainSubstring, "secret")
+			So(diff["value"].New, ShouldEqual, AuditRedacted)
+		})
+	})
+}
+
+func TestChannelAuditDiff(t *testing.T) {
+	Convey("ChannelAuditDiff", t, func() {
+		So(encryption.SetKeys("master"), ShouldBeNil)
+		Reset(func() {
+			_ = encryption.SetKeys("")
+		})
+		newChannel := func(key string, config string) *Channel {
+			channel := &Channel{Id: 1, Name: "channel", Key: key, Config: config}
+			So(channel.encryptSecrets(), ShouldBeNil)
+			return channel
+		}
+		marshal := func(diff map[string]*AuditChange) string {
+			jsonBytes, err := json.Marshal(diff)
+			So(err, ShouldBeNil)
+			return string(jsonBytes)
+		}
+
+		Convey("redacts the key and the credentials of the config", func() {
+			diff := ChannelAuditDiff(nil, newChannel("sk-plaintext", `{"region":"us-east-1","ak":"ak-plaintext","sk":"sk-config"}`))
+			So(diff["key"].New, ShouldEqual, AuditRedacted)
+			So(diff["config.ak"].New, ShouldEqual, AuditRedacted)
+			So(diff["config.sk"].New, ShouldEqual, AuditRedacted)
+			So(diff["config"].New, ShouldContainSubstring, "us-east-1")
+			diffString := marshal(diff)
+			So(diffString, ShouldNotContainSubstring, "plaintext")
+			So(diffString, ShouldNotContainSubstring, "sk-config")
+			So(diffString, ShouldNotContainSubstring, "enc:v1:")
+		})
+		Convey("notices a changed key but not a re-encrypted one", func() {
+			before := newChannel("sk-old", `{"ak":"ak-old"}`)
+			So(ChannelAuditDiff(before, newChannel("sk-old", `{"ak":"ak-old"}`)), ShouldBeEmpty)
+			diff := ChannelAuditDiff(before, newChannel("sk-new", `{"ak":"ak-new"}`))
+			So(diff, ShouldHaveLength, 2)
+			So(diff["key"].Old, ShouldEqual, AuditRedacted)
+			So(diff["key"].New, ShouldEqual, AuditRedacted)
+			So(diff["config.ak"], ShouldNotBeNil)
+			So(marshal(diff), ShouldNotContainSubstring, "-old")
+		})
+		Convey("shows the other changes of the config", func() {
+			diff := ChannelAuditDiff(newChannel("sk", `{"region":"a","sk":"secret"}`), newChannel("sk", `{"region":"b","sk":"secret"}`))
+			So(diff, ShouldHaveLength, 1)
+			So(diff["config"].Old, ShouldEqual, `{"region":"a"}`)
+			So(diff["config"].New, ShouldEqual, `{"region":"b"}`)
+		})
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/relay/modelinfo"
 	"math/rand"
 	"sort"
 	"strconv"
@@ -23,21 +24,22 @@ var (
 	UserId2QuotaCacheSeconds  = config.SyncFrequency
 	UserId2StatusCacheSeconds = config.SyncFrequency
 	GroupModelsCacheSeconds   = config.SyncFrequency
+	SigningKeyCacheSeconds    = config.SyncFrequency
+	UserId2RoleCacheSeconds   = config.SyncFrequency
+	UserId2AuthCacheSeconds   = config.SyncFrequency
 )
 
+// CacheGetTokenByKey looks up the token by the hash of the key, the plaintext key is never stored
 func CacheGetTokenByKey(key string) (*Token, error) {
-	keyCol := "`key`"
-	if common.UsingPostgreSQL {
-		keyCol = `"key"`
-	}
+	keyHash := HashTokenKey(key)
 	var token Token
 	if !common.RedisEnabled {
-		err := DB.Where(keyCol+" = ?", key).First(&token).Error
+		err := DB.Where("key_hash = ?", keyHash).First(&token).Error
 		return &token, err
 	}
-	tokenObjectString, err := common.RedisGet(fmt.Sprintf("token:%s", key))
+	tokenObjectString, err := common.RedisGet(fmt.Sprintf("token:%s", keyHash))
 	if err != nil {
-		err := DB.Where(keyCol+" = ?", key).First(&token).Error
+		err := DB.Where("key_hash = ?", keyHash).First(&token).Error
 		if err != nil {
 			return nil, err
 		}
@@ -45,7 +47,7 @@ func CacheGetTokenByKey(key string) (*Token, error) {
 		if err != nil {
 			return nil, err
 		}
-		err = common.RedisSet(fmt.Sprintf("token:%s", key), string(jsonBytes), time.Duration(TokenCacheSeconds)*time.Second)
+		err = common.RedisSet(fmt.Sprintf("token:%s", keyHash), string(jsonBytes), time.Duration(TokenCacheSeconds)*time.Second)
 		if err != nil {
 			logger.SysError("Redis set token error: " + err.Error())
 		}
@@ -55,6 +57,43 @@ func CacheGetTokenByKey(key string) (*Token, error) {
 	return &token, err
 }
 
+// CacheGetSigningKeyById is called for every relay jwt, the secret stays encrypted in the cache
+func CacheGetSigningKeyById(id int) (*SigningKey, error) {
+	if !common.RedisEnabled {
+		return GetSigningKeyById(id, true)
+	}
+	var key SigningKey
+	keyObjectString, err := common.RedisGet(fmt.Sprintf("signing_key:%d", id))
+	if err == nil {
+		err = json.Unmarshal([]byte(keyObjectString), &key)
+		return &key, err
+	}
+	signingKey, err := GetSigningKeyById(id, true)
+	if err != nil {
+		return nil, err
+	}
+	jsonBytes, err := json.Marshal(signingKey)
+	if err != nil {
+		return nil, err
+	}
+	err = common.RedisSet(fmt.Sprintf("signing_key:%d", id), string(jsonBytes), time.Duration(SigningKeyCacheSeconds)*time.Second)
+	if err != nil {
+		logger.SysError("Redis set signing key error: " + err.Error())
+	}
+	return signingKey, nil
+}
+
+// cacheDeleteSigningKey makes a disabled or deleted signing key take effect at once
+func cacheDeleteSigningKey(id int) {
+	if !common.RedisEnabled {
+		return
+	}
+	err := common.RedisDel(fmt.Sprintf("signing_key:%d", id))
+	if err != nil {
+		logger.SysError("Redis delete signing key error: " + err.Error())
+	}
+}
+
 func CacheGetUserGroup(id int) (group string, err error) {
 	if !common.RedisEnabled {
 		return GetUserGroup(id)
@@ -73,6 +112,76 @@ func CacheGetUserGroup(id int) (group string, err error) {
 	return group, err
 }
 
+// CacheGetUserCustomPermissions returns the comma separated permissions of the custom role of the user, checked on every admin request
+func CacheGetUserCustomPermissions(userId int) (permissions string, err error) {
+	if !common.RedisEnabled {
+		return getUserCustomPermissions(userId)
+	}
+	permissions, err = common.RedisGet(fmt.Sprintf("user_custom_permissions:%d", userId))
+	if err == nil {
+		return permissions, nil
+	}
+	permissions, err = getUserCustomPermissions(userId)
+	if err != nil {
+		return "", err
+	}
+	err = common.RedisSet(fmt.Sprintf("user_custom_permissions:%d", userId), permissions, time.Duration(UserId2RoleCacheSeconds)*time.Second)
+	if err != nil {
+		logger.SysError("Redis set user custom permissions error: " + err.Error())
+	}
+	return permissions, nil
+}
+
+// cacheDeleteUserCustomPermissions makes a change of the custom role or of its permissions take effect at once
+func cacheDeleteUserCustomPermissions(userIds ...int) {
+	if !common.RedisEnabled {
+		return
+	}
+	for _, userId := range userIds {
+		err := common.RedisDel(fmt.Sprintf("user_custom_permissions:%d", userId))
+		if err != nil {
+			logger.SysError("Redis delete user custom permissions error: " + err.Error())
+		}
+	}
+}
+
+// CacheGetUserAuth returns the username, role and status of the user, read on every dashboard request
+func CacheGetUserAuth(id int) (*User, error) {
+	if !common.RedisEnabled {
+		return getUserAuth(id)
+	}
+	var user User
+	userObjectString, err := common.RedisGet(fmt.Sprintf("user_auth:%d", id))
+	if err == nil {
+		err = json.Unmarshal([]byte(userObjectString), &user)
+		return &user, err
+	}
+	userAuth, err := getUserAuth(id)
+	if err != nil {
+		return nil, err
+	}
+	jsonBytes, err := json.Marshal(userAuth)
+	if err != nil {
+		return nil, err
+	}
+	err = common.RedisSet(fmt.Sprintf("user_auth:%d", id), string(jsonBytes), time.Duration(UserId2AuthCacheSeconds)*time.Second)
+	if err != nil {
+		logger.SysError("Redis set user auth error: " + err.Error())
+	}
+	return userAuth, nil
+}
+
+// cacheDeleteUserAuth makes a change of the username, role or status take effect at once
+func cacheDeleteUserAuth(id int) {
+	if !common.RedisEnabled {
+		return
+	}
+	err := common.RedisDel(fmt.Sprintf("user_auth:%d", id))
+	if err != nil {
+		logger.SysError("Redis delete user auth error: " + err.Error())
+	}
+}
+
 func fetchAndUpdateUserQuota(ctx context.Context, id int) (quota int64, err error) {
 	quota, err = GetUserQuota(id)
 	if err != nil {
@@ -224,16 +333,25 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
-func CacheGetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority bool) (*Channel, error) {
+func CacheGetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority bool, requirement modelinfo.Requirement) (*Channel, error) {
 	if !config.MemoryCacheEnabled {
-		return GetRandomSatisfiedChannel(group, model, ignoreFirstPriority)
+		return GetRandomSatisfiedChannel(group, model, ignoreFirstPriority, requirement)
 	}
 	channelSyncLock.RLock()
 	defer channelSyncLock.RUnlock()
 	channels := group2model2channels[group][model]
+	if !requirement.IsEmpty() {
+		channels = filterChannelsByRequirement(channels, model, requirement)
+	}
 	if len(channels) == 0 {
 		return nil, errors.New("channel not found")
 	}
+	return pickChannelByPriority(channels, ignoreFirstPriority), nil
+}
+
+// pickChannelByPriority randomly picks a channel of the highest priority,
+// or of the lower priorities if ignoreFirstPriority is set, the channels should be sorted by priority
+func pickChannelByPriority(channels []*Channel, ignoreFirstPriority bool) *Channel {
 	endIdx := len(channels)
 	// choose by priority
 	firstChannel := channels[0]
@@ -251,5 +369,24 @@ func CacheGetRandomSatisfiedChannel(group string, model string, ignoreFirstPrior
 			idx = random.RandRange(endIdx, len(channels))
 		}
 	}
-	return channels[idx], nil
+	return channels[idx]
+}
+
+// filterChannelsByRequirement drops the channels whose actual model lacks the required capabilities
+func filterChannelsByRequirement(channels []*Channel, model string, requirement modelinfo.Requirement) []*Channel {
+	filtered := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		actualModel := model
+		if mappedModel := channel.GetModelMapping()[model]; mappedModel != "" {
+			actualModel = mappedModel
+		}
+		channelRequirement := requirement
+		if requirement.Stream && channel.fakesStream(actualModel) {
+			channelRequirement.Stream = false
+		}
+		if modelinfo.IsModelSatisfied(actualModel, channelRequirement) {
+			filtered = append(filtered, channel)
+		}
+	}
+	return filtered
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/encryption"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
 	"gorm.io/gorm"
@@ -50,6 +51,24 @@ type ChannelConfig struct {
 	Plugin            string `json:"plugin,omitempty"`
 	VertexAIProjectID string `json:"vertex_ai_project_id,omitempty"`
 	VertexAIADC       string `json:"vertex_ai_adc,omitempty"`
+	// StreamConversion maps model name to the stream conversion mode, "*" matches all models
+	StreamConversion map[string]string `json:"stream_conversion,omitempty"`
+}
+
+const (
+	// StreamConversionFake serves stream clients from upstreams which only support non-stream
+	StreamConversionFake = "fake_stream"
+	// StreamConversionAggregate serves non-stream clients from upstreams which only support stream
+	StreamConversionAggregate = "aggregate_stream"
+)
+
+// GetStreamConversion returns the stream conversion mode of the model, empty if the stream mode is passed through
+func (cfg ChannelConfig) GetStreamConversion(modelName string) string {
+	mode, ok := cfg.StreamConversion[modelName]
+	if !ok {
+		mode = cfg.StreamConversion["*"]
+	}
+	return mode
 }
 
 func GetAllChannels(startIdx int, num int, scope string) ([]*Channel, error) {
@@ -84,6 +103,12 @@ func GetChannelById(id int, selectAll bool) (*Channel, error) {
 
 func BatchInsertChannels(channels []Channel) error {
 	var err error
+	for i := range channels {
+		err = channels[i].encryptSecrets()
+		if err != nil {
+			return err
+		}
+	}
 	err = DB.Create(&channels).Error
 	if err != nil {
 		return err
@@ -124,8 +149,18 @@ func (channel *Channel) GetModelMapping() map[string]string {
 	return modelMapping
 }
 
+// fakesStream tells whether the channel serves the stream requests of the model with non-stream upstream requests
+func (channel *Channel) fakesStream(modelName string) bool {
+	cfg, err := channel.parseConfig()
+	return err == nil && cfg.GetStreamConversion(modelName) == StreamConversionFake
+}
+
 func (channel *Channel) Insert() error {
 	var err error
+	err = channel.encryptSecrets()
+	if err != nil {
+		return err
+	}
 	err = DB.Create(channel).Error
 	if err != nil {
 		return err
@@ -136,6 +171,10 @@ func (channel *Channel) Insert() error {
 
 func (channel *Channel) Update() error {
 	var err error
+	err = channel.encryptSecrets()
+	if err != nil {
+		return err
+	}
 	err = DB.Model(channel).Updates(channel).Error
 	if err != nil {
 		return err
@@ -175,15 +214,18 @@ func (channel *Channel) Delete() error {
 	return err
 }
 
+// LoadConfig returns the config with its credentials decrypted
 func (channel *Channel) LoadConfig() (ChannelConfig, error) {
-	var cfg ChannelConfig
-	if channel.Config == "" {
-		return cfg, nil
-	}
-	err := json.Unmarshal([]byte(channel.Config), &cfg)
+	cfg, err := channel.parseConfig()
 	if err != nil {
 		return cfg, err
 	}
+	for _, secret := range cfg.secrets() {
+		*secret, err = encryption.Decrypt(*secret)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to decrypt config of channel %d: %w", channel.Id, err)
+		}
+	}
 	return cfg, nil
 }
 
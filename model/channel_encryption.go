@@ -0,0 +1,157 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/songquanpeng/one-api/common/encryption"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// secrets returns the credential fields of the config, which are encrypted at rest
+func (cfg *ChannelConfig) secrets() []*string {
+	return []*string{&cfg.SK, &cfg.AK, &cfg.VertexAIADC}
+}
+
+func (channel *Channel) parseConfig() (ChannelConfig, error) {
+	var cfg ChannelConfig
+	if channel.Config == "" {
+		return cfg, nil
+	}
+	err := json.Unmarshal([]byte(channel.Config), &cfg)
+	return cfg, err
+}
+
+// sealSecrets applies seal to the key and the credentials of the config,
+// the config is only rewritten if one of its credentials changed
+func (channel *Channel) sealSecrets(seal func(string) (string, error)) error {
+	key, err := seal(channel.Key)
+	if err != nil {
+		return err
+	}
+	channel.Key = key
+	if channel.Config == "" {
+		return nil
+	}
+	cfg, err := channel.parseConfig()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, secret := range cfg.secrets() {
+		sealed, err := seal(*secret)
+		if err != nil {
+			return err
+		}
+		if sealed != *secret {
+			*secret = sealed
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	jsonBytes, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	channel.Config = string(jsonBytes)
+	return nil
+}
+
+func (channel *Channel) encryptSecrets() error {
+	return channel.sealSecrets(encryption.Encrypt)
+}
+
+// RedactSecrets blanks the key and the credentials of the config, for the users who can't edit the channel
+func (channel *Channel) RedactSecrets() {
+	channel.Key = ""
+	cfg, err := channel.parseConfig()
+	if err != nil {
+		channel.Config = ""
+		return
+	}
+	for _, secret := range cfg.secrets() {
+		*secret = ""
+	}
+	jsonBytes, err := json.Marshal(cfg)
+	if err != nil {
+		channel.Config = ""
+		return
+	}
+	channel.Config = string(jsonBytes)
+}
+
+// GetKey returns the decrypted key of the channel
+func (channel *Channel) GetKey() (string, error) {
+	key, err := encryption.Decrypt(channel.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key of channel %d: %w", channel.Id, err)
+	}
+	return key, nil
+}
+
+// ReencryptChannels encrypts the plaintext channels and the ones sealed by a retired master key
+// with the current master key, it returns the number of channels rewritten
+func ReencryptChannels() (int, error) {
+	if !encryption.Enabled() {
+		return 0, fmt.Errorf("channel encryption key is not set")
+	}
+	var channels []*Channel
+	err := DB.Select("id", "key", "config").Find(&channels).Error
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, channel := range channels {
+		key, config := channel.Key, channel.Config
+		err = channel.sealSecrets(encryption.Reencrypt)
+		if err != nil {
+			return count, fmt.Errorf("failed to re-encrypt channel %d: %w", channel.Id, err)
+		}
+		if channel.Key == key && channel.Config == config {
+			continue
+		}
+		err = DB.Model(&Channel{}).Where("id = ?", channel.Id).Updates(map[string]interface{}{
+			"key":    channel.Key,
+			"config": channel.Config,
+		}).Error
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	logger.SysLog(fmt.Sprintf("re-encrypted %d channels", count))
+	return count, nil
+}
+
+// channelAuditFields are the channel with the key and the credentials of the config decrypted and flattened,
+// so that AuditDiff notices when they change, they are all listed in channelAuditRedacted
+func channelAuditFields(channel *Channel) map[string]any {
+	if channel == nil {
+		return nil
+	}
+	fields := toAuditFields(channel)
+	if key, err := channel.GetKey(); err == nil {
+		fields["key"] = key
+	}
+	cfg, err := channel.LoadConfig()
+	if err != nil {
+		return fields
+	}
+	fields["config.sk"], fields["config.ak"], fields["config.vertex_ai_adc"] = cfg.SK, cfg.AK, cfg.VertexAIADC
+	for _, secret := range cfg.secrets() {
+		*secret = ""
+	}
+	if jsonBytes, err := json.Marshal(cfg); err == nil {
+		fields["config"] = string(jsonBytes)
+	}
+	return fields
+}
+
+var channelAuditRedacted = []string{"key", "config.sk", "config.ak", "config.vertex_ai_adc"}
+
+// ChannelAuditDiff is AuditDiff for the channels, the key and the credentials are redacted
+func ChannelAuditDiff(before *Channel, after *Channel) map[string]*AuditChange {
+	return AuditDiff(channelAuditFields(before), channelAuditFields(after), channelAuditRedacted...)
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/relay/modelinfo"
+)
+
+func TestFilterChannelsByRequirement(t *testing.T) {
+	Convey("filter the channels by the capabilities of their model", t, func() {
+		mapping := `{"reasoning":"o1"}`
+		plain := &Channel{Id: 1, ModelMapping: &mapping}
+		fake := &Channel{Id: 2, ModelMapping: &mapping, Config: `{"stream_conversion":{"o1":"fake_stream"}}`}
+		wildcard := &Channel{Id: 3, ModelMapping: &mapping, Config: `{"stream_conversion":{"*":"fake_stream"}}`}
+		channels := []*Channel{plain, fake, wildcard}
+
+		Convey("a stream request skips the channels which can't stream the mapped model", func() {
+			filtered := filterChannelsByRequirement(channels, "reasoning", modelinfo.Requirement{Stream: true})
+			So(filtered, ShouldResemble, []*Channel{fake, wildcard})
+		})
+		Convey("the fake stream doesn't add the other capabilities", func() {
+			filtered := filterChannelsByRequirement(channels, "reasoning", modelinfo.Requirement{Stream: true, Audio: true})
+			So(filtered, ShouldBeEmpty)
+		})
+		Convey("a non-stream request is served by all of them", func() {
+			filtered := filterChannelsByRequirement(channels, "reasoning", modelinfo.Requirement{Tools: true})
+			So(filtered, ShouldHaveLength, 3)
+		})
+	})
+}
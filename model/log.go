@@ -29,6 +29,8 @@ type Log struct {
 	ElapsedTime       int64  `json:"elapsed_time" gorm:"default:0"` // unit is ms
 	IsStream          bool   `json:"is_stream" gorm:"default:false"`
 	SystemPromptReset bool   `json:"system_prompt_reset" gorm:"default:false"`
+	EndUser           string `json:"end_user" gorm:"type:varchar(64);index;default:''"`
+	OrganizationId    int    `json:"organization_id" gorm:"index;default:0"`
 }
 
 const (
@@ -43,6 +45,12 @@ const (
 func recordLogHelper(ctx context.Context, log *Log) {
 	requestId := helper.GetRequestID(ctx)
 	log.RequestId = requestId
+	if log.EndUser == "" {
+		log.EndUser = helper.GetEndUser(ctx)
+	}
+	if log.OrganizationId == 0 {
+		log.OrganizationId = helper.GetOrganizationId(ctx)
+	}
 	err := LOG_DB.Create(log).Error
 	if err != nil {
 		logger.Error(ctx, "failed to record log: "+err.Error())
@@ -85,6 +93,9 @@ func RecordConsumeLog(ctx context.Context, log *Log) {
 	log.CreatedAt = helper.GetTimestamp()
 	log.Type = LogTypeConsume
 	recordLogHelper(ctx, log)
+	if log.Id != 0 {
+		recordLogTags(ctx, log.Id)
+	}
 }
 
 func RecordTestLog(ctx context.Context, log *Log) {
@@ -155,7 +166,7 @@ func SearchUserLogs(userId int, keyword string) (logs []*Log, err error) {
 	return logs, err
 }
 
-func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int) (quota int64) {
+func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, tag string) (quota int64) {
 	ifnull := "ifnull"
 	if common.UsingPostgreSQL {
 		ifnull = "COALESCE"
@@ -179,6 +190,7 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 	if channel != 0 {
 		tx = tx.Where("channel_id = ?", channel)
 	}
+	tx = withTag(tx, tag)
 	tx.Where("type = ?", LogTypeConsume).Scan(&quota)
 	return quota
 }
@@ -209,6 +221,10 @@ func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelNa
 }
 
 func DeleteOldLog(targetTimestamp int64) (int64, error) {
+	err := LOG_DB.Where("log_id in (?)", LOG_DB.Model(&Log{}).Select("id").Where("created_at < ?", targetTimestamp)).Delete(&LogTag{}).Error
+	if err != nil {
+		return 0, err
+	}
 	result := LOG_DB.Where("created_at < ?", targetTimestamp).Delete(&Log{})
 	return result.RowsAffected, result.Error
 }
@@ -222,7 +238,8 @@ type LogStatistic struct {
 	CompletionTokens int    `gorm:"column:completion_tokens"`
 }
 
-func SearchLogsByDayAndModel(userId, start, end int) (LogStatistics []*LogStatistic, err error) {
+// SearchLogsByDayAndModel only counts the logs having the tag if it is not empty
+func SearchLogsByDayAndModel(userId, start, end int, tag string) (LogStatistics []*LogStatistic, err error) {
 	groupSelect := "DATE_FORMAT(FROM_UNIXTIME(created_at), '%Y-%m-%d') as day"
 
 	if common.UsingPostgreSQL {
@@ -233,6 +250,13 @@ func SearchLogsByDayAndModel(userId, start, end int) (LogStatistics []*LogStatis
 		groupSelect = "strftime('%Y-%m-%d', datetime(created_at, 'unixepoch')) as day"
 	}
 
+	tagCondition := ""
+	args := []interface{}{userId, start, end}
+	if tag != "" {
+		tagCondition = "AND id IN (SELECT log_id FROM log_tags WHERE tag = ?)"
+		args = append(args, tag)
+	}
+
 	err = LOG_DB.Raw(`
 		SELECT `+groupSelect+`,
 		model_name, count(1) as request_count,
@@ -243,9 +267,42 @@ func SearchLogsByDayAndModel(userId, start, end int) (LogStatistics []*LogStatis
 		WHERE type=2
 		AND user_id= ?
 		AND created_at BETWEEN ? AND ?
+		`+tagCondition+`
 		GROUP BY day, model_name
 		ORDER BY day, model_name
-	`, userId, start, end).Scan(&LogStatistics).Error
+	`, args...).Scan(&LogStatistics).Error
 
 	return LogStatistics, err
 }
+
+type EndUserStatistic struct {
+	EndUser          string `json:"end_user" gorm:"column:end_user"`
+	RequestCount     int    `json:"request_count" gorm:"column:request_count"`
+	Quota            int64  `json:"quota" gorm:"column:quota"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"column:completion_tokens"`
+}
+
+// SumUsedQuotaByEndUser returns the usage of the end users with the most spend first
+func SumUsedQuotaByEndUser(startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string) (stats []*EndUserStatistic, err error) {
+	tx := LOG_DB.Table("logs").
+		Select("end_user, count(1) as request_count, sum(quota) as quota, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens").
+		Where("type = ? and end_user <> ''", LogTypeConsume)
+	if username != "" {
+		tx = tx.Where("username = ?", username)
+	}
+	if tokenName != "" {
+		tx = tx.Where("token_name = ?", tokenName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	err = tx.Group("end_user").Order("quota desc").Limit(config.MaxRecentItems).Scan(&stats).Error
+	return stats, err
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// LogTag is a tag of a consume log, such as project:search, kept in the log database
+type LogTag struct {
+	Id    int    `json:"id"`
+	LogId int    `json:"log_id" gorm:"index"`
+	Tag   string `json:"tag" gorm:"type:varchar(64);index"`
+}
+
+func recordLogTags(ctx context.Context, logId int) {
+	tags := helper.GetTags(ctx)
+	if len(tags) == 0 {
+		return
+	}
+	logTags := make([]LogTag, 0, len(tags))
+	for _, tag := range tags {
+		logTags = append(logTags, LogTag{LogId: logId, Tag: tag})
+	}
+	err := LOG_DB.Create(&logTags).Error
+	if err != nil {
+		logger.Error(ctx, "failed to record log tags: "+err.Error())
+	}
+}
+
+// withTag keeps the logs having the tag
+func withTag(tx *gorm.DB, tag string) *gorm.DB {
+	if tag == "" {
+		return tx
+	}
+	return tx.Where("id in (?)", LOG_DB.Model(&LogTag{}).Select("log_id").Where("tag = ?", tag))
+}
+
+type TagStatistic struct {
+	Tag              string `json:"tag" gorm:"column:tag"`
+	RequestCount     int    `json:"request_count" gorm:"column:request_count"`
+	Quota            int64  `json:"quota" gorm:"column:quota"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"column:completion_tokens"`
+}
+
+// SumUsedQuotaByTag breaks the spend down by tag, tagPrefix such as project: keeps one dimension of the tags
+func SumUsedQuotaByTag(startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, tagPrefix string) (stats []*TagStatistic, err error) {
+	tx := LOG_DB.Table("log_tags").
+		Select("log_tags.tag as tag, count(1) as request_count, sum(logs.quota) as quota, sum(logs.prompt_tokens) as prompt_tokens, sum(logs.completion_tokens) as completion_tokens").
+		Joins("join logs on logs.id = log_tags.log_id").
+		Where("logs.type = ?", LogTypeConsume)
+	if tagPrefix != "" {
+		tx = tx.Where("log_tags.tag LIKE ?", tagPrefix+"%")
+	}
+	if username != "" {
+		tx = tx.Where("logs.username = ?", username)
+	}
+	if tokenName != "" {
+		tx = tx.Where("logs.token_name = ?", tokenName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	if modelName != "" {
+		tx = tx.Where("logs.model_name = ?", modelName)
+	}
+	if channel != 0 {
+		tx = tx.Where("logs.channel_id = ?", channel)
+	}
+	err = tx.Group("log_tags.tag").Order("quota desc").Scan(&stats).Error
+	return stats, err
+}
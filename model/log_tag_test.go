@@ -0,0 +1,58 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+func TestSumUsedQuotaByTag(t *testing.T) {
+	Convey("sum the used quota by tag", t, func() {
+		setupTestDB(t)
+		alice := &User{Username: "alice", AffCode: "alice", AccessToken: "alice"}
+		So(DB.Create(alice).Error, ShouldBeNil)
+		ctx := context.Background()
+		recordTestConsumeLog(helper.SetTags(ctx, []string{"project:search", "team:a"}), alice.Id, "app", "gpt-4o", 100)
+		recordTestConsumeLog(helper.SetTags(ctx, []string{"project:search"}), alice.Id, "app", "gpt-4o-mini", 20)
+		recordTestConsumeLog(helper.SetTags(ctx, []string{"project:chat", "team:a"}), alice.Id, "other", "gpt-4o", 300)
+		// untagged requests are left out
+		recordTestConsumeLog(ctx, alice.Id, "app", "gpt-4o", 1000)
+
+		Convey("all tags, the most spend first", func() {
+			stats, err := SumUsedQuotaByTag(0, 0, "", "", "", 0, "")
+			So(err, ShouldBeNil)
+			So(len(stats), ShouldEqual, 3)
+			So(stats[0].Tag, ShouldEqual, "team:a")
+			So(stats[0].Quota, ShouldEqual, 400)
+			So(stats[1].Tag, ShouldEqual, "project:chat")
+			So(stats[2].Tag, ShouldEqual, "project:search")
+			So(stats[2].RequestCount, ShouldEqual, 2)
+			So(stats[2].Quota, ShouldEqual, 120)
+			So(stats[2].PromptTokens, ShouldEqual, 60)
+			So(stats[2].CompletionTokens, ShouldEqual, 30)
+		})
+		Convey("one dimension of the tags", func() {
+			stats, err := SumUsedQuotaByTag(0, 0, "", "", "", 0, "project:")
+			So(err, ShouldBeNil)
+			So(len(stats), ShouldEqual, 2)
+			So(stats[0].Tag, ShouldEqual, "project:chat")
+			So(stats[1].Tag, ShouldEqual, "project:search")
+		})
+		Convey("filtered by the log columns", func() {
+			stats, err := SumUsedQuotaByTag(0, 0, "gpt-4o", "alice", "app", 0, "")
+			So(err, ShouldBeNil)
+			So(len(stats), ShouldEqual, 2)
+			for _, stat := range stats {
+				So(stat.Quota, ShouldEqual, 100)
+			}
+		})
+		Convey("the used quota of one tag", func() {
+			So(SumUsedQuota(LogTypeConsume, 0, 0, "", "", "", 0, "team:a"), ShouldEqual, 400)
+			So(SumUsedQuota(LogTypeConsume, 0, 0, "", "", "", 0, "project:search"), ShouldEqual, 120)
+			So(SumUsedQuota(LogTypeConsume, 0, 0, "", "", "", 0, ""), ShouldEqual, 1420)
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+func recordTestConsumeLog(ctx context.Context, userId int, tokenName string, modelName string, quota int) {
+	RecordConsumeLog(ctx, &Log{
+		UserId:           userId,
+		TokenName:        tokenName,
+		ModelName:        modelName,
+		Quota:            quota,
+		PromptTokens:     quota / 2,
+		CompletionTokens: quota / 4,
+	})
+}
+
+func TestSumUsedQuotaByEndUser(t *testing.T) {
+	Convey("sum the used quota by end user", t, func() {
+		setupTestDB(t)
+		alice := &User{Username: "alice", AffCode: "alice", AccessToken: "alice"}
+		bob := &User{Username: "bob", AffCode: "bob", AccessToken: "bob"}
+		So(DB.Create(alice).Error, ShouldBeNil)
+		So(DB.Create(bob).Error, ShouldBeNil)
+		ctx := context.Background()
+		recordTestConsumeLog(helper.SetEndUser(ctx, "u1"), alice.Id, "app", "gpt-4o", 100)
+		recordTestConsumeLog(helper.SetEndUser(ctx, "u1"), alice.Id, "app", "gpt-4o-mini", 20)
+		recordTestConsumeLog(helper.SetEndUser(ctx, "u2"), alice.Id, "app", "gpt-4o", 300)
+		recordTestConsumeLog(helper.SetEndUser(ctx, "u3"), bob.Id, "other", "gpt-4o", 50)
+		// requests without an end user are left out
+		recordTestConsumeLog(ctx, alice.Id, "app", "gpt-4o", 1000)
+		RecordLog(ctx, alice.Id, LogTypeTopup, "topup")
+
+		Convey("all end users, the most spend first", func() {
+			stats, err := SumUsedQuotaByEndUser(0, 0, "", "", "")
+			So(err, ShouldBeNil)
+			So(len(stats), ShouldEqual, 3)
+			So(stats[0].EndUser, ShouldEqual, "u2")
+			So(stats[0].Quota, ShouldEqual, 300)
+			So(stats[1].EndUser, ShouldEqual, "u1")
+			So(stats[1].RequestCount, ShouldEqual, 2)
+			So(stats[1].Quota, ShouldEqual, 120)
+			So(stats[1].PromptTokens, ShouldEqual, 60)
+			So(stats[1].CompletionTokens, ShouldEqual, 30)
+		})
+		Convey("the end users of one user", func() {
+			stats, err := SumUsedQuotaByEndUser(0, 0, "", "bob", "")
+			So(err, ShouldBeNil)
+			So(len(stats), ShouldEqual, 1)
+			So(stats[0].EndUser, ShouldEqual, "u3")
+		})
+		Convey("filtered by model and token", func() {
+			stats, err := SumUsedQuotaByEndUser(0, 0, "gpt-4o-mini", "", "app")
+			So(err, ShouldBeNil)
+			So(len(stats), ShouldEqual, 1)
+			So(stats[0].Quota, ShouldEqual, 20)
+		})
+		Convey("filtered by time", func() {
+			stats, err := SumUsedQuotaByEndUser(helper.GetTimestamp()+3600, 0, "", "", "")
+			So(err, ShouldBeNil)
+			So(len(stats), ShouldEqual, 0)
+		})
+	})
+}
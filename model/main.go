@@ -108,6 +108,26 @@ func openSQLite() (*gorm.DB, error) {
 	})
 }
 
+// OpenMemoryDB points DB and LOG_DB at a fresh in-memory sqlite database with all the tables, for the tests
+func OpenMemoryDB() (*sql.DB, error) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// every connection to :memory: opens another database
+	sqlDB.SetMaxOpenConns(1)
+	DB, LOG_DB = db, db
+	if err = migrateDB(); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return sqlDB, nil
+}
+
 func InitDB() {
 	var err error
 	DB, err = chooseDB("SQL_DSN")
@@ -119,6 +139,7 @@ func InitDB() {
 	sqlDB := setDBConns(DB)
 
 	if !config.IsMasterNode {
+		initTokenHashSecret()
 		return
 	}
 
@@ -131,6 +152,11 @@ func InitDB() {
 		logger.FatalLog("failed to migrate database: " + err.Error())
 		return
 	}
+	initTokenHashSecret()
+	if err = migrateTokenKeys(); err != nil {
+		logger.FatalLog("failed to hash token keys: " + err.Error())
+		return
+	}
 	logger.SysLog("database migrated")
 }
 
@@ -142,6 +168,42 @@ func migrateDB() error {
 	if err = DB.AutoMigrate(&Token{}); err != nil {
 		return err
 	}
+	if err = DB.AutoMigrate(&TokenPeriodUsage{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&SigningKey{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&CustomRole{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Organization{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&OrganizationMember{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&OrganizationInvitation{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&OAuthProvider{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&UserIdentity{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&UserSession{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&TwoFactorChallenge{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&PersonalAccessToken{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&RegistrationInvite{}); err != nil {
+		return err
+	}
 	if err = DB.AutoMigrate(&User{}); err != nil {
 		return err
 	}
@@ -157,6 +219,12 @@ func migrateDB() error {
 	if err = DB.AutoMigrate(&Log{}); err != nil {
 		return err
 	}
+	if err = DB.AutoMigrate(&LogTag{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&AuditLog{}); err != nil {
+		return err
+	}
 	if err = DB.AutoMigrate(&Channel{}); err != nil {
 		return err
 	}
@@ -197,6 +265,12 @@ func migrateLOGDB() error {
 	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
 		return err
 	}
+	if err = LOG_DB.AutoMigrate(&LogTag{}); err != nil {
+		return err
+	}
+	if err = LOG_DB.AutoMigrate(&AuditLog{}); err != nil {
+		return err
+	}
 	return nil
 }
 
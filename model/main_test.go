@@ -0,0 +1,19 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/songquanpeng/one-api/common"
+)
+
+// setupTestDB is modeltest.SetupDB, which can't be imported by the tests of this package
+func setupTestDB(t *testing.T) {
+	sqlDB, err := OpenMemoryDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.RedisEnabled = false
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+}
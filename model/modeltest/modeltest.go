@@ -0,0 +1,22 @@
+// Package modeltest provides the database of the tests which go through the model
+package modeltest
+
+import (
+	"testing"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// SetupDB points the model at a fresh in-memory database, which is closed when the test ends
+func SetupDB(t testing.TB) {
+	t.Helper()
+	sqlDB, err := model.OpenMemoryDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.RedisEnabled = false
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+}
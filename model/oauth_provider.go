@@ -0,0 +1,180 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/encryption"
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+const (
+	OAuthProviderStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	OAuthProviderStatusDisabled = 2 // also don't use 0
+)
+
+var oauthProviderNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// OAuthProvider is a login provider speaking the standard OAuth2 authorization code flow,
+// the claims are dotted paths into the userinfo response, e.g. "data.user.id"
+type OAuthProvider struct {
+	Id                    int    `json:"id"`
+	Name                  string `json:"name" gorm:"type:varchar(32);uniqueIndex"` // used in the callback url, e.g. gitlab
+	DisplayName           string `json:"display_name"`
+	ClientId              string `json:"client_id"`
+	ClientSecret          string `json:"client_secret,omitempty" gorm:"type:text"` // encrypted like the channel keys, never returned
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	Scopes                string `json:"scopes" gorm:"default:''"` // space separated
+	UserIdClaim           string `json:"user_id_claim" gorm:"default:'sub'"`
+	UsernameClaim         string `json:"username_claim" gorm:"default:'preferred_username'"`
+	EmailClaim            string `json:"email_claim" gorm:"default:'email'"`
+	DisplayNameClaim      string `json:"display_name_claim" gorm:"default:'name'"`
+	GroupClaim            string `json:"group_claim" gorm:"default:''"`    // e.g. groups, the groups are not synced if empty
+	GroupMapping          string `json:"group_mapping" gorm:"type:text"`   // json map of claim value to user group, synced at each login
+	RoleMapping           string `json:"role_mapping" gorm:"type:text"`    // json map of claim value to role, synced at each login
+	RequiredGroup         string `json:"required_group" gorm:"default:''"` // the users without this claim value are refused and disabled
+	Status                int    `json:"status" gorm:"default:1"`
+	CreatedTime           int64  `json:"created_time" gorm:"bigint"`
+}
+
+// UserIdentity links a user to the subject of an external login provider
+type UserIdentity struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Provider    string `json:"provider" gorm:"type:varchar(32);uniqueIndex:idx_provider_subject"`
+	Subject     string `json:"subject" gorm:"type:varchar(128);uniqueIndex:idx_provider_subject"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func IsValidOAuthProviderName(name string) bool {
+	return oauthProviderNamePattern.MatchString(name)
+}
+
+// Validate checks the fields required by the authorization code flow
+func (provider *OAuthProvider) Validate() error {
+	if !IsValidOAuthProviderName(provider.Name) {
+		return errors.New("提供商标识只能包含小写字母、数字、下划线和连字符，且长度不超过 32")
+	}
+	if provider.ClientId == "" {
+		return errors.New("Client Id 不能为空")
+	}
+	for _, endpoint := range []string{provider.AuthorizationEndpoint, provider.TokenEndpoint, provider.UserinfoEndpoint} {
+		if !strings.HasPrefix(endpoint, "https://") && !strings.HasPrefix(endpoint, "http://") {
+			return fmt.Errorf("无效的端点：%s", endpoint)
+		}
+	}
+	if provider.UserIdClaim == "" {
+		return errors.New("用户 ID 字段不能为空")
+	}
+	for _, mapping := range []string{provider.GroupMapping, provider.RoleMapping} {
+		if mapping != "" && !json.Valid([]byte(mapping)) {
+			return errors.New("组映射必须是合法的 JSON")
+		}
+	}
+	return nil
+}
+
+func GetAllOAuthProviders() ([]*OAuthProvider, error) {
+	var providers []*OAuthProvider
+	err := DB.Omit("client_secret").Order("id").Find(&providers).Error
+	return providers, err
+}
+
+func GetEnabledOAuthProviders() ([]*OAuthProvider, error) {
+	var providers []*OAuthProvider
+	err := DB.Omit("client_secret").Where("status = ?", OAuthProviderStatusEnabled).Order("id").Find(&providers).Error
+	return providers, err
+}
+
+func GetOAuthProviderById(id int, selectAll bool) (*OAuthProvider, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	provider := OAuthProvider{Id: id}
+	var err error
+	if selectAll {
+		err = DB.First(&provider, "id = ?", id).Error
+	} else {
+		err = DB.Omit("client_secret").First(&provider, "id = ?", id).Error
+	}
+	return &provider, err
+}
+
+func GetEnabledOAuthProviderByName(name string) (*OAuthProvider, error) {
+	provider := OAuthProvider{}
+	err := DB.First(&provider, "name = ? and status = ?", name, OAuthProviderStatusEnabled).Error
+	return &provider, err
+}
+
+// GetClientSecret returns the decrypted client secret
+func (provider *OAuthProvider) GetClientSecret() (string, error) {
+	return encryption.Decrypt(provider.ClientSecret)
+}
+
+func (provider *OAuthProvider) Insert() error {
+	secret, err := encryption.Encrypt(provider.ClientSecret)
+	if err != nil {
+		return err
+	}
+	provider.ClientSecret = secret
+	provider.CreatedTime = helper.GetTimestamp()
+	return DB.Create(provider).Error
+}
+
+// Update keeps the client secret unless a new one is given, the name can't be changed as the identities refer to it
+func (provider *OAuthProvider) Update() error {
+	fields := []string{"display_name", "client_id", "authorization_endpoint", "token_endpoint", "userinfo_endpoint",
+		"scopes", "user_id_claim", "username_claim", "email_claim", "display_name_claim", "group_claim", "group_mapping",
+		"role_mapping", "required_group", "status"}
+	if provider.ClientSecret != "" {
+		secret, err := encryption.Encrypt(provider.ClientSecret)
+		if err != nil {
+			return err
+		}
+		provider.ClientSecret = secret
+		fields = append(fields, "client_secret")
+	}
+	return DB.Model(provider).Select(fields).Updates(provider).Error
+}
+
+func DeleteOAuthProviderById(id int) error {
+	provider, err := GetOAuthProviderById(id, false)
+	if err != nil {
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("provider = ?", provider.Name).Delete(&UserIdentity{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(provider).Error
+	})
+}
+
+func GetUserIdentity(provider string, subject string) (*UserIdentity, error) {
+	identity := UserIdentity{}
+	err := DB.First(&identity, "provider = ? and subject = ?", provider, subject).Error
+	return &identity, err
+}
+
+func GetUserIdentities(userId int) ([]*UserIdentity, error) {
+	var identities []*UserIdentity
+	err := DB.Where("user_id = ?", userId).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+func (identity *UserIdentity) Insert() error {
+	identity.CreatedTime = helper.GetTimestamp()
+	return DB.Create(identity).Error
+}
+
+func DeleteUserIdentity(userId int, id int) error {
+	return DB.Where("user_id = ? and id = ?", userId, id).Delete(&UserIdentity{}).Error
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/modelinfo"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
 	"strconv"
 	"strings"
 	"time"
@@ -29,9 +31,29 @@ func InitOptionMap() {
 	config.OptionMap["EmailVerificationEnabled"] = strconv.FormatBool(config.EmailVerificationEnabled)
 	config.OptionMap["GitHubOAuthEnabled"] = strconv.FormatBool(config.GitHubOAuthEnabled)
 	config.OptionMap["OidcEnabled"] = strconv.FormatBool(config.OidcEnabled)
+	config.OptionMap["OidcGroupClaim"] = config.OidcGroupClaim
+	config.OptionMap["OidcGroupMapping"] = config.OidcGroupMapping
+	config.OptionMap["OidcRoleMapping"] = config.OidcRoleMapping
+	config.OptionMap["OidcRequiredGroup"] = config.OidcRequiredGroup
+	config.OptionMap["LdapEnabled"] = strconv.FormatBool(config.LdapEnabled)
+	config.OptionMap["LdapStartTLSEnabled"] = strconv.FormatBool(config.LdapStartTLSEnabled)
+	config.OptionMap["LdapServerURL"] = config.LdapServerURL
+	config.OptionMap["LdapBindDN"] = config.LdapBindDN
+	config.OptionMap["LdapBindSecret"] = ""
+	config.OptionMap["LdapBaseDN"] = config.LdapBaseDN
+	config.OptionMap["LdapUserFilter"] = config.LdapUserFilter
+	config.OptionMap["LdapUsernameAttribute"] = config.LdapUsernameAttribute
+	config.OptionMap["LdapEmailAttribute"] = config.LdapEmailAttribute
+	config.OptionMap["LdapDisplayNameAttribute"] = config.LdapDisplayNameAttribute
+	config.OptionMap["LdapGroupAttribute"] = config.LdapGroupAttribute
+	config.OptionMap["LdapGroupMapping"] = config.LdapGroupMapping
+	config.OptionMap["LdapRoleMapping"] = config.LdapRoleMapping
+	config.OptionMap["ScimToken"] = ""
 	config.OptionMap["WeChatAuthEnabled"] = strconv.FormatBool(config.WeChatAuthEnabled)
 	config.OptionMap["TurnstileCheckEnabled"] = strconv.FormatBool(config.TurnstileCheckEnabled)
 	config.OptionMap["RegisterEnabled"] = strconv.FormatBool(config.RegisterEnabled)
+	config.OptionMap["RegisterMode"] = config.RegisterMode
+	config.OptionMap["AdminTwoFactorRequiredEnabled"] = strconv.FormatBool(config.AdminTwoFactorRequiredEnabled)
 	config.OptionMap["AutomaticDisableChannelEnabled"] = strconv.FormatBool(config.AutomaticDisableChannelEnabled)
 	config.OptionMap["AutomaticEnableChannelEnabled"] = strconv.FormatBool(config.AutomaticEnableChannelEnabled)
 	config.OptionMap["ApproximateTokenEnabled"] = strconv.FormatBool(config.ApproximateTokenEnabled)
@@ -70,6 +92,8 @@ func InitOptionMap() {
 	config.OptionMap["ModelRatio"] = billingratio.ModelRatio2JSONString()
 	config.OptionMap["GroupRatio"] = billingratio.GroupRatio2JSONString()
 	config.OptionMap["CompletionRatio"] = billingratio.CompletionRatio2JSONString()
+	config.OptionMap["ModelInfo"] = modelinfo.ModelInfo2JSONString()
+	config.OptionMap["GroupRateLimit"] = ratelimit.GroupRateLimit2JSONString()
 	config.OptionMap["TopUpLink"] = config.TopUpLink
 	config.OptionMap["ChatLink"] = config.ChatLink
 	config.OptionMap["QuotaPerUnit"] = strconv.FormatFloat(config.QuotaPerUnit, 'f', -1, 64)
@@ -133,12 +157,18 @@ func updateOptionMap(key string, value string) (err error) {
 			config.GitHubOAuthEnabled = boolValue
 		case "OidcEnabled":
 			config.OidcEnabled = boolValue
+		case "LdapEnabled":
+			config.LdapEnabled = boolValue
+		case "LdapStartTLSEnabled":
+			config.LdapStartTLSEnabled = boolValue
 		case "WeChatAuthEnabled":
 			config.WeChatAuthEnabled = boolValue
 		case "TurnstileCheckEnabled":
 			config.TurnstileCheckEnabled = boolValue
 		case "RegisterEnabled":
 			config.RegisterEnabled = boolValue
+		case "AdminTwoFactorRequiredEnabled":
+			config.AdminTwoFactorRequiredEnabled = boolValue
 		case "EmailDomainRestrictionEnabled":
 			config.EmailDomainRestrictionEnabled = boolValue
 		case "AutomaticDisableChannelEnabled":
@@ -191,6 +221,38 @@ func updateOptionMap(key string, value string) (err error) {
 		config.OidcTokenEndpoint = value
 	case "OidcUserinfoEndpoint":
 		config.OidcUserinfoEndpoint = value
+	case "OidcGroupClaim":
+		config.OidcGroupClaim = value
+	case "OidcGroupMapping":
+		config.OidcGroupMapping = value
+	case "OidcRoleMapping":
+		config.OidcRoleMapping = value
+	case "OidcRequiredGroup":
+		config.OidcRequiredGroup = value
+	case "LdapServerURL":
+		config.LdapServerURL = value
+	case "LdapBindDN":
+		config.LdapBindDN = value
+	case "LdapBindSecret":
+		config.LdapBindSecret = value
+	case "LdapBaseDN":
+		config.LdapBaseDN = value
+	case "LdapUserFilter":
+		config.LdapUserFilter = value
+	case "LdapUsernameAttribute":
+		config.LdapUsernameAttribute = value
+	case "LdapEmailAttribute":
+		config.LdapEmailAttribute = value
+	case "LdapDisplayNameAttribute":
+		config.LdapDisplayNameAttribute = value
+	case "LdapGroupAttribute":
+		config.LdapGroupAttribute = value
+	case "LdapGroupMapping":
+		config.LdapGroupMapping = value
+	case "LdapRoleMapping":
+		config.LdapRoleMapping = value
+	case "ScimToken":
+		config.ScimToken = value
 	case "Footer":
 		config.Footer = value
 	case "SystemName":
@@ -229,6 +291,10 @@ func updateOptionMap(key string, value string) (err error) {
 		err = billingratio.UpdateGroupRatioByJSONString(value)
 	case "CompletionRatio":
 		err = billingratio.UpdateCompletionRatioByJSONString(value)
+	case "ModelInfo":
+		err = modelinfo.UpdateModelInfoByJSONString(value)
+	case "GroupRateLimit":
+		err = ratelimit.UpdateGroupRateLimitByJSONString(value)
 	case "TopUpLink":
 		config.TopUpLink = value
 	case "ChatLink":
@@ -237,6 +303,8 @@ func updateOptionMap(key string, value string) (err error) {
 		config.ChannelDisableThreshold, _ = strconv.ParseFloat(value, 64)
 	case "QuotaPerUnit":
 		config.QuotaPerUnit, _ = strconv.ParseFloat(value, 64)
+	case "RegisterMode":
+		config.RegisterMode = value
 	case "Theme":
 		config.Theme = value
 	}
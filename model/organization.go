@@ -0,0 +1,354 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+const (
+	OrganizationStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	OrganizationStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	OrganizationRoleMember = "member"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleOwner  = "owner"
+)
+
+// Organization owns a quota pool, the tokens of its members can draw from the pool instead of their own quota
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"index"`
+	Status      int    `json:"status" gorm:"default:1"`
+	Quota       int64  `json:"quota" gorm:"bigint;default:0"`
+	UsedQuota   int64  `json:"used_quota" gorm:"bigint;default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_organization_member"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_organization_member;index"`
+	Username       string `json:"username" gorm:"->;-:migration"` // filled by GetOrganizationMembers
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	QuotaLimit     int64  `json:"quota_limit" gorm:"bigint;default:0"` // the most quota the member can draw from the pool, 0 means unlimited
+	UsedQuota      int64  `json:"used_quota" gorm:"bigint;default:0"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationInvitation is a single-use code to join the organization
+type OrganizationInvitation struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"index"`
+	Code           string `json:"code" gorm:"type:char(32);uniqueIndex"`
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	QuotaLimit     int64  `json:"quota_limit" gorm:"bigint;default:0"`
+	InviterId      int    `json:"inviter_id"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	ExpiredTime    int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+}
+
+type UserOrganization struct {
+	Organization
+	Role string `json:"role"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleMember, OrganizationRoleAdmin, OrganizationRoleOwner:
+		return true
+	}
+	return false
+}
+
+// IsOrganizationManager tells whether the role can manage the members of the organization
+func IsOrganizationManager(role string) bool {
+	return role == OrganizationRoleAdmin || role == OrganizationRoleOwner
+}
+
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	organization := Organization{
+		Name:        name,
+		Status:      OrganizationStatusEnabled,
+		CreatedTime: helper.GetTimestamp(),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&organization).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: organization.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    helper.GetTimestamp(),
+		}).Error
+	})
+	return &organization, err
+}
+
+func GetAllOrganizations(startIdx int, num int) ([]*Organization, error) {
+	var organizations []*Organization
+	err := DB.Order("id desc").Limit(num).Offset(startIdx).Find(&organizations).Error
+	return organizations, err
+}
+
+func GetUserOrganizations(userId int) ([]*UserOrganization, error) {
+	var organizations []*UserOrganization
+	err := DB.Table("organizations").
+		Select("organizations.*, organization_members.role").
+		Joins("join organization_members on organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", userId).
+		Order("organizations.id desc").Scan(&organizations).Error
+	return organizations, err
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	organization := Organization{Id: id}
+	err := DB.First(&organization, "id = ?", id).Error
+	return &organization, err
+}
+
+func (organization *Organization) Update() error {
+	return DB.Model(organization).Select("name", "status").Updates(organization).Error
+}
+
+func DeleteOrganizationById(id int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("organization_id = ?", id).Delete(&OrganizationInvitation{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("organization_id = ?", id).Delete(&OrganizationMember{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{Id: id}).Error
+	})
+}
+
+func GetOrganizationMember(organizationId int, userId int) (*OrganizationMember, error) {
+	member := OrganizationMember{}
+	err := DB.Where("organization_id = ? and user_id = ?", organizationId, userId).First(&member).Error
+	return &member, err
+}
+
+func GetOrganizationMembers(organizationId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	err := DB.Model(&OrganizationMember{}).
+		Select("organization_members.*, users.username").
+		Joins("left join users on users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ?", organizationId).
+		Order("organization_members.id").Find(&members).Error
+	return members, err
+}
+
+func (member *OrganizationMember) Update() error {
+	return DB.Model(member).Select("role", "quota_limit").Updates(member).Error
+}
+
+func DeleteOrganizationMember(organizationId int, userId int) error {
+	return DB.Where("organization_id = ? and user_id = ?", organizationId, userId).Delete(&OrganizationMember{}).Error
+}
+
+func (invitation *OrganizationInvitation) Insert() error {
+	invitation.Code = random.GetUUID()
+	invitation.CreatedTime = helper.GetTimestamp()
+	return DB.Create(invitation).Error
+}
+
+func GetOrganizationInvitations(organizationId int) ([]*OrganizationInvitation, error) {
+	var invitations []*OrganizationInvitation
+	err := DB.Where("organization_id = ?", organizationId).Order("id desc").Find(&invitations).Error
+	return invitations, err
+}
+
+func DeleteOrganizationInvitation(organizationId int, id int) error {
+	return DB.Where("organization_id = ? and id = ?", organizationId, id).Delete(&OrganizationInvitation{}).Error
+}
+
+// AcceptOrganizationInvitation adds the user to the organization and uses up the invitation
+func AcceptOrganizationInvitation(code string, userId int) (*OrganizationMember, error) {
+	if code == "" {
+		return nil, errors.New("未提供邀请码")
+	}
+	member := &OrganizationMember{}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		invitation := OrganizationInvitation{}
+		err := tx.Where("code = ?", code).First(&invitation).Error
+		if err != nil {
+			return errors.New("无效的邀请码")
+		}
+		if invitation.ExpiredTime != -1 && invitation.ExpiredTime < helper.GetTimestamp() {
+			return errors.New("该邀请码已过期")
+		}
+		var count int64
+		err = tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", invitation.OrganizationId, userId).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("您已是该组织的成员")
+		}
+		member = &OrganizationMember{
+			OrganizationId: invitation.OrganizationId,
+			UserId:         userId,
+			Role:           invitation.Role,
+			QuotaLimit:     invitation.QuotaLimit,
+			CreatedTime:    helper.GetTimestamp(),
+		}
+		err = tx.Create(member).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&invitation).Error
+	})
+	return member, err
+}
+
+func GetOrganizationQuota(id int) (quota int64, err error) {
+	err = DB.Model(&Organization{}).Where("id = ?", id).Select("quota").Find(&quota).Error
+	return quota, err
+}
+
+func IncreaseOrganizationQuota(id int, quota int64) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return DB.Model(&Organization{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
+}
+
+// TransferQuotaToOrganization moves quota of the user into the pool of the organization
+func TransferQuotaToOrganization(userId int, organizationId int, quota int64) error {
+	if quota <= 0 {
+		return errors.New("额度必须大于 0")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? and quota >= ?", userId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户额度不足")
+		}
+		return tx.Model(&Organization{}).Where("id = ?", organizationId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+}
+
+// CheckOrganizationQuota returns an error if the member can't draw quota from the pool,
+// quota 0 only checks whether the pool or the cap of the member is used up
+func CheckOrganizationQuota(organizationId int, userId int, quota int64) error {
+	organization, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return errors.New("组织不存在")
+	}
+	if organization.Status != OrganizationStatusEnabled {
+		return errors.New("该组织已被禁用")
+	}
+	member, err := GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return errors.New("用户不是该组织的成员")
+	}
+	if organization.Quota <= 0 || organization.Quota < quota {
+		return fmt.Errorf("组织 %s 额度不足", organization.Name)
+	}
+	if member.QuotaLimit > 0 {
+		if remain := member.QuotaLimit - member.UsedQuota; remain <= 0 || remain < quota {
+			return errors.New("已达到组织成员额度上限")
+		}
+	}
+	return nil
+}
+
+// drawOrganizationQuota draws quota from the pool on behalf of the member only if the pool and the cap of the member
+// still cover it, the conditions are part of the updates so that concurrent requests can't overdraw
+func drawOrganizationQuota(organizationId int, userId int, quota int64) error {
+	if quota <= 0 {
+		return consumeOrganizationQuota(organizationId, userId, quota)
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Organization{}).Where("id = ? and status = ? and quota >= ?", organizationId, OrganizationStatusEnabled, quota).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", quota),
+			"used_quota": gorm.Expr("used_quota + ?", quota),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("组织额度不足")
+		}
+		result = tx.Model(&OrganizationMember{}).
+			Where("organization_id = ? and user_id = ? and (quota_limit = 0 or quota_limit - used_quota >= ?)", organizationId, userId, quota).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("已达到组织成员额度上限")
+		}
+		return nil
+	})
+}
+
+// consumeOrganizationQuota draws quota from the pool on behalf of the member, negative quota gives it back
+func consumeOrganizationQuota(organizationId int, userId int, quota int64) error {
+	if quota == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Organization{}).Where("id = ?", organizationId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", quota),
+			"used_quota": gorm.Expr("used_quota + ?", quota),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", organizationId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+	})
+}
+
+// CacheGetPayerQuota returns the quota a request draws from, the pool of the organization if any, otherwise the user's
+func CacheGetPayerQuota(ctx context.Context, userId int, organizationId int) (int64, error) {
+	if organizationId != 0 {
+		return GetOrganizationQuota(organizationId)
+	}
+	return CacheGetUserQuota(ctx, userId)
+}
+
+func CacheDecreasePayerQuota(userId int, organizationId int, quota int64) error {
+	if organizationId != 0 {
+		// the pool of the organization is not cached
+		return nil
+	}
+	return CacheDecreaseUserQuota(userId, quota)
+}
+
+// GetOrganizationLogs returns the consume logs charged to the organization, userId 0 means all the members
+func GetOrganizationLogs(organizationId int, userId int, startTimestamp int64, endTimestamp int64, modelName string, startIdx int, num int) (logs []*Log, err error) {
+	tx := LOG_DB.Where("organization_id = ? and type = ?", organizationId, LogTypeConsume)
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	return logs, err
+}
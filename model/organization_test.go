@@ -0,0 +1,65 @@
+package model
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDrawOrganizationQuota(t *testing.T) {
+	Convey("draw quota from the pool of an organization", t, func() {
+		setupTestDB(t)
+		organization, err := CreateOrganization("acme", 1)
+		So(err, ShouldBeNil)
+		So(IncreaseOrganizationQuota(organization.Id, 100), ShouldBeNil)
+		member := &OrganizationMember{OrganizationId: organization.Id, UserId: 2, Role: OrganizationRoleMember, QuotaLimit: 30}
+		So(DB.Create(member).Error, ShouldBeNil)
+
+		Convey("the pool and the member are charged", func() {
+			So(drawOrganizationQuota(organization.Id, 1, 40), ShouldBeNil)
+			quota, _ := GetOrganizationQuota(organization.Id)
+			So(quota, ShouldEqual, 60)
+			owner, _ := GetOrganizationMember(organization.Id, 1)
+			So(owner.UsedQuota, ShouldEqual, 40)
+		})
+		Convey("the pool can't be overdrawn", func() {
+			So(drawOrganizationQuota(organization.Id, 1, 101), ShouldNotBeNil)
+			quota, _ := GetOrganizationQuota(organization.Id)
+			So(quota, ShouldEqual, 100)
+		})
+		Convey("the cap of the member rolls the pool back", func() {
+			So(drawOrganizationQuota(organization.Id, 2, 20), ShouldBeNil)
+			So(drawOrganizationQuota(organization.Id, 2, 20), ShouldNotBeNil)
+			quota, _ := GetOrganizationQuota(organization.Id)
+			So(quota, ShouldEqual, 80)
+			member, _ = GetOrganizationMember(organization.Id, 2)
+			So(member.UsedQuota, ShouldEqual, 20)
+		})
+		Convey("a disabled organization can't be drawn from", func() {
+			organization.Status = OrganizationStatusDisabled
+			So(organization.Update(), ShouldBeNil)
+			So(drawOrganizationQuota(organization.Id, 1, 10), ShouldNotBeNil)
+		})
+		Convey("concurrent draws don't overdraw the pool", func() {
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			succeeded := 0
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if drawOrganizationQuota(organization.Id, 1, 30) == nil {
+						mu.Lock()
+						succeeded++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			So(succeeded, ShouldEqual, 3)
+			quota, _ := GetOrganizationQuota(organization.Id)
+			So(quota, ShouldEqual, 10)
+		})
+	})
+}
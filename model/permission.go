@@ -0,0 +1,225 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	PermissionChannelRead        = "channel.read"
+	PermissionChannelWrite       = "channel.write" // also grants testing the channels and updating their balance
+	PermissionUserRead           = "user.read"
+	PermissionUserManage         = "user.manage"
+	PermissionLogRead            = "log.read"
+	PermissionLogDelete          = "log.delete"
+	PermissionAuditLogRead       = "audit_log.read"
+	PermissionRedemptionRead     = "redemption.read"
+	PermissionRedemptionCreate   = "redemption.create" // also grants updating and deleting the codes
+	PermissionSigningKeyManage   = "signing_key.manage"
+	PermissionOrganizationManage = "organization.manage"
+	PermissionOptionManage       = "option.manage"
+	PermissionRoleManage         = "role.manage"
+)
+
+var AllPermissions = []string{
+	PermissionChannelRead,
+	PermissionChannelWrite,
+	PermissionUserRead,
+	PermissionUserManage,
+	PermissionLogRead,
+	PermissionLogDelete,
+	PermissionAuditLogRead,
+	PermissionRedemptionRead,
+	PermissionRedemptionCreate,
+	PermissionSigningKeyManage,
+	PermissionOrganizationManage,
+	PermissionOptionManage,
+	PermissionRoleManage,
+}
+
+// rootOnlyPermissions are not granted to the administrators by default
+var rootOnlyPermissions = map[string]bool{
+	PermissionOptionManage: true,
+	PermissionAuditLogRead: true,
+	PermissionRoleManage:   true,
+}
+
+// CustomRole is a named set of permissions, it grants the permissions to its users on top of their role level
+type CustomRole struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(32);uniqueIndex"`
+	Description string `json:"description" gorm:"default:''"`
+	Permissions string `json:"permissions" gorm:"type:text"` // comma separated, e.g. log.read,channel.read
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func IsValidPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePermissions checks the comma separated permissions and returns them normalized
+func ValidatePermissions(permissions string) (string, error) {
+	var valid []string
+	for _, permission := range strings.Split(permissions, ",") {
+		permission = strings.TrimSpace(permission)
+		if permission == "" {
+			continue
+		}
+		if !IsValidPermission(permission) {
+			return "", fmt.Errorf("无效的权限：%s", permission)
+		}
+		valid = append(valid, permission)
+	}
+	return strings.Join(valid, ","), nil
+}
+
+func (role *CustomRole) HasPermission(permission string) bool {
+	return hasPermission(role.Permissions, permission)
+}
+
+func hasPermission(permissions string, permission string) bool {
+	for _, p := range strings.Split(permissions, ",") {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func GetAllCustomRoles() ([]*CustomRole, error) {
+	var roles []*CustomRole
+	err := DB.Order("id").Find(&roles).Error
+	return roles, err
+}
+
+func GetCustomRoleById(id int) (*CustomRole, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	role := CustomRole{Id: id}
+	err := DB.First(&role, "id = ?", id).Error
+	return &role, err
+}
+
+func GetCustomRoleByName(name string) (*CustomRole, error) {
+	role := CustomRole{}
+	err := DB.First(&role, "name = ?", name).Error
+	return &role, err
+}
+
+func (role *CustomRole) Insert() error {
+	role.CreatedTime = helper.GetTimestamp()
+	return DB.Create(role).Error
+}
+
+func (role *CustomRole) Update() error {
+	err := DB.Model(role).Select("description", "permissions").Updates(role).Error
+	if err != nil {
+		return err
+	}
+	cacheDeleteUserCustomPermissions(getCustomRoleUserIds(role.Name)...)
+	return nil
+}
+
+func DeleteCustomRoleById(id int) error {
+	role, err := GetCustomRoleById(id)
+	if err != nil {
+		return err
+	}
+	userIds := getCustomRoleUserIds(role.Name)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("custom_role = ?", role.Name).Update("custom_role", "").Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+	cacheDeleteUserCustomPermissions(userIds...)
+	return nil
+}
+
+func getCustomRoleUserIds(name string) []int {
+	var userIds []int
+	err := DB.Model(&User{}).Where("custom_role = ?", name).Pluck("id", &userIds).Error
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to get users of custom role %s: %s", name, err.Error()))
+	}
+	return userIds
+}
+
+func SetUserCustomRole(userId int, name string) error {
+	if name != "" {
+		if _, err := GetCustomRoleByName(name); err != nil {
+			return fmt.Errorf("角色 %s 不存在", name)
+		}
+	}
+	err := DB.Model(&User{}).Where("id = ?", userId).Update("custom_role", name).Error
+	if err != nil {
+		return err
+	}
+	cacheDeleteUserCustomPermissions(userId)
+	return nil
+}
+
+func roleLevelHasPermission(role int, permission string) bool {
+	if role >= RoleRootUser {
+		return true
+	}
+	return role >= RoleAdminUser && !rootOnlyPermissions[permission]
+}
+
+// getUserCustomPermissions returns the permissions of the custom role of the user, empty if the user has none
+func getUserCustomPermissions(userId int) (string, error) {
+	var name string
+	err := DB.Model(&User{}).Where("id = ?", userId).Select("custom_role").Find(&name).Error
+	if err != nil || name == "" {
+		return "", err
+	}
+	customRole, err := GetCustomRoleByName(name)
+	if err != nil {
+		return "", err
+	}
+	return customRole.Permissions, nil
+}
+
+// HasPermission tells whether the user is granted the permission by the role level or the custom role
+func HasPermission(userId int, role int, permission string) bool {
+	if roleLevelHasPermission(role, permission) {
+		return true
+	}
+	customPermissions, err := CacheGetUserCustomPermissions(userId)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to get custom role of user %d: %s", userId, err.Error()))
+		return false
+	}
+	return hasPermission(customPermissions, permission)
+}
+
+// GetUserPermissions returns all the permissions granted to the user
+func GetUserPermissions(userId int, role int) []string {
+	customPermissions, err := CacheGetUserCustomPermissions(userId)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to get custom role of user %d: %s", userId, err.Error()))
+	}
+	var permissions []string
+	for _, permission := range AllPermissions {
+		if roleLevelHasPermission(role, permission) || hasPermission(customPermissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+const (
+	PersonalAccessTokenKeyPrefix = "pat-"
+	// PersonalAccessTokenScopeSelf grants the routes open to any logged in user, e.g. the own tokens and logs
+	PersonalAccessTokenScopeSelf = "self"
+
+	// the last used time is written at most once per this interval
+	personalAccessTokenTouchInterval = 60 // unit is second
+)
+
+// PersonalAccessToken is a named credential for the management api, limited to its scopes,
+// which are the permissions plus self, and never more than what its user is granted
+type PersonalAccessToken struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(64)"`
+	KeyHash      string `json:"-" gorm:"type:char(64);uniqueIndex"`
+	KeyPrefix    string `json:"key_prefix" gorm:"type:varchar(16)"`
+	Scopes       string `json:"scopes" gorm:"type:text"` // comma separated, e.g. channel.read,channel.write
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	ExpiredTime  int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint"`
+	LastUsedIp   string `json:"last_used_ip" gorm:"default:''"`
+}
+
+func IsPersonalAccessTokenKey(key string) bool {
+	return strings.HasPrefix(key, PersonalAccessTokenKeyPrefix)
+}
+
+// ValidateScopes checks the comma separated scopes and returns them normalized
+func ValidateScopes(scopes string) (string, error) {
+	var valid []string
+	for _, scope := range strings.Split(scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if scope != PersonalAccessTokenScopeSelf && !IsValidPermission(scope) {
+			return "", fmt.Errorf("无效的权限范围：%s", scope)
+		}
+		valid = append(valid, scope)
+	}
+	if len(valid) == 0 {
+		return "", errors.New("权限范围不能为空")
+	}
+	return strings.Join(valid, ","), nil
+}
+
+func (pat *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range strings.Split(pat.Scopes, ",") {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func GetUserPersonalAccessTokens(userId int) ([]*PersonalAccessToken, error) {
+	var pats []*PersonalAccessToken
+	err := DB.Where("user_id = ?", userId).Order("id desc").Find(&pats).Error
+	return pats, err
+}
+
+// Insert generates the key, which is returned only once, only its hash is stored
+func (pat *PersonalAccessToken) Insert() (string, error) {
+	key := PersonalAccessTokenKeyPrefix + random.GenerateKey()
+	pat.KeyHash = HashTokenKey(key)
+	pat.KeyPrefix = key[:len(PersonalAccessTokenKeyPrefix)+tokenKeyPrefixLength]
+	pat.CreatedTime = helper.GetTimestamp()
+	err := DB.Create(pat).Error
+	return key, err
+}
+
+func DeletePersonalAccessToken(userId int, id int) error {
+	result := DB.Where("user_id = ? and id = ?", userId, id).Delete(&PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("令牌不存在")
+	}
+	return nil
+}
+
+func DeleteUserPersonalAccessTokens(userId int) error {
+	return DB.Where("user_id = ?", userId).Delete(&PersonalAccessToken{}).Error
+}
+
+// ValidatePersonalAccessToken returns the token and its user if the token is not expired
+func ValidatePersonalAccessToken(key string, ip string) (*PersonalAccessToken, *User, error) {
+	pat := &PersonalAccessToken{}
+	err := DB.First(pat, "key_hash = ?", HashTokenKey(key)).Error
+	if err != nil {
+		return nil, nil, errors.New("无效的访问令牌")
+	}
+	now := helper.GetTimestamp()
+	if pat.ExpiredTime != -1 && pat.ExpiredTime < now {
+		return nil, nil, errors.New("访问令牌已过期")
+	}
+	user, err := GetUserById(pat.UserId, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if now-pat.LastUsedTime > personalAccessTokenTouchInterval || pat.LastUsedIp != ip {
+		pat.LastUsedTime = now
+		pat.LastUsedIp = ip
+		err = DB.Model(pat).Select("last_used_time", "last_used_ip").Updates(pat).Error
+		if err != nil {
+			logger.SysError("failed to update the last used time of the access token: " + err.Error())
+		}
+	}
+	return pat, user, nil
+}
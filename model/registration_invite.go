@@ -0,0 +1,81 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// RegistrationInvite is a single-use code to register, the new user is put in its group and granted its quota,
+// it is kept after use so that the admins can tell who registered with it
+type RegistrationInvite struct {
+	Id          int    `json:"id"`
+	Code        string `json:"code" gorm:"type:char(32);uniqueIndex"`
+	Remark      string `json:"remark" gorm:"default:''"` // e.g. the partner it is sent to
+	Group       string `json:"group" gorm:"type:varchar(32);default:'default'"`
+	Quota       int64  `json:"quota" gorm:"bigint;default:0"`
+	CreatorId   int    `json:"creator_id"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	ExpiredTime int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	UsedUserId  int    `json:"used_user_id" gorm:"default:0"`
+	UsedTime    int64  `json:"used_time" gorm:"bigint;default:0"`
+}
+
+func GetAllRegistrationInvites(startIdx int, num int) ([]*RegistrationInvite, error) {
+	var invites []*RegistrationInvite
+	err := DB.Order("id desc").Limit(num).Offset(startIdx).Find(&invites).Error
+	return invites, err
+}
+
+func (invite *RegistrationInvite) Insert() error {
+	invite.Code = random.GetUUID()
+	invite.CreatedTime = helper.GetTimestamp()
+	return DB.Create(invite).Error
+}
+
+func DeleteRegistrationInviteById(id int) error {
+	result := DB.Delete(&RegistrationInvite{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("邀请不存在")
+	}
+	return nil
+}
+
+// ClaimRegistrationInvite marks the invite as used before the user is created, so that it can't be used twice,
+// ReleaseRegistrationInvite gives it back if the registration fails
+func ClaimRegistrationInvite(code string) (*RegistrationInvite, error) {
+	if code == "" {
+		return nil, errors.New("管理员开启了邀请注册，请通过邀请链接注册")
+	}
+	invite := &RegistrationInvite{}
+	err := DB.Where("code = ?", code).First(invite).Error
+	if err != nil {
+		return nil, errors.New("无效的邀请码")
+	}
+	now := helper.GetTimestamp()
+	if invite.ExpiredTime != -1 && invite.ExpiredTime < now {
+		return nil, errors.New("该邀请码已过期")
+	}
+	result := DB.Model(&RegistrationInvite{}).Where("id = ? and used_time = 0", invite.Id).Update("used_time", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("该邀请码已被使用")
+	}
+	invite.UsedTime = now
+	return invite, nil
+}
+
+func ReleaseRegistrationInvite(id int) error {
+	return DB.Model(&RegistrationInvite{}).Where("id = ? and used_user_id = 0", id).Update("used_time", 0).Error
+}
+
+func (invite *RegistrationInvite) SetUsedUser(userId int) error {
+	invite.UsedUserId = userId
+	return DB.Model(invite).Update("used_user_id", userId).Error
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
+)
+
+// RelayTokenClaims are the claims of a short-lived relay jwt, the kid header is the id of the signing key.
+// The jwt acts as its parent token, the subject is the end user, models and quota narrow the parent token.
+type RelayTokenClaims struct {
+	TokenId int      `json:"tid"`
+	Models  []string `json:"models,omitempty"`
+	Quota   int64    `json:"quota,omitempty"`
+	jwt.StandardClaims
+
+	signingKeyId int
+}
+
+// IsRelayJWT tells a jwt from the sk- keys, which never contain dots
+func IsRelayJWT(key string) bool {
+	return strings.Count(key, ".") == 2
+}
+
+// ValidateRelayJWT verifies the jwt with the signing key of its kid header and returns the parent token
+func ValidateRelayJWT(raw string) (*Token, *RelayTokenClaims, error) {
+	claims := &RelayTokenClaims{}
+	var signingKey *SigningKey
+	_, err := jwt.ParseWithClaims(raw, claims, func(parsed *jwt.Token) (interface{}, error) {
+		kid, _ := parsed.Header["kid"].(string)
+		id, err := strconv.Atoi(kid)
+		if err != nil {
+			return nil, errors.New("invalid kid")
+		}
+		key, err := CacheGetSigningKeyById(id)
+		if err != nil {
+			return nil, err
+		}
+		if key.Status != SigningKeyStatusEnabled {
+			return nil, errors.New("signing key disabled")
+		}
+		// the algorithm is pinned by the key, otherwise a public key could be used as a HS256 secret
+		if parsed.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected algorithm %s", parsed.Method.Alg())
+		}
+		signingKey = key
+		return key.verificationKey()
+	})
+	if err != nil {
+		logger.SysLog("invalid relay jwt: " + err.Error())
+		return nil, nil, errors.New("无效的 JWT")
+	}
+	now := time.Now().Unix()
+	if claims.ExpiresAt == 0 {
+		return nil, nil, errors.New("JWT 缺少过期时间")
+	}
+	if claims.ExpiresAt-now > int64(config.RelayJWTMaxLifetime) {
+		return nil, nil, fmt.Errorf("JWT 有效期不能超过 %d 秒", config.RelayJWTMaxLifetime)
+	}
+	token, err := GetTokenById(claims.TokenId)
+	if err != nil || token.UserId != signingKey.UserId {
+		return nil, nil, errors.New("JWT 的父令牌无效")
+	}
+	err = validateTokenStatus(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.Id == "" {
+		// jwts sharing a jti share the quota cap, otherwise every jwt has its own
+		sum := sha256.Sum256([]byte(raw))
+		claims.Id = hex.EncodeToString(sum[:16])
+	}
+	claims.signingKeyId = signingKey.Id
+	return token, claims, nil
+}
+
+// QuotaCap caps the quota consumed by the jwt, the usage is forgotten after it expires
+func (claims *RelayTokenClaims) QuotaCap() ratelimit.QuotaCap {
+	return ratelimit.QuotaCap{
+		Name:       "JWT ",
+		Subject:    fmt.Sprintf("jwt:%d:%s", claims.signingKeyId, claims.Id),
+		Limit:      claims.Quota,
+		Expiration: time.Until(time.Unix(claims.ExpiresAt, 0)) + time.Minute,
+	}
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
+)
+
+func TestValidateRelayJWT(t *testing.T) {
+	Convey("ValidateRelayJWT", t, func() {
+		setupTestDB(t)
+		createUser := func(username string) *User {
+			user := &User{Username: username, AffCode: username, AccessToken: username}
+			So(DB.Create(user).Error, ShouldBeNil)
+			return user
+		}
+		alice := createUser("alice")
+		bob := createUser("bob")
+		token := &Token{UserId: alice.Id, KeyHash: "alice", Name: "alice", Status: TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}
+		So(DB.Create(token).Error, ShouldBeNil)
+		otherToken := &Token{UserId: bob.Id, KeyHash: "bob", Name: "bob", Status: TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}
+		So(DB.Create(otherToken).Error, ShouldBeNil)
+
+		hmacKey := &SigningKey{UserId: alice.Id, Name: "hmac", Algorithm: SigningAlgorithmHS256, Secret: "hmac-secret", Status: SigningKeyStatusEnabled}
+		So(hmacKey.Insert(), ShouldBeNil)
+		rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+		publicKeyBytes, err := x509.MarshalPKIXPublicKey(&rsaPrivateKey.PublicKey)
+		So(err, ShouldBeNil)
+		publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes})
+		rsaKey := &SigningKey{UserId: alice.Id, Name: "rsa", Algorithm: SigningAlgorithmRS256, PublicKey: string(publicKeyPEM), Status: SigningKeyStatusEnabled}
+		So(rsaKey.Insert(), ShouldBeNil)
+
+		sign := func(method jwt.SigningMethod, kid string, secret interface{}, claims *RelayTokenClaims) string {
+			jwtToken := jwt.NewWithClaims(method, claims)
+			jwtToken.Header["kid"] = kid
+			raw, err := jwtToken.SignedString(secret)
+			So(err, ShouldBeNil)
+			return raw
+		}
+		newClaims := func(tokenId int) *RelayTokenClaims {
+			return &RelayTokenClaims{TokenId: tokenId, StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()}}
+		}
+		signHMAC := func(claims *RelayTokenClaims) string {
+			return sign(jwt.SigningMethodHS256, strconv.Itoa(hmacKey.Id), []byte("hmac-secret"), claims)
+		}
+
+		Convey("accepts a jwt signed with the key of its kid", func() {
+			raw := sign(jwt.SigningMethodRS256, strconv.Itoa(rsaKey.Id), rsaPrivateKey, newClaims(token.Id))
+			So(IsRelayJWT(raw), ShouldBeTrue)
+			parent, claims, err := ValidateRelayJWT(raw)
+			So(err, ShouldBeNil)
+			So(parent.Id, ShouldEqual, token.Id)
+			So(claims.TokenId, ShouldEqual, token.Id)
+			_, _, err = ValidateRelayJWT(signHMAC(newClaims(token.Id)))
+			So(err, ShouldBeNil)
+		})
+		Convey("pins the algorithm to the key", func() {
+			// the public key is no secret, it must not verify a HS256 jwt
+			raw := sign(jwt.SigningMethodHS256, strconv.Itoa(rsaKey.Id), publicKeyPEM, newClaims(token.Id))
+			_, _, err := ValidateRelayJWT(raw)
+			So(err, ShouldNotBeNil)
+			raw = sign(jwt.SigningMethodHS384, strconv.Itoa(hmacKey.Id), []byte("hmac-secret"), newClaims(token.Id))
+			_, _, err = ValidateRelayJWT(raw)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("refuses an unknown or disabled kid", func() {
+			for _, kid := range []string{"", "abc", "999"} {
+				_, _, err := ValidateRelayJWT(sign(jwt.SigningMethodHS256, kid, []byte("hmac-secret"), newClaims(token.Id)))
+				So(err, ShouldNotBeNil)
+			}
+			hmacKey.Status = SigningKeyStatusDisabled
+			So(hmacKey.Update(), ShouldBeNil)
+			_, _, err := ValidateRelayJWT(signHMAC(newClaims(token.Id)))
+			So(err, ShouldNotBeNil)
+		})
+		Convey("refuses a wrong signature", func() {
+			raw := sign(jwt.SigningMethodHS256, strconv.Itoa(hmacKey.Id), []byte("another-secret"), newClaims(token.Id))
+			_, _, err := ValidateRelayJWT(raw)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("requires a short expiration", func() {
+			claims := newClaims(token.Id)
+			claims.ExpiresAt = 0
+			_, _, err := ValidateRelayJWT(signHMAC(claims))
+			So(err.Error(), ShouldEqual, "JWT 缺少过期时间")
+			claims.ExpiresAt = time.Now().Unix() + int64(config.RelayJWTMaxLifetime) + 60
+			_, _, err = ValidateRelayJWT(signHMAC(claims))
+			So(err.Error(), ShouldContainSubstring, "JWT 有效期不能超过")
+			claims.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+			_, _, err = ValidateRelayJWT(signHMAC(claims))
+			So(err, ShouldNotBeNil)
+		})
+		Convey("refuses a parent token of another user", func() {
+			_, _, err := ValidateRelayJWT(signHMAC(newClaims(otherToken.Id)))
+			So(err.Error(), ShouldEqual, "JWT 的父令牌无效")
+			_, _, err = ValidateRelayJWT(signHMAC(newClaims(12345)))
+			So(err.Error(), ShouldEqual, "JWT 的父令牌无效")
+		})
+		Convey("refuses a disabled parent token", func() {
+			So(DB.Model(token).Update("status", TokenStatusDisabled).Error, ShouldBeNil)
+			_, _, err := ValidateRelayJWT(signHMAC(newClaims(token.Id)))
+			So(err, ShouldNotBeNil)
+		})
+		Convey("the jwts sharing a jti share the quota cap", func() {
+			endUsers := 0
+			validate := func(jti string, quota int64) ratelimit.QuotaCap {
+				claims := newClaims(token.Id)
+				claims.Id = jti
+				claims.Quota = quota
+				// a distinct end user makes distinct jwts
+				endUsers++
+				claims.Subject = "user-" + strconv.Itoa(endUsers)
+				_, validated, err := ValidateRelayJWT(signHMAC(claims))
+				So(err, ShouldBeNil)
+				return validated.QuotaCap()
+			}
+			first := validate("session-1", 100)
+			second := validate("session-1", 100)
+			So(first.Subject, ShouldEqual, second.Subject)
+			So(validate("", 100).Subject, ShouldNotEqual, validate("", 100).Subject)
+			So(validate("session-2", 100).Subject, ShouldNotEqual, first.Subject)
+
+			ctx := ratelimit.WithQuotaCap(context.Background(), first)
+			So(ratelimit.CheckQuotaCaps(ctx, 80), ShouldBeNil)
+			ratelimit.RecordQuota(ctx, 80)
+			ctx = ratelimit.WithQuotaCap(context.Background(), second)
+			So(ratelimit.CheckQuotaCaps(ctx, 10), ShouldBeNil)
+			So(ratelimit.CheckQuotaCaps(ctx, 30), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"github.com/songquanpeng/one-api/common"
+)
+
+// ScimIdentityProvider is the provider of the identities holding the userName given by the SCIM client,
+// since it is usually an email address and doesn't fit in the username
+const ScimIdentityProvider = "scim"
+
+func GetScimIdentities(startIdx int, num int) (identities []*UserIdentity, total int64, err error) {
+	query := DB.Model(&UserIdentity{}).Where("provider = ?", ScimIdentityProvider)
+	err = query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id").Limit(num).Offset(startIdx).Find(&identities).Error
+	return identities, total, err
+}
+
+// GetScimIdentityByUserName matches case-insensitively, as the userName attribute is not case exact
+func GetScimIdentityByUserName(userName string) (*UserIdentity, error) {
+	identity := UserIdentity{}
+	err := DB.First(&identity, "provider = ? and lower(subject) = lower(?)", ScimIdentityProvider, userName).Error
+	return &identity, err
+}
+
+func GetScimIdentityByUserId(userId int) (*UserIdentity, error) {
+	identity := UserIdentity{}
+	err := DB.First(&identity, "provider = ? and user_id = ?", ScimIdentityProvider, userId).Error
+	return &identity, err
+}
+
+func (identity *UserIdentity) UpdateSubject(subject string) error {
+	identity.Subject = subject
+	return DB.Model(identity).Update("subject", subject).Error
+}
+
+// GetScimUsersByGroup only returns the users provisioned through scim, the other accounts in the group are not managed by the client
+func GetScimUsersByGroup(group string) (users []*User, err error) {
+	groupCol := "users.`group`"
+	if common.UsingPostgreSQL {
+		groupCol = `users."group"`
+	}
+	err = DB.Omit("password").
+		Joins("join user_identities on user_identities.user_id = users.id and user_identities.provider = ?", ScimIdentityProvider).
+		Where(groupCol+" = ? and users.status != ?", group, UserStatusDeleted).Order("users.id").Find(&users).Error
+	return users, err
+}
+
+func SetUserGroup(userId int, group string) error {
+	return DB.Model(&User{}).Where("id = ?", userId).Update("group", group).Error
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// the last active time is written at most once per this interval, so that each request doesn't hit the database
+const userSessionTouchInterval = 60 // unit is second
+
+// UserSession is a dashboard login, the cookie only holds the session id,
+// so that the sessions can be listed and revoked
+type UserSession struct {
+	Id             int    `json:"id"`
+	SessionId      string `json:"-" gorm:"type:char(32);uniqueIndex"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	Ip             string `json:"ip" gorm:"default:''"`
+	UserAgent      string `json:"user_agent" gorm:"default:''"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	LastActiveTime int64  `json:"last_active_time" gorm:"bigint"`
+	ExpiredTime    int64  `json:"expired_time" gorm:"bigint"`
+}
+
+func userSessionCacheKey(sessionId string) string {
+	return fmt.Sprintf("user_session:%s", sessionId)
+}
+
+func CreateUserSession(userId int, ip string, userAgent string) (*UserSession, error) {
+	now := helper.GetTimestamp()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	userSession := &UserSession{
+		SessionId:      random.GetUUID(),
+		UserId:         userId,
+		Ip:             ip,
+		UserAgent:      userAgent,
+		CreatedTime:    now,
+		LastActiveTime: now,
+		ExpiredTime:    now + int64(config.SessionExpirationSeconds),
+	}
+	// the expired sessions are cleaned up when the user logs in again
+	err := DB.Where("user_id = ? and expired_time < ?", userId, now).Delete(&UserSession{}).Error
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Create(userSession).Error
+	return userSession, err
+}
+
+// ValidateUserSession returns the session if it is neither revoked nor expired
+func ValidateUserSession(sessionId string) (*UserSession, error) {
+	if sessionId == "" {
+		return nil, errors.New("会话不存在")
+	}
+	userSession := &UserSession{}
+	cached := false
+	if common.RedisEnabled {
+		sessionString, err := common.RedisGet(userSessionCacheKey(sessionId))
+		if err == nil && json.Unmarshal([]byte(sessionString), userSession) == nil {
+			cached = true
+		}
+	}
+	if !cached {
+		err := DB.First(userSession, "session_id = ?", sessionId).Error
+		if err != nil {
+			return nil, errors.New("会话不存在")
+		}
+	}
+	now := helper.GetTimestamp()
+	if userSession.ExpiredTime < now {
+		return nil, errors.New("会话已过期")
+	}
+	if cached {
+		return userSession, nil
+	}
+	if now-userSession.LastActiveTime > userSessionTouchInterval {
+		userSession.LastActiveTime = now
+		err := DB.Model(userSession).Update("last_active_time", now).Error
+		if err != nil {
+			logger.SysError("failed to update the last active time of the session: " + err.Error())
+		}
+	}
+	if common.RedisEnabled {
+		jsonBytes, err := json.Marshal(userSession)
+		if err == nil {
+			err = common.RedisSet(userSessionCacheKey(sessionId), string(jsonBytes), userSessionTouchInterval*time.Second)
+		}
+		if err != nil {
+			logger.SysError("Redis set user session error: " + err.Error())
+		}
+	}
+	return userSession, nil
+}
+
+func GetUserSessions(userId int) ([]*UserSession, error) {
+	var userSessions []*UserSession
+	err := DB.Where("user_id = ? and expired_time >= ?", userId, helper.GetTimestamp()).Order("last_active_time desc").Find(&userSessions).Error
+	return userSessions, err
+}
+
+func revokeUserSessions(userSessions []*UserSession) error {
+	if len(userSessions) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(userSessions))
+	for _, userSession := range userSessions {
+		ids = append(ids, userSession.Id)
+	}
+	err := DB.Where("id in (?)", ids).Delete(&UserSession{}).Error
+	if err != nil {
+		return err
+	}
+	if common.RedisEnabled {
+		for _, userSession := range userSessions {
+			err = common.RedisDel(userSessionCacheKey(userSession.SessionId))
+			if err != nil {
+				logger.SysError("Redis del user session error: " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// RevokeUserSession revokes one session of the user, the user id makes sure it is not someone else's
+func RevokeUserSession(userId int, id int) error {
+	var userSessions []*UserSession
+	err := DB.Where("user_id = ? and id = ?", userId, id).Find(&userSessions).Error
+	if err != nil {
+		return err
+	}
+	if len(userSessions) == 0 {
+		return errors.New("会话不存在")
+	}
+	return revokeUserSessions(userSessions)
+}
+
+func RevokeUserSessionBySessionId(sessionId string) error {
+	var userSessions []*UserSession
+	err := DB.Where("session_id = ?", sessionId).Find(&userSessions).Error
+	if err != nil {
+		return err
+	}
+	return revokeUserSessions(userSessions)
+}
+
+// RevokeUserSessions logs the user out everywhere, except the session with exceptSessionId if given
+func RevokeUserSessions(userId int, exceptSessionId string) error {
+	var userSessions []*UserSession
+	err := DB.Where("user_id = ? and session_id != ?", userId, exceptSessionId).Find(&userSessions).Error
+	if err != nil {
+		return err
+	}
+	return revokeUserSessions(userSessions)
+}
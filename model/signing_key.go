@@ -0,0 +1,117 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/songquanpeng/one-api/common/encryption"
+)
+
+const (
+	SigningKeyStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	SigningKeyStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	SigningAlgorithmHS256 = "HS256"
+	SigningAlgorithmRS256 = "RS256"
+	SigningAlgorithmES256 = "ES256"
+)
+
+// SigningKey verifies the relay jwts minted by a backend of the user,
+// the secret of HS256 is encrypted like the channel keys, RS256 and ES256 keep the public key only
+type SigningKey struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Name        string `json:"name" gorm:"index"`
+	Algorithm   string `json:"algorithm" gorm:"type:varchar(16)"`
+	Secret      string `json:"secret,omitempty" gorm:"type:text"` // only returned once on creation
+	PublicKey   string `json:"public_key" gorm:"type:text"`
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func IsValidSigningAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case SigningAlgorithmHS256, SigningAlgorithmRS256, SigningAlgorithmES256:
+		return true
+	}
+	return false
+}
+
+func GetAllSigningKeys(userId int, startIdx int, num int) ([]*SigningKey, error) {
+	var keys []*SigningKey
+	query := DB.Omit("secret").Order("id desc")
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	err := query.Limit(num).Offset(startIdx).Find(&keys).Error
+	return keys, err
+}
+
+func GetSigningKeyById(id int, selectAll bool) (*SigningKey, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	key := SigningKey{Id: id}
+	var err error
+	if selectAll {
+		err = DB.First(&key, "id = ?", id).Error
+	} else {
+		err = DB.Omit("secret").First(&key, "id = ?", id).Error
+	}
+	return &key, err
+}
+
+func (key *SigningKey) Insert() error {
+	if key.Algorithm == SigningAlgorithmHS256 {
+		secret, err := encryption.Encrypt(key.Secret)
+		if err != nil {
+			return err
+		}
+		key.Secret = secret
+	}
+	return DB.Create(key).Error
+}
+
+func (key *SigningKey) Update() error {
+	err := DB.Model(key).Select("name", "status").Updates(key).Error
+	cacheDeleteSigningKey(key.Id)
+	return err
+}
+
+func DeleteSigningKeyById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	err := DB.Delete(&SigningKey{Id: id}).Error
+	cacheDeleteSigningKey(id)
+	return err
+}
+
+// ValidateKeyMaterial checks that the public key of RS256 and ES256 can be parsed
+func (key *SigningKey) ValidateKeyMaterial() error {
+	if key.Algorithm == SigningAlgorithmHS256 {
+		return nil
+	}
+	_, err := key.verificationKey()
+	return err
+}
+
+func (key *SigningKey) verificationKey() (interface{}, error) {
+	switch key.Algorithm {
+	case SigningAlgorithmHS256:
+		secret, err := encryption.Decrypt(key.Secret)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(secret), nil
+	case SigningAlgorithmRS256:
+		return jwt.ParseRSAPublicKeyFromPEM([]byte(key.PublicKey))
+	case SigningAlgorithmES256:
+		return jwt.ParseECPublicKeyFromPEM([]byte(key.PublicKey))
+	}
+	return nil, fmt.Errorf("unsupported algorithm %s", key.Algorithm)
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/message"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
 )
 
 const (
@@ -21,19 +23,32 @@ const (
 )
 
 type Token struct {
-	Id             int     `json:"id"`
-	UserId         int     `json:"user_id"`
-	Key            string  `json:"key" gorm:"type:char(48);uniqueIndex"`
-	Status         int     `json:"status" gorm:"default:1"`
-	Name           string  `json:"name" gorm:"index" `
-	CreatedTime    int64   `json:"created_time" gorm:"bigint"`
-	AccessedTime   int64   `json:"accessed_time" gorm:"bigint"`
-	ExpiredTime    int64   `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
-	RemainQuota    int64   `json:"remain_quota" gorm:"bigint;default:0"`
-	UnlimitedQuota bool    `json:"unlimited_quota" gorm:"default:false"`
-	UsedQuota      int64   `json:"used_quota" gorm:"bigint;default:0"` // used quota
-	Models         *string `json:"models" gorm:"type:text"`            // allowed models
-	Subnet         *string `json:"subnet" gorm:"default:''"`           // allowed subnet
+	Id               int     `json:"id"`
+	UserId           int     `json:"user_id"`
+	Key              string  `json:"key,omitempty" gorm:"-"` // the plaintext key is only returned once on creation
+	KeyHash          string  `json:"-" gorm:"type:char(64);uniqueIndex"`
+	KeyPrefix        string  `json:"key_prefix" gorm:"type:varchar(16)"` // shown to tell the tokens apart
+	Status           int     `json:"status" gorm:"default:1"`
+	Name             string  `json:"name" gorm:"index" `
+	CreatedTime      int64   `json:"created_time" gorm:"bigint"`
+	AccessedTime     int64   `json:"accessed_time" gorm:"bigint"`
+	ExpiredTime      int64   `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	RemainQuota      int64   `json:"remain_quota" gorm:"bigint;default:0"`
+	UnlimitedQuota   bool    `json:"unlimited_quota" gorm:"default:false"`
+	UsedQuota        int64   `json:"used_quota" gorm:"bigint;default:0"`               // used quota
+	Models           *string `json:"models" gorm:"type:text"`                          // allowed models
+	Subnet           *string `json:"subnet" gorm:"default:''"`                         // allowed subnet
+	Endpoints        *string `json:"endpoints" gorm:"type:text"`                       // allowed endpoints, e.g. chat,embeddings
+	ContextFit       bool    `json:"context_fit" gorm:"default:false"`                 // trim the messages to fit the context window
+	RPMLimit         int     `json:"rpm_limit" gorm:"default:0"`                       // requests per minute, 0 means unlimited
+	TPMLimit         int     `json:"tpm_limit" gorm:"default:0"`                       // tokens per minute, 0 means unlimited
+	ConcurrencyLimit int     `json:"concurrency_limit" gorm:"default:0"`               // concurrent requests, 0 means unlimited
+	BudgetPeriod     string  `json:"budget_period" gorm:"type:varchar(16);default:''"` // daily, weekly or monthly
+	PeriodQuota      int64   `json:"period_quota" gorm:"bigint;default:0"`             // quota per budget period, 0 means unlimited
+	ModelQuotas      *string `json:"model_quotas" gorm:"type:text"`                    // json map of model name to quota per budget period
+	EndUserRPMLimit  int     `json:"end_user_rpm_limit" gorm:"default:0"`              // requests per minute of each end user, 0 means unlimited
+	EndUserQuota     int64   `json:"end_user_quota" gorm:"bigint;default:0"`           // quota of each end user per budget period, daily if no period
+	OrganizationId   int     `json:"organization_id" gorm:"index;default:0"`           // draws from the quota pool of the organization, 0 means the user's own quota
 }
 
 func GetAllUserTokens(userId int, startIdx int, num int, order string) ([]*Token, error) {
@@ -71,13 +86,21 @@ func ValidateUserToken(key string) (token *Token, err error) {
 		}
 		return nil, errors.New("令牌验证失败")
 	}
+	err = validateTokenStatus(token)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func validateTokenStatus(token *Token) error {
 	if token.Status == TokenStatusExhausted {
-		return nil, fmt.Errorf("令牌 %s（#%d）额度已用尽", token.Name, token.Id)
+		return fmt.Errorf("令牌 %s（#%d）额度已用尽", token.Name, token.Id)
 	} else if token.Status == TokenStatusExpired {
-		return nil, errors.New("该令牌已过期")
+		return errors.New("该令牌已过期")
 	}
 	if token.Status != TokenStatusEnabled {
-		return nil, errors.New("该令牌状态不可用")
+		return errors.New("该令牌状态不可用")
 	}
 	if token.ExpiredTime != -1 && token.ExpiredTime < helper.GetTimestamp() {
 		if !common.RedisEnabled {
@@ -87,7 +110,7 @@ func ValidateUserToken(key string) (token *Token, err error) {
 				logger.SysError("failed to update token status" + err.Error())
 			}
 		}
-		return nil, errors.New("该令牌已过期")
+		return errors.New("该令牌已过期")
 	}
 	if !token.UnlimitedQuota && token.RemainQuota <= 0 {
 		if !common.RedisEnabled {
@@ -98,9 +121,9 @@ func ValidateUserToken(key string) (token *Token, err error) {
 				logger.SysError("failed to update token status" + err.Error())
 			}
 		}
-		return nil, errors.New("该令牌额度已用尽")
+		return errors.New("该令牌额度已用尽")
 	}
-	return token, nil
+	return nil
 }
 
 func GetTokenByIds(id int, userId int) (*Token, error) {
@@ -125,6 +148,10 @@ func GetTokenById(id int) (*Token, error) {
 
 func (t *Token) Insert() error {
 	var err error
+	if t.Key != "" {
+		t.KeyHash = HashTokenKey(t.Key)
+		t.KeyPrefix = getTokenKeyPrefix(t.Key)
+	}
 	err = DB.Create(t).Error
 	return err
 }
@@ -132,7 +159,7 @@ func (t *Token) Insert() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (t *Token) Update() error {
 	var err error
-	err = DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet").Updates(t).Error
+	err = DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet", "endpoints", "context_fit", "rpm_limit", "tpm_limit", "concurrency_limit", "budget_period", "period_quota", "model_quotas", "end_user_rpm_limit", "end_user_quota", "organization_id").Updates(t).Error
 	return err
 }
 
@@ -144,7 +171,10 @@ func (t *Token) SelectUpdate() error {
 func (t *Token) Delete() error {
 	var err error
 	err = DB.Delete(t).Error
-	return err
+	if err != nil {
+		return err
+	}
+	return deleteTokenPeriodUsages(t.Id)
 }
 
 func (t *Token) GetModels() string {
@@ -157,6 +187,11 @@ func (t *Token) GetModels() string {
 	return *t.Models
 }
 
+// DisableUserTokens turns off all the enabled tokens of a user, e.g. when the user is offboarded
+func DisableUserTokens(userId int) error {
+	return DB.Model(&Token{}).Where("user_id = ? and status = ?", userId, TokenStatusEnabled).Update("status", TokenStatusDisabled).Error
+}
+
 func DeleteTokenById(id int, userId int) (err error) {
 	// Why we need userId here? In case user want to delete other's token.
 	if id == 0 || userId == 0 {
@@ -214,7 +249,7 @@ func decreaseTokenQuota(id int, quota int64) (err error) {
 	return err
 }
 
-func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
+func PreConsumeTokenQuota(ctx context.Context, tokenId int, quota int64, modelName string) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -225,6 +260,17 @@ func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 	if !token.UnlimitedQuota && token.RemainQuota < quota {
 		return errors.New("令牌额度不足")
 	}
+	err = CheckTokenBudget(token, modelName, quota)
+	if err != nil {
+		return err
+	}
+	err = ratelimit.CheckQuotaCaps(ctx, quota)
+	if err != nil {
+		return err
+	}
+	if token.OrganizationId != 0 {
+		return preConsumeOrganizationTokenQuota(ctx, token, quota, modelName)
+	}
 	userQuota, err := GetUserQuota(token.UserId)
 	if err != nil {
 		return err
@@ -275,16 +321,33 @@ func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 			return err
 		}
 	}
+	err = consumeTokenBudget(token, modelName, quota)
+	if err != nil {
+		rollbackTokenPreConsume(token, modelName, quota, false)
+		return err
+	}
 	err = DecreaseUserQuota(token.UserId, quota)
-	return err
+	if err != nil {
+		rollbackTokenPreConsume(token, modelName, quota, true)
+		return err
+	}
+	ratelimit.RecordQuota(ctx, quota)
+	return nil
 }
 
-func PostConsumeTokenQuota(tokenId int, quota int64) (err error) {
+func PostConsumeTokenQuota(ctx context.Context, tokenId int, quota int64, modelName string) (err error) {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
 		return err
 	}
-	if quota > 0 {
+	ratelimit.RecordQuota(ctx, quota)
+	err = consumeTokenBudget(token, modelName, quota)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to consume budget of token %d: %s", tokenId, err.Error()))
+	}
+	if token.OrganizationId != 0 {
+		err = consumeOrganizationQuota(token.OrganizationId, token.UserId, quota)
+	} else if quota > 0 {
 		err = DecreaseUserQuota(token.UserId, quota)
 	} else {
 		err = IncreaseUserQuota(token.UserId, -quota)
@@ -301,3 +364,45 @@ func PostConsumeTokenQuota(tokenId int, quota int64) (err error) {
 	}
 	return nil
 }
+
+// rollbackTokenPreConsume gives back the quota taken from the token and its budget when the pre-consumption fails halfway
+func rollbackTokenPreConsume(token *Token, modelName string, quota int64, budgetConsumed bool) {
+	if budgetConsumed {
+		err := consumeTokenBudget(token, modelName, -quota)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to roll back budget of token %d: %s", token.Id, err.Error()))
+		}
+	}
+	if !token.UnlimitedQuota {
+		err := IncreaseTokenQuota(token.Id, quota)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to roll back quota of token %d: %s", token.Id, err.Error()))
+		}
+	}
+}
+
+// preConsumeOrganizationTokenQuota draws the quota from the pool of the organization instead of the user
+func preConsumeOrganizationTokenQuota(ctx context.Context, token *Token, quota int64, modelName string) error {
+	err := CheckOrganizationQuota(token.OrganizationId, token.UserId, quota)
+	if err != nil {
+		return err
+	}
+	if !token.UnlimitedQuota {
+		err = DecreaseTokenQuota(token.Id, quota)
+		if err != nil {
+			return err
+		}
+	}
+	err = consumeTokenBudget(token, modelName, quota)
+	if err != nil {
+		rollbackTokenPreConsume(token, modelName, quota, false)
+		return err
+	}
+	err = drawOrganizationQuota(token.OrganizationId, token.UserId, quota)
+	if err != nil {
+		rollbackTokenPreConsume(token, modelName, quota, true)
+		return err
+	}
+	ratelimit.RecordQuota(ctx, quota)
+	return nil
+}
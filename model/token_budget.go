@@ -0,0 +1,175 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
+)
+
+const (
+	BudgetPeriodNone    = ""
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodWeekly  = "weekly"
+	BudgetPeriodMonthly = "monthly"
+)
+
+// TokenPeriodUsage is the quota used by a token in one budget period,
+// the row with empty model name is the usage of all models
+type TokenPeriodUsage struct {
+	Id          int    `json:"id"`
+	TokenId     int    `json:"token_id" gorm:"uniqueIndex:idx_token_period_usage"`
+	ModelName   string `json:"model_name" gorm:"type:varchar(255);default:'';uniqueIndex:idx_token_period_usage"`
+	PeriodStart int64  `json:"period_start" gorm:"bigint;uniqueIndex:idx_token_period_usage"`
+	UsedQuota   int64  `json:"used_quota" gorm:"bigint;default:0"`
+}
+
+func IsValidBudgetPeriod(period string) bool {
+	switch period {
+	case BudgetPeriodNone, BudgetPeriodDaily, BudgetPeriodWeekly, BudgetPeriodMonthly:
+		return true
+	}
+	return false
+}
+
+// GetBudgetPeriod returns the start and the end of the period containing now, in local time
+func GetBudgetPeriod(period string, now time.Time) (int64, int64) {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	switch period {
+	case BudgetPeriodDaily:
+		return today.Unix(), today.AddDate(0, 0, 1).Unix()
+	case BudgetPeriodWeekly:
+		// weeks start on Monday
+		start := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		return start.Unix(), start.AddDate(0, 0, 7).Unix()
+	case BudgetPeriodMonthly:
+		start := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		return start.Unix(), start.AddDate(0, 1, 0).Unix()
+	}
+	return 0, 0
+}
+
+// GetModelQuotas returns the quota of each model per period
+func (t *Token) GetModelQuotas() map[string]int64 {
+	if t.ModelQuotas == nil || *t.ModelQuotas == "" {
+		return nil
+	}
+	modelQuotas := make(map[string]int64)
+	err := json.Unmarshal([]byte(*t.ModelQuotas), &modelQuotas)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to unmarshal model quotas for token %d: %s", t.Id, err.Error()))
+		return nil
+	}
+	return modelQuotas
+}
+
+func (t *Token) HasBudget() bool {
+	return t.BudgetPeriod != BudgetPeriodNone && (t.PeriodQuota > 0 || len(t.GetModelQuotas()) > 0)
+}
+
+func getTokenPeriodUsedQuota(tokenId int, modelName string, periodStart int64) (int64, error) {
+	var usedQuota int64
+	err := DB.Model(&TokenPeriodUsage{}).
+		Where("token_id = ? and model_name = ? and period_start = ?", tokenId, modelName, periodStart).
+		Select("used_quota").Scan(&usedQuota).Error
+	return usedQuota, err
+}
+
+// GetTokenPeriodUsedQuota returns the quota used by the token in the current period
+func GetTokenPeriodUsedQuota(token *Token, modelName string) (int64, error) {
+	periodStart, _ := GetBudgetPeriod(token.BudgetPeriod, time.Now())
+	return getTokenPeriodUsedQuota(token.Id, modelName, periodStart)
+}
+
+// CheckTokenBudget returns an error if consuming quota on the model would exceed the budget of the current period,
+// quota 0 only checks whether the budget is used up
+func CheckTokenBudget(token *Token, modelName string, quota int64) error {
+	if !token.HasBudget() {
+		return nil
+	}
+	periodStart, periodEnd := GetBudgetPeriod(token.BudgetPeriod, time.Now())
+	resetTime := time.Unix(periodEnd, 0).Format("2006-01-02 15:04:05")
+	if token.PeriodQuota > 0 {
+		usedQuota, err := getTokenPeriodUsedQuota(token.Id, "", periodStart)
+		if err != nil {
+			return err
+		}
+		if remain := token.PeriodQuota - usedQuota; remain <= 0 || remain < quota {
+			return fmt.Errorf("令牌本周期额度不足，将于 %s 重置", resetTime)
+		}
+	}
+	if modelQuota, ok := token.GetModelQuotas()[modelName]; ok && modelName != "" {
+		usedQuota, err := getTokenPeriodUsedQuota(token.Id, modelName, periodStart)
+		if err != nil {
+			return err
+		}
+		if remain := modelQuota - usedQuota; remain <= 0 || remain < quota {
+			return fmt.Errorf("令牌本周期模型 %s 的额度不足，将于 %s 重置", modelName, resetTime)
+		}
+	}
+	return nil
+}
+
+// CheckTokenBudgetById checks the budget against the estimated quota of a request which is not pre-consumed,
+// e.g. of a user with plenty of quota, so that the budget is not only checked after it is exceeded
+func CheckTokenBudgetById(tokenId int, modelName string, quota int64) error {
+	token, err := GetTokenById(tokenId)
+	if err != nil {
+		return err
+	}
+	return CheckTokenBudget(token, modelName, quota)
+}
+
+func increaseTokenPeriodUsedQuota(tokenId int, modelName string, periodStart int64, quota int64) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token_id"}, {Name: "model_name"}, {Name: "period_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"used_quota": gorm.Expr("used_quota + ?", quota)}),
+	}).Create(&TokenPeriodUsage{
+		TokenId:     tokenId,
+		ModelName:   modelName,
+		PeriodStart: periodStart,
+		UsedQuota:   quota,
+	}).Error
+}
+
+// consumeTokenBudget adds the quota to the usage of the current period, negative quota gives it back
+func consumeTokenBudget(token *Token, modelName string, quota int64) error {
+	if quota == 0 || !token.HasBudget() {
+		return nil
+	}
+	periodStart, _ := GetBudgetPeriod(token.BudgetPeriod, time.Now())
+	err := increaseTokenPeriodUsedQuota(token.Id, "", periodStart, quota)
+	if err != nil {
+		return err
+	}
+	if modelName == "" {
+		return nil
+	}
+	return increaseTokenPeriodUsedQuota(token.Id, modelName, periodStart, quota)
+}
+
+func deleteTokenPeriodUsages(tokenId int) error {
+	return DB.Where("token_id = ?", tokenId).Delete(&TokenPeriodUsage{}).Error
+}
+
+// EndUserQuotaCap caps the quota of an end user of the token in the current budget period,
+// the period is daily if the token has none
+func (t *Token) EndUserQuotaCap(endUser string) ratelimit.QuotaCap {
+	period := t.BudgetPeriod
+	if period == BudgetPeriodNone {
+		period = BudgetPeriodDaily
+	}
+	periodStart, periodEnd := GetBudgetPeriod(period, time.Now())
+	return ratelimit.QuotaCap{
+		Name:       fmt.Sprintf("终端用户 %s 本周期", endUser),
+		Subject:    fmt.Sprintf("endUser:%d:%s:%d", t.Id, endUser, periodStart),
+		Limit:      t.EndUserQuota,
+		Expiration: time.Until(time.Unix(periodEnd, 0)) + time.Minute,
+	}
+}
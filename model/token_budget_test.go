@@ -0,0 +1,52 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+)
+
+func TestTokenBudget(t *testing.T) {
+	Convey("token budget", t, func() {
+		setupTestDB(t)
+		user := &User{Username: "budget", Quota: 1000, AffCode: "budget"}
+		So(DB.Create(user).Error, ShouldBeNil)
+		token := &Token{UserId: user.Id, KeyHash: "budget", Name: "budget", RemainQuota: 1000, BudgetPeriod: BudgetPeriodDaily, PeriodQuota: 100}
+		So(DB.Create(token).Error, ShouldBeNil)
+
+		Convey("the estimate of a request which is not pre-consumed is checked against the budget", func() {
+			So(CheckTokenBudgetById(token.Id, "gpt-4", 50), ShouldBeNil)
+			So(CheckTokenBudgetById(token.Id, "gpt-4", 150), ShouldNotBeNil)
+		})
+		Convey("pre-consumption beyond the budget is rejected", func() {
+			So(PreConsumeTokenQuota(context.Background(), token.Id, 60, "gpt-4"), ShouldBeNil)
+			So(PreConsumeTokenQuota(context.Background(), token.Id, 60, "gpt-4"), ShouldNotBeNil)
+			usedQuota, err := GetTokenPeriodUsedQuota(token, "")
+			So(err, ShouldBeNil)
+			So(usedQuota, ShouldEqual, 60)
+		})
+		Convey("the token and its budget are rolled back when the user quota can't be decreased", func() {
+			err := DB.Callback().Update().Before("gorm:update").Register("test:fail_user_update", func(tx *gorm.DB) {
+				if tx.Statement.Table == "users" {
+					_ = tx.AddError(errors.New("users is locked"))
+				}
+			})
+			So(err, ShouldBeNil)
+			defer DB.Callback().Update().Remove("test:fail_user_update")
+			So(PreConsumeTokenQuota(context.Background(), token.Id, 60, "gpt-4"), ShouldNotBeNil)
+			restored, err := GetTokenById(token.Id)
+			So(err, ShouldBeNil)
+			So(restored.RemainQuota, ShouldEqual, 1000)
+			So(restored.UsedQuota, ShouldEqual, 0)
+			usedQuota, err := GetTokenPeriodUsedQuota(token, "")
+			So(err, ShouldBeNil)
+			So(usedQuota, ShouldEqual, 0)
+			usedQuota, err = GetTokenPeriodUsedQuota(token, "gpt-4")
+			So(err, ShouldBeNil)
+			So(usedQuota, ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// tokenHashSecretOptionKey ends with Secret so it is never listed by the option api
+const tokenHashSecretOptionKey = "TokenHashSecret"
+
+// tokenKeyPrefixLength is the number of leading characters of a key kept for display
+const tokenKeyPrefixLength = 8
+
+// HashTokenKey returns the salted hash of a token key, which is what the database stores
+func HashTokenKey(key string) string {
+	mac := hmac.New(sha256.New, []byte(config.TokenHashSecret))
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func getTokenKeyPrefix(key string) string {
+	if len(key) <= tokenKeyPrefixLength {
+		return key
+	}
+	return key[:tokenKeyPrefixLength]
+}
+
+// initTokenHashSecret uses TOKEN_HASH_SECRET if set, otherwise a random secret generated by the master node
+// and kept in the options table, changing the secret invalidates all the tokens
+func initTokenHashSecret() {
+	if config.TokenHashSecret != "" {
+		return
+	}
+	option := Option{}
+	err := DB.Where(&Option{Key: tokenHashSecretOptionKey}).Limit(1).Find(&option).Error
+	if err != nil {
+		logger.FatalLog("failed to load token hash secret: " + err.Error())
+	}
+	if option.Value != "" {
+		config.TokenHashSecret = option.Value
+		return
+	}
+	if !config.IsMasterNode {
+		logger.FatalLog("token hash secret not found, please start the master node first or set TOKEN_HASH_SECRET")
+	}
+	option.Key = tokenHashSecretOptionKey
+	option.Value = random.GetRandomString(32)
+	err = DB.Save(&option).Error
+	if err != nil {
+		logger.FatalLog("failed to save token hash secret: " + err.Error())
+	}
+	config.TokenHashSecret = option.Value
+	logger.SysLog("token hash secret generated")
+}
+
+// migrateTokenKeys replaces the plaintext keys of the tokens created by older versions with their hashes
+func migrateTokenKeys() error {
+	if !DB.Migrator().HasColumn(&Token{}, "key") {
+		return nil
+	}
+	keyCol := "`key`"
+	if common.UsingPostgreSQL {
+		keyCol = `"key"`
+	}
+	type plainToken struct {
+		Id  int
+		Key string
+	}
+	var plainTokens []plainToken
+	err := DB.Table("tokens").Select("id, " + keyCol).
+		Where(keyCol + " is not null and " + keyCol + " <> ''").Find(&plainTokens).Error
+	if err != nil {
+		return err
+	}
+	if len(plainTokens) == 0 {
+		return nil
+	}
+	logger.SysLog(fmt.Sprintf("hashing the keys of %d tokens", len(plainTokens)))
+	for _, token := range plainTokens {
+		err = DB.Table("tokens").Where("id = ?", token.Id).Updates(map[string]interface{}{
+			"key_hash":   HashTokenKey(token.Key),
+			"key_prefix": getTokenKeyPrefix(token.Key),
+			"key":        nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
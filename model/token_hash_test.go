@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+func TestHashTokenKey(t *testing.T) {
+	Convey("hash token keys", t, func() {
+		secret := config.TokenHashSecret
+		defer func() { config.TokenHashSecret = secret }()
+		config.TokenHashSecret = "secret-a"
+		hash := HashTokenKey("abcdefghijkl")
+		So(len(hash), ShouldEqual, 64)
+		So(hash, ShouldEqual, HashTokenKey("abcdefghijkl"))
+		So(hash, ShouldNotEqual, HashTokenKey("abcdefghijkm"))
+		config.TokenHashSecret = "secret-b"
+		So(hash, ShouldNotEqual, HashTokenKey("abcdefghijkl"))
+		So(getTokenKeyPrefix("abcdefghijkl"), ShouldEqual, "abcdefgh")
+		So(getTokenKeyPrefix("abc"), ShouldEqual, "abc")
+	})
+}
+
+func TestMigrateTokenKeys(t *testing.T) {
+	Convey("migrate the plaintext keys of older versions", t, func() {
+		setupTestDB(t)
+		secret := config.TokenHashSecret
+		defer func() { config.TokenHashSecret = secret }()
+		config.TokenHashSecret = "migration"
+		So(DB.Exec("alter table tokens add column `key` char(48)").Error, ShouldBeNil)
+		So(DB.Exec("insert into tokens (user_id, name, `key`, remain_quota) values (1, 'old', 'legacykey0123456789', 100)").Error, ShouldBeNil)
+		So(DB.Exec("insert into tokens (user_id, name, key_hash, key_prefix) values (1, 'new', 'hashed', 'newkey01')").Error, ShouldBeNil)
+
+		So(migrateTokenKeys(), ShouldBeNil)
+		token, err := CacheGetTokenByKey("legacykey0123456789")
+		So(err, ShouldBeNil)
+		So(token.Name, ShouldEqual, "old")
+		So(token.KeyPrefix, ShouldEqual, "legacyke")
+		So(token.RemainQuota, ShouldEqual, 100)
+		var plainKeys int64
+		So(DB.Table("tokens").Where("`key` is not null and `key` <> ''").Count(&plainKeys).Error, ShouldBeNil)
+		So(plainKeys, ShouldEqual, 0)
+		var untouched Token
+		So(DB.Where("name = ?", "new").First(&untouched).Error, ShouldBeNil)
+		So(untouched.KeyHash, ShouldEqual, "hashed")
+		// the migration is idempotent
+		So(migrateTokenKeys(), ShouldBeNil)
+	})
+}
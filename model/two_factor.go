@@ -0,0 +1,248 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/encryption"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/common/totp"
+)
+
+const RecoveryCodeCount = 10
+
+// 32 characters, so that each random byte maps to one without bias
+const recoveryCodeChars = "abcdefghijklmnopqrstuvwxyz234567"
+
+const (
+	// the second step of the login has to be finished in time and within a few attempts
+	twoFactorChallengeTimeout     = 5 * 60 // unit is second
+	twoFactorChallengeMaxAttempts = 5
+)
+
+var ErrTwoFactorChallengeExpired = errors.New("两步验证已过期，请重新登录")
+
+// TwoFactorChallenge is the pending second step of a login, the cookie only holds the challenge id,
+// so that the client can't reset the attempts
+type TwoFactorChallenge struct {
+	Id          int    `json:"id"`
+	ChallengeId string `json:"-" gorm:"type:char(32);uniqueIndex"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Attempts    int    `json:"attempts" gorm:"default:0"`
+	ExpiredTime int64  `json:"expired_time" gorm:"bigint"`
+}
+
+func getTwoFactorUser(userId int) (*User, error) {
+	user := User{}
+	err := DB.Select("id", "username", "two_factor_enabled", "two_factor_secret", "two_factor_recovery_codes", "two_factor_last_step").
+		First(&user, "id = ?", userId).Error
+	return &user, err
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes returns the plaintext codes and their hashes to store
+func generateRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code := make([]byte, 10)
+		if _, err := rand.Read(code); err != nil {
+			return nil, "", err
+		}
+		for j := range code {
+			code[j] = recoveryCodeChars[int(code[j])%len(recoveryCodeChars)]
+		}
+		codes[i] = string(code[:5]) + "-" + string(code[5:])
+		hashes[i] = hashRecoveryCode(string(code))
+	}
+	return codes, strings.Join(hashes, ","), nil
+}
+
+// SetupTwoFactor generates a new secret for the user, it takes effect after EnableTwoFactor confirms a code of it
+func SetupTwoFactor(userId int) (secret string, uri string, err error) {
+	user, err := getTwoFactorUser(userId)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TwoFactorEnabled {
+		return "", "", errors.New("已启用两步验证")
+	}
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := encryption.Encrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+	err = DB.Model(&User{}).Where("id = ?", userId).Update("two_factor_secret", encrypted).Error
+	if err != nil {
+		return "", "", err
+	}
+	return secret, totp.ProvisioningURI(config.SystemName, user.Username, secret), nil
+}
+
+// EnableTwoFactor turns on two-factor authentication once the user proves the secret is saved,
+// the recovery codes are only returned here
+func EnableTwoFactor(userId int, code string) ([]string, error) {
+	user, err := getTwoFactorUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, errors.New("已启用两步验证")
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, errors.New("请先获取两步验证密钥")
+	}
+	secret, err := encryption.Decrypt(user.TwoFactorSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return nil, errors.New("验证码错误")
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Model(&User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"two_factor_enabled":        true,
+		"two_factor_recovery_codes": hashes,
+		"two_factor_last_step":      step,
+	}).Error
+	return codes, err
+}
+
+func DisableTwoFactor(userId int) error {
+	return DB.Model(&User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"two_factor_enabled":        false,
+		"two_factor_secret":         "",
+		"two_factor_recovery_codes": "",
+		"two_factor_last_step":      0,
+	}).Error
+}
+
+func RegenerateRecoveryCodes(userId int) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Model(&User{}).Where("id = ? and two_factor_enabled = ?", userId, true).Update("two_factor_recovery_codes", hashes).Error
+	return codes, err
+}
+
+// VerifyTwoFactor accepts either a totp code or an unused recovery code, which is used up,
+// the updates are conditional so that a code can't be used twice by concurrent requests
+func VerifyTwoFactor(userId int, code string) error {
+	user, err := getTwoFactorUser(userId)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return errors.New("未启用两步验证")
+	}
+	secret, err := encryption.Decrypt(user.TwoFactorSecret)
+	if err != nil {
+		return err
+	}
+	if step, ok := totp.Validate(secret, code, time.Now()); ok {
+		result := DB.Model(&User{}).Where("id = ? and two_factor_last_step < ?", userId, step).Update("two_factor_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("验证码已使用，请等待下一个验证码")
+		}
+		return nil
+	}
+	hash := hashRecoveryCode(code)
+	var remaining []string
+	found := false
+	for _, h := range strings.Split(user.TwoFactorRecoveryCodes, ",") {
+		if h == hash {
+			found = true
+			continue
+		}
+		if h != "" {
+			remaining = append(remaining, h)
+		}
+	}
+	if !found {
+		return errors.New("验证码错误")
+	}
+	result := DB.Model(&User{}).Where("id = ? and two_factor_recovery_codes = ?", userId, user.TwoFactorRecoveryCodes).
+		Update("two_factor_recovery_codes", strings.Join(remaining, ","))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("验证码错误")
+	}
+	return nil
+}
+
+func CreateTwoFactorChallenge(userId int) (*TwoFactorChallenge, error) {
+	now := helper.GetTimestamp()
+	// the expired challenges are cleaned up when a new one is created
+	err := DB.Where("expired_time < ?", now).Delete(&TwoFactorChallenge{}).Error
+	if err != nil {
+		return nil, err
+	}
+	challenge := &TwoFactorChallenge{
+		ChallengeId: random.GetUUID(),
+		UserId:      userId,
+		ExpiredTime: now + twoFactorChallengeTimeout,
+	}
+	err = DB.Create(challenge).Error
+	return challenge, err
+}
+
+// VerifyTwoFactorChallenge checks the code of the pending login and returns its user, the attempt is counted
+// before the code is checked so that concurrent requests can't exceed the attempts, the challenge is used up on success
+func VerifyTwoFactorChallenge(challengeId string, code string) (int, error) {
+	if challengeId == "" {
+		return 0, ErrTwoFactorChallengeExpired
+	}
+	challenge := TwoFactorChallenge{}
+	err := DB.First(&challenge, "challenge_id = ?", challengeId).Error
+	if err != nil {
+		return 0, ErrTwoFactorChallengeExpired
+	}
+	result := DB.Model(&TwoFactorChallenge{}).
+		Where("id = ? and attempts < ? and expired_time >= ?", challenge.Id, twoFactorChallengeMaxAttempts, helper.GetTimestamp()).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		_ = DB.Delete(&challenge).Error
+		return 0, ErrTwoFactorChallengeExpired
+	}
+	err = VerifyTwoFactor(challenge.UserId, code)
+	if err != nil {
+		return 0, err
+	}
+	result = DB.Delete(&TwoFactorChallenge{}, "id = ?", challenge.Id)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrTwoFactorChallengeExpired
+	}
+	return challenge.UserId, nil
+}
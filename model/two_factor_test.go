@@ -0,0 +1,73 @@
+package model
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/totp"
+)
+
+var recoveryCodePattern = regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	Convey("generate recovery codes", t, func() {
+		codes, hashes, err := generateRecoveryCodes()
+		So(err, ShouldBeNil)
+		So(len(codes), ShouldEqual, RecoveryCodeCount)
+		seen := make(map[string]bool)
+		for _, code := range codes {
+			So(recoveryCodePattern.MatchString(code), ShouldBeTrue)
+			So(seen[code], ShouldBeFalse)
+			seen[code] = true
+			So(hashes, ShouldContainSubstring, hashRecoveryCode(code))
+		}
+	})
+}
+
+func TestVerifyTwoFactorChallenge(t *testing.T) {
+	Convey("the second step of a login", t, func() {
+		setupTestDB(t)
+		user := &User{Username: "alice", AffCode: "alice", AccessToken: "alice"}
+		So(DB.Create(user).Error, ShouldBeNil)
+		secret, _, err := SetupTwoFactor(user.Id)
+		So(err, ShouldBeNil)
+		code, err := totp.GenerateCode(secret, time.Now())
+		So(err, ShouldBeNil)
+		recoveryCodes, err := EnableTwoFactor(user.Id, code)
+		So(err, ShouldBeNil)
+		challenge, err := CreateTwoFactorChallenge(user.Id)
+		So(err, ShouldBeNil)
+		So(challenge.ChallengeId, ShouldHaveLength, 32)
+
+		Convey("a valid code returns the user and uses up the challenge", func() {
+			userId, err := VerifyTwoFactorChallenge(challenge.ChallengeId, recoveryCodes[0])
+			So(err, ShouldBeNil)
+			So(userId, ShouldEqual, user.Id)
+			_, err = VerifyTwoFactorChallenge(challenge.ChallengeId, recoveryCodes[1])
+			So(err, ShouldEqual, ErrTwoFactorChallengeExpired)
+		})
+		Convey("the attempts are limited", func() {
+			for i := 0; i < twoFactorChallengeMaxAttempts; i++ {
+				_, err = VerifyTwoFactorChallenge(challenge.ChallengeId, "000000")
+				So(err, ShouldNotBeNil)
+				So(err, ShouldNotEqual, ErrTwoFactorChallengeExpired)
+			}
+			_, err = VerifyTwoFactorChallenge(challenge.ChallengeId, recoveryCodes[0])
+			So(err, ShouldEqual, ErrTwoFactorChallengeExpired)
+		})
+		Convey("an expired challenge is refused", func() {
+			So(DB.Model(challenge).Update("expired_time", 1).Error, ShouldBeNil)
+			_, err = VerifyTwoFactorChallenge(challenge.ChallengeId, recoveryCodes[0])
+			So(err, ShouldEqual, ErrTwoFactorChallengeExpired)
+		})
+		Convey("an unknown challenge is refused", func() {
+			_, err = VerifyTwoFactorChallenge("", recoveryCodes[0])
+			So(err, ShouldEqual, ErrTwoFactorChallengeExpired)
+			_, err = VerifyTwoFactorChallenge("unknown", recoveryCodes[0])
+			So(err, ShouldEqual, ErrTwoFactorChallengeExpired)
+		})
+	})
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/blacklist"
 	"github.com/songquanpeng/one-api/common/config"
-	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/random"
 )
@@ -27,6 +26,7 @@ const (
 	UserStatusEnabled  = 1 // don't use 0, 0 is the default value!
 	UserStatusDisabled = 2 // also don't use 0
 	UserStatusDeleted  = 3
+	UserStatusPending  = 4 // registered in the approval mode, waiting for an admin
 )
 
 // User if you add sensitive fields, don't forget to clean them in setupLogin function.
@@ -43,7 +43,9 @@ type User struct {
 	WeChatId         string `json:"wechat_id" gorm:"column:wechat_id;index"`
 	LarkId           string `json:"lark_id" gorm:"column:lark_id;index"`
 	OidcId           string `json:"oidc_id" gorm:"column:oidc_id;index"`
+	LdapId           string `json:"ldap_id" gorm:"column:ldap_id;index"`
 	VerificationCode string `json:"verification_code" gorm:"-:all"`                                    // this field is only for Email verification, don't save it to database!
+	InviteCode       string `json:"invite_code" gorm:"-:all"`                                          // the registration invite, only for the register request
 	AccessToken      string `json:"access_token" gorm:"type:char(32);column:access_token;uniqueIndex"` // this token is for system management
 	Quota            int64  `json:"quota" gorm:"bigint;default:0"`
 	UsedQuota        int64  `json:"used_quota" gorm:"bigint;default:0;column:used_quota"` // used quota
@@ -51,6 +53,12 @@ type User struct {
 	Group            string `json:"group" gorm:"type:varchar(32);default:'default'"`
 	AffCode          string `json:"aff_code" gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
 	InviterId        int    `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
+	CustomRole       string `json:"custom_role" gorm:"type:varchar(32);default:''"` // grants the permissions of the role, only changed by SetUserCustomRole
+	// the two-factor fields are only changed by the functions in two_factor.go
+	TwoFactorEnabled       bool   `json:"two_factor_enabled" gorm:"default:false"`
+	TwoFactorSecret        string `json:"-" gorm:"type:text"`        // encrypted like the channel keys
+	TwoFactorRecoveryCodes string `json:"-" gorm:"type:text"`        // comma separated sha256 of the unused recovery codes
+	TwoFactorLastStep      int64  `json:"-" gorm:"bigint;default:0"` // the last accepted totp period, to reject replays
 }
 
 func GetMaxUserId() int {
@@ -77,6 +85,11 @@ func GetAllUsers(startIdx int, num int, order string) (users []*User, err error)
 	return users, err
 }
 
+func GetPendingUsers() (users []*User, err error) {
+	err = DB.Omit("password").Where("status = ?", UserStatusPending).Order("id desc").Find(&users).Error
+	return users, err
+}
+
 func SearchUsers(keyword string) (users []*User, err error) {
 	if !common.UsingPostgreSQL {
 		err = DB.Omit("password").Where("id = ? or username LIKE ? or email LIKE ? or display_name LIKE ?", keyword, keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
@@ -100,6 +113,16 @@ func GetUserById(id int, selectAll bool) (*User, error) {
 	return &user, err
 }
 
+// getUserAuth only reads the columns checked by the dashboard authentication
+func getUserAuth(id int) (*User, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	user := User{}
+	err := DB.Select("id", "username", "role", "status").First(&user, "id = ?", id).Error
+	return &user, err
+}
+
 func GetUserIdByAffCode(affCode string) (int, error) {
 	if affCode == "" {
 		return 0, errors.New("affCode 为空！")
@@ -145,22 +168,7 @@ func (user *User) Insert(ctx context.Context, inviterId int) error {
 			RecordLog(ctx, inviterId, LogTypeSystem, fmt.Sprintf("邀请用户赠送 %s", common.LogQuota(config.QuotaForInviter)))
 		}
 	}
-	// create default token
-	cleanToken := Token{
-		UserId:         user.Id,
-		Name:           "default",
-		Key:            random.GenerateKey(),
-		CreatedTime:    helper.GetTimestamp(),
-		AccessedTime:   helper.GetTimestamp(),
-		ExpiredTime:    -1,
-		RemainQuota:    -1,
-		UnlimitedQuota: true,
-	}
-	result.Error = cleanToken.Insert()
-	if result.Error != nil {
-		// do not block
-		logger.SysError(fmt.Sprintf("create default token for user %d failed: %s", user.Id, result.Error.Error()))
-	}
+	// no default token is created, the key of a token is only shown once on creation and nobody would see it
 	return nil
 }
 
@@ -177,8 +185,67 @@ func (user *User) Update(updatePassword bool) error {
 	} else if user.Status == UserStatusEnabled {
 		blacklist.UnbanUser(user.Id)
 	}
-	err = DB.Model(user).Updates(user).Error
-	return err
+	if user.Status != 0 && common.RedisEnabled {
+		// the ban list covers a disable at once, this makes a re-enable take effect at once as well
+		err = common.RedisDel(fmt.Sprintf("user_enabled:%d", user.Id))
+		if err != nil {
+			logger.SysError("Redis del user enabled error: " + err.Error())
+		}
+	}
+	oldUser := User{}
+	err = DB.Select("role").Where("id = ?", user.Id).Find(&oldUser).Error
+	if err != nil {
+		return err
+	}
+	err = DB.Model(user).Omit("custom_role", "two_factor_enabled", "two_factor_secret", "two_factor_recovery_codes", "two_factor_last_step").Updates(user).Error
+	if err != nil {
+		return err
+	}
+	cacheDeleteUserAuth(user.Id)
+	// the sessions are invalidated when the user is disabled, demoted or changes the password
+	if updatePassword || user.Status == UserStatusDisabled || (user.Role != 0 && user.Role < oldUser.Role) {
+		return RevokeUserSessions(user.Id, "")
+	}
+	return nil
+}
+
+// UpdateColumns writes only the columns, for the logins which sync the profile from a directory or an identity provider,
+// so that the quota and the other columns changed meanwhile are not overwritten
+func (user *User) UpdateColumns(columns ...string) error {
+	oldUser := User{}
+	err := DB.Select("role").Where("id = ?", user.Id).Find(&oldUser).Error
+	if err != nil {
+		return err
+	}
+	err = DB.Model(user).Select(columns).Updates(user).Error
+	if err != nil {
+		return err
+	}
+	cacheDeleteUserAuth(user.Id)
+	revoke := false
+	for _, column := range columns {
+		switch column {
+		case "status":
+			if user.Status == UserStatusDisabled {
+				blacklist.BanUser(user.Id)
+				revoke = true
+			} else if user.Status == UserStatusEnabled {
+				blacklist.UnbanUser(user.Id)
+			}
+			if common.RedisEnabled {
+				err = common.RedisDel(fmt.Sprintf("user_enabled:%d", user.Id))
+				if err != nil {
+					logger.SysError("Redis del user enabled error: " + err.Error())
+				}
+			}
+		case "role":
+			revoke = revoke || user.Role < oldUser.Role
+		}
+	}
+	if revoke {
+		return RevokeUserSessions(user.Id, "")
+	}
+	return nil
 }
 
 func (user *User) Delete() error {
@@ -189,7 +256,20 @@ func (user *User) Delete() error {
 	user.Username = fmt.Sprintf("deleted_%s", random.GetUUID())
 	user.Status = UserStatusDeleted
 	err := DB.Model(user).Updates(user).Error
-	return err
+	if err != nil {
+		return err
+	}
+	cacheDeleteUserAuth(user.Id)
+	err = RevokeUserSessions(user.Id, "")
+	if err != nil {
+		return err
+	}
+	err = DeleteUserPersonalAccessTokens(user.Id)
+	if err != nil {
+		return err
+	}
+	// free the external accounts so that they can sign up again
+	return DB.Where("user_id = ?", user.Id).Delete(&UserIdentity{}).Error
 }
 
 // ValidateAndFill check password & user status
@@ -211,6 +291,9 @@ func (user *User) ValidateAndFill() (err error) {
 		}
 	}
 	okay := common.ValidatePasswordAndHash(password, user.Password)
+	if okay && user.Status == UserStatusPending {
+		return errors.New("账户正在等待管理员审核")
+	}
 	if !okay || user.Status != UserStatusEnabled {
 		return errors.New("用户名或密码错误，或用户已被封禁")
 	}
@@ -249,6 +332,14 @@ func (user *User) FillUserByLarkId() error {
 	return nil
 }
 
+func (user *User) FillUserByLdapId() error {
+	if user.LdapId == "" {
+		return errors.New("LDAP id 为空！")
+	}
+	DB.Where(User{LdapId: user.LdapId}).First(user)
+	return nil
+}
+
 func (user *User) FillUserByOidcId() error {
 	if user.OidcId == "" {
 		return errors.New("oidc id 为空！")
@@ -289,6 +380,10 @@ func IsLarkIdAlreadyTaken(githubId string) bool {
 	return DB.Where("lark_id = ?", githubId).Find(&User{}).RowsAffected == 1
 }
 
+func IsLdapIdAlreadyTaken(ldapId string) bool {
+	return DB.Where("ldap_id = ?", ldapId).Find(&User{}).RowsAffected == 1
+}
+
 func IsOidcIdAlreadyTaken(oidcId string) bool {
 	return DB.Where("oidc_id = ?", oidcId).Find(&User{}).RowsAffected == 1
 }
@@ -305,8 +400,22 @@ func ResetUserPasswordByEmail(email string, password string) error {
 	if err != nil {
 		return err
 	}
+	var ids []int
+	err = DB.Model(&User{}).Where("email = ?", email).Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
 	err = DB.Model(&User{}).Where("email = ?", email).Update("password", hashedPassword).Error
-	return err
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err = RevokeUserSessions(id, "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func IsAdmin(userId int) bool {
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUserUpdateColumns(t *testing.T) {
+	Convey("update only the synced columns of a user", t, func() {
+		setupTestDB(t)
+		user := &User{Username: "alice", AffCode: "alice", AccessToken: "alice", Role: RoleAdminUser, Status: UserStatusEnabled, Quota: 100}
+		So(DB.Create(user).Error, ShouldBeNil)
+		_, err := CreateUserSession(user.Id, "127.0.0.1", "test")
+		So(err, ShouldBeNil)
+		synced := User{Id: user.Id}
+		So(DB.First(&synced).Error, ShouldBeNil)
+		// the quota is spent while the login syncs the profile
+		So(DecreaseUserQuota(user.Id, 30), ShouldBeNil)
+
+		Convey("the other columns are kept", func() {
+			synced.DisplayName = "Alice"
+			synced.Group = "vip"
+			So(synced.UpdateColumns("display_name", "group", "role"), ShouldBeNil)
+			updated, err := GetUserById(user.Id, false)
+			So(err, ShouldBeNil)
+			So(updated.DisplayName, ShouldEqual, "Alice")
+			So(updated.Group, ShouldEqual, "vip")
+			So(updated.Quota, ShouldEqual, 70)
+			sessions, _ := GetUserSessions(user.Id)
+			So(len(sessions), ShouldEqual, 1)
+		})
+		Convey("a demotion revokes the sessions", func() {
+			synced.Role = RoleCommonUser
+			So(synced.UpdateColumns("role"), ShouldBeNil)
+			sessions, _ := GetUserSessions(user.Id)
+			So(len(sessions), ShouldEqual, 0)
+		})
+	})
+}
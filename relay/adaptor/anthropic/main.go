@@ -125,10 +125,11 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 		openaiContent := message.ParseContent()
 		for _, part := range openaiContent {
 			var content Content
-			if part.Type == model.ContentTypeText {
+			switch part.Type {
+			case model.ContentTypeText:
 				content.Type = "text"
 				content.Text = part.Text
-			} else if part.Type == model.ContentTypeImageURL {
+			case model.ContentTypeImageURL:
 				content.Type = "image"
 				content.Source = &ImageSource{
 					Type: "base64",
@@ -136,6 +137,18 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 				mimeType, data, _ := image.GetImageFromUrl(part.ImageURL.Url)
 				content.Source.MediaType = mimeType
 				content.Source.Data = data
+			case model.ContentTypeFile:
+				// https://docs.anthropic.com/en/docs/build-with-claude/pdf-support
+				mimeType, data := part.File.Parse()
+				content.Type = "document"
+				content.Source = &ImageSource{
+					Type:      "base64",
+					MediaType: mimeType,
+					Data:      data,
+				}
+			default:
+				// claude does not support audio input yet
+				continue
 			}
 			contents = append(contents, content)
 		}
@@ -6,6 +6,7 @@ type Metadata struct {
 	UserId string `json:"user_id"`
 }
 
+// ImageSource is also used as the source of document content
 type ImageSource struct {
 	Type      string `json:"type"`
 	MediaType string `json:"media_type"`
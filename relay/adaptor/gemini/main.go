@@ -106,11 +106,12 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *ChatRequest {
 		var parts []Part
 		imageNum := 0
 		for _, part := range openaiContent {
-			if part.Type == model.ContentTypeText {
+			switch part.Type {
+			case model.ContentTypeText:
 				parts = append(parts, Part{
 					Text: part.Text,
 				})
-			} else if part.Type == model.ContentTypeImageURL {
+			case model.ContentTypeImageURL:
 				imageNum += 1
 				if imageNum > VisionMaxImageNum {
 					continue
@@ -122,6 +123,21 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *ChatRequest {
 						Data:     data,
 					},
 				})
+			case model.ContentTypeInputAudio:
+				parts = append(parts, Part{
+					InlineData: &InlineData{
+						MimeType: part.InputAudio.MimeType(),
+						Data:     part.InputAudio.Data,
+					},
+				})
+			case model.ContentTypeFile:
+				mimeType, data := part.File.Parse()
+				parts = append(parts, Part{
+					InlineData: &InlineData{
+						MimeType: mimeType,
+						Data:     data,
+					},
+				})
 			}
 		}
 		content.Parts = parts
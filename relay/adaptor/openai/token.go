@@ -1,9 +1,12 @@
 package openai
 
 import (
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
+	"regexp"
 	"strings"
 
 	"github.com/pkoukk/tiktoken-go"
@@ -120,6 +123,29 @@ func CountTokenMessages(messages []model.Message, model string) int {
 							tokenNum += imageTokens
 						}
 					}
+				case "input_audio":
+					inputAudio, ok := m["input_audio"].(map[string]any)
+					if ok {
+						data, _ := inputAudio["data"].(string)
+						format, _ := inputAudio["format"].(string)
+						audioTokens, err := countAudioTokens(data, format, model)
+						if err != nil {
+							logger.SysError("error counting audio tokens: " + err.Error())
+						} else {
+							tokenNum += audioTokens
+						}
+					}
+				case "file":
+					file, ok := m["file"].(map[string]any)
+					if ok {
+						fileData, _ := file["file_data"].(string)
+						fileTokens, err := countFileTokens(tokenEncoder, fileData, model)
+						if err != nil {
+							logger.SysError("error counting file tokens: " + err.Error())
+						} else {
+							tokenNum += fileTokens
+						}
+					}
 				}
 			}
 		}
@@ -211,6 +237,75 @@ func countImageTokens(url string, detail string, model string) (_ int, err error
 	}
 }
 
+const (
+	// https://platform.openai.com/docs/guides/realtime-costs
+	audioTokensPerSecond = 10
+	// https://ai.google.dev/gemini-api/docs/tokens
+	geminiAudioTokensPerSecond = 32
+	geminiPdfTokensPerPage     = 258
+	// https://docs.anthropic.com/en/docs/build-with-claude/pdf-support#estimate-your-costs
+	pdfTokensPerPage = 1500
+	// bitrate used to estimate the duration of compressed audio, in bytes per second
+	defaultAudioByteRate = 128 * 1000 / 8
+	wavHeaderSize        = 44
+)
+
+var pdfPagePattern = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// countAudioTokens estimates the tokens of a base64 encoded audio clip by its duration
+func countAudioTokens(data string, format string, model string) (int, error) {
+	if data == "" {
+		return 0, errors.New("empty audio data")
+	}
+	size := base64.StdEncoding.DecodedLen(len(data))
+	byteRate := defaultAudioByteRate
+	if format == "wav" || format == "" {
+		// the byte rate of wav is stored in the header
+		headerLength := 60 // enough to hold the 44 bytes header
+		if len(data) < headerLength {
+			headerLength = len(data)
+		}
+		header, err := base64.StdEncoding.DecodeString(data[:headerLength])
+		if err != nil {
+			return 0, err
+		}
+		if len(header) >= wavHeaderSize && string(header[:4]) == "RIFF" {
+			byteRate = int(binary.LittleEndian.Uint32(header[28:32]))
+			size -= wavHeaderSize
+		}
+	}
+	if byteRate <= 0 {
+		byteRate = defaultAudioByteRate
+	}
+	seconds := math.Ceil(float64(size) / float64(byteRate))
+	if strings.HasPrefix(model, "gemini") {
+		return int(seconds) * geminiAudioTokensPerSecond, nil
+	}
+	return int(seconds) * audioTokensPerSecond, nil
+}
+
+// countFileTokens estimates the tokens of a file content part,
+// pdf is counted by pages and plain text is counted by its content
+func countFileTokens(tokenEncoder *tiktoken.Tiktoken, fileData string, modelName string) (int, error) {
+	file := model.File{FileData: fileData}
+	mimeType, data := file.Parse()
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(mimeType, "text/") {
+		return getTokenNum(tokenEncoder, string(decoded)), nil
+	}
+	pages := len(pdfPagePattern.FindAllIndex(decoded, -1))
+	if pages == 0 {
+		pages = 1
+	}
+	if strings.HasPrefix(modelName, "gemini") {
+		return pages * geminiPdfTokensPerPage, nil
+	}
+	return pages * pdfTokensPerPage, nil
+}
+
 func CountTokenInput(input any, model string) int {
 	switch v := input.(type) {
 	case string:
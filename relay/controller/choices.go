@@ -0,0 +1,339 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/common/render"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// MaxEmulatedChoices limits the concurrent upstream requests of one emulated request
+const MaxEmulatedChoices = 8
+
+// singleChoiceAPITypes are the api types which ignore the n parameter and always return one choice
+var singleChoiceAPITypes = map[int]bool{
+	apitype.Anthropic:  true,
+	apitype.AwsClaude:  true,
+	apitype.Gemini:     true,
+	apitype.VertexAI:   true,
+	apitype.Ollama:     true,
+	apitype.Cohere:     true,
+	apitype.Coze:       true,
+	apitype.Cloudflare: true,
+	apitype.PaLM:       true,
+	apitype.Baidu:      true,
+	apitype.Tencent:    true,
+	apitype.Xunfei:     true,
+}
+
+func shouldEmulateMultipleChoices(meta *meta.Meta, textRequest *model.GeneralOpenAIRequest) bool {
+	return meta.Mode == relaymode.ChatCompletions && textRequest.N > 1 && singleChoiceAPITypes[meta.APIType]
+}
+
+// choiceMerger collects the responses of the emulated choices
+type choiceMerger struct {
+	sync.Mutex
+	c       *gin.Context
+	id      string
+	written bool
+}
+
+// forward sends a stream chunk of one choice to the client, chunks from different choices are interleaved
+func (m *choiceMerger) forward(index int, data string) {
+	var streamResponse openai.ChatCompletionsStreamResponse
+	err := json.Unmarshal([]byte(data), &streamResponse)
+	if err != nil {
+		logger.SysError("error unmarshalling stream response: " + err.Error())
+		return
+	}
+	if len(streamResponse.Choices) == 0 {
+		// usage only chunk, the summed usage will be sent at the end
+		return
+	}
+	streamResponse.Id = m.id
+	streamResponse.Usage = nil
+	for i := range streamResponse.Choices {
+		streamResponse.Choices[i].Index = index
+	}
+	m.Lock()
+	defer m.Unlock()
+	if !m.written {
+		common.SetEventStreamHeaders(m.c)
+		m.written = true
+	}
+	err = render.ObjectData(m.c, streamResponse)
+	if err != nil {
+		logger.SysError(err.Error())
+	}
+}
+
+// choiceWriter captures the response written by the adaptor for one choice
+type choiceWriter struct {
+	gin.ResponseWriter
+	merger  *choiceMerger
+	index   int
+	stream  bool
+	header  http.Header
+	status  int
+	body    bytes.Buffer
+	pending bytes.Buffer
+}
+
+func newChoiceWriter(c *gin.Context, merger *choiceMerger, index int, stream bool) *choiceWriter {
+	return &choiceWriter{
+		ResponseWriter: c.Writer,
+		merger:         merger,
+		index:          index,
+		stream:         stream,
+		header:         make(http.Header),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *choiceWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *choiceWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *choiceWriter) WriteHeaderNow() {}
+
+func (w *choiceWriter) Status() int {
+	return w.status
+}
+
+func (w *choiceWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *choiceWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *choiceWriter) Flush() {}
+
+func (w *choiceWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *choiceWriter) Write(data []byte) (int, error) {
+	if !w.stream {
+		return w.body.Write(data)
+	}
+	w.body.Write(data)
+	w.pending.Write(data)
+	// the events are separated by blank lines, only complete events are forwarded
+	for {
+		event, rest, found := strings.Cut(w.pending.String(), "\n\n")
+		if !found {
+			break
+		}
+		w.pending.Reset()
+		w.pending.WriteString(rest)
+		for _, line := range strings.Split(event, "\n") {
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			line = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if line == "" || line == "[DONE]" {
+				continue
+			}
+			w.merger.forward(w.index, line)
+		}
+	}
+	return len(data), nil
+}
+
+// choiceResult holds one emulated choice, each choice has its own meta and adaptor,
+// since some adaptors keep state between the request and the response
+type choiceResult struct {
+	meta    *meta.Meta
+	adaptor adaptor.Adaptor
+	body    []byte
+	writer  *choiceWriter
+	usage   *model.Usage
+	err     *model.ErrorWithStatusCode
+}
+
+// relayMultipleChoices emulates n > 1 by sending n concurrent requests to the upstream,
+// the choices are merged into one response and the usage is summed up
+func relayMultipleChoices(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest) (*model.Usage, *model.ErrorWithStatusCode) {
+	n := textRequest.N
+	if n > MaxEmulatedChoices {
+		return nil, openai.ErrorWrapper(fmt.Errorf("n should be less than or equal to %d", MaxEmulatedChoices), "invalid_n", http.StatusBadRequest)
+	}
+	merger := &choiceMerger{
+		c:  c,
+		id: fmt.Sprintf("chatcmpl-%s", random.GetUUID()),
+	}
+	results := make([]choiceResult, n)
+	// the requests are converted one by one, the adaptors may change the request and the context
+	for i := range results {
+		choiceMeta := *meta
+		choiceAdaptor := relay.GetAdaptor(meta.APIType)
+		choiceAdaptor.Init(&choiceMeta)
+		choiceRequest := *textRequest
+		requestBody, err := getRequestBody(c, &choiceMeta, &choiceRequest, choiceAdaptor)
+		if err != nil {
+			return nil, openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
+		}
+		jsonData, err := io.ReadAll(requestBody)
+		if err != nil {
+			return nil, openai.ErrorWrapper(err, "read_request_body_failed", http.StatusInternalServerError)
+		}
+		results[i] = choiceResult{
+			meta:    &choiceMeta,
+			adaptor: choiceAdaptor,
+			body:    jsonData,
+			writer:  newChoiceWriter(c, merger, i, meta.IsStream),
+		}
+	}
+	return relayChoices(c, meta, textRequest, merger, results)
+}
+
+// relayChoices sends the choices concurrently, if some of them fail the usage of the others is still returned
+// along with the error, since they are paid for upstream
+func relayChoices(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest, merger *choiceMerger, results []choiceResult) (*model.Usage, *model.ErrorWithStatusCode) {
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(result *choiceResult) {
+			defer wg.Done()
+			subContext := c.Copy()
+			subContext.Writer = result.writer
+			resp, err := result.adaptor.DoRequest(subContext, result.meta, bytes.NewReader(result.body))
+			if err != nil {
+				result.err = openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+				return
+			}
+			if isErrorHappened(result.meta, resp) {
+				result.err = RelayErrorHandler(resp)
+				return
+			}
+			result.usage, result.err = result.adaptor.DoResponse(subContext, resp, result.meta)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	usage := &model.Usage{}
+	var firstErr *model.ErrorWithStatusCode
+	for i, result := range results {
+		if result.err != nil {
+			logger.Errorf(c.Request.Context(), "emulated choice %d failed: %+v", i, *result.err)
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+		if result.usage != nil {
+			usage.PromptTokens += result.usage.PromptTokens
+			usage.CompletionTokens += result.usage.CompletionTokens
+		}
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	if meta.IsStream {
+		if !merger.written {
+			if firstErr == nil {
+				firstErr = openai.ErrorWrapper(errors.New("no choice returned"), "empty_response", http.StatusInternalServerError)
+			}
+			return usage, firstErr
+		}
+		// the status has been sent, so a failed choice is finished with its error in the stream
+		for i, result := range results {
+			if result.err == nil {
+				continue
+			}
+			finishReason := "error"
+			err := render.ObjectData(c, gin.H{
+				"id":      merger.id,
+				"object":  "chat.completion.chunk",
+				"created": helper.GetTimestamp(),
+				"model":   meta.ActualModelName,
+				"choices": []openai.ChatCompletionsStreamResponseChoice{{Index: i, FinishReason: &finishReason}},
+				"error":   result.err.Error,
+			})
+			if err != nil {
+				logger.SysError(err.Error())
+			}
+		}
+		if textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage {
+			err := render.ObjectData(c, openai.ChatCompletionsStreamResponse{
+				Id:      merger.id,
+				Object:  "chat.completion.chunk",
+				Created: helper.GetTimestamp(),
+				Model:   meta.ActualModelName,
+				Choices: []openai.ChatCompletionsStreamResponseChoice{},
+				Usage:   usage,
+			})
+			if err != nil {
+				logger.SysError(err.Error())
+			}
+		}
+		render.Done(c)
+		return usage, nil
+	}
+
+	if firstErr != nil {
+		return usage, firstErr
+	}
+	fullTextResponse, err := mergeChoices(merger.id, meta.ActualModelName, results)
+	if err != nil {
+		return nil, openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	fullTextResponse.Usage = *usage
+	jsonResponse, err := json.Marshal(fullTextResponse)
+	if err != nil {
+		return nil, openai.ErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write(jsonResponse)
+	return usage, nil
+}
+
+// mergeChoices puts the choices of the captured responses into one response, indexed by their choice
+func mergeChoices(id string, modelName string, results []choiceResult) (*openai.TextResponse, error) {
+	fullTextResponse := &openai.TextResponse{
+		Id:      id,
+		Model:   modelName,
+		Object:  "chat.completion",
+		Created: helper.GetTimestamp(),
+		Choices: make([]openai.TextResponseChoice, 0, len(results)),
+	}
+	for i, result := range results {
+		var textResponse openai.TextResponse
+		err := json.Unmarshal(result.writer.body.Bytes(), &textResponse)
+		if err != nil {
+			return nil, err
+		}
+		for _, choice := range textResponse.Choices {
+			choice.Index = i
+			fullTextResponse.Choices = append(fullTextResponse.Choices, choice)
+		}
+	}
+	return fullTextResponse, nil
+}
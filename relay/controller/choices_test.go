@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// fakeChoiceAdaptor answers one choice, the adaptor methods which are not used by relayChoices are left nil
+type fakeChoiceAdaptor struct {
+	adaptor.Adaptor
+	content string
+	fail    bool
+}
+
+func (a *fakeChoiceAdaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestBody io.Reader) (*http.Response, error) {
+	if a.fail {
+		return nil, errors.New("upstream unavailable")
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func (a *fakeChoiceAdaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Meta) (*model.Usage, *model.ErrorWithStatusCode) {
+	if meta.IsStream {
+		for _, part := range strings.Split(a.content, " ") {
+			_, _ = fmt.Fprintf(c.Writer, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", part)
+		}
+		_, _ = c.Writer.Write([]byte("data: [DONE]\n\n"))
+	} else {
+		_, _ = fmt.Fprintf(c.Writer, "{\"choices\":[{\"index\":0,\"message\":{\"role\":\"assistant\",\"content\":%q},\"finish_reason\":\"stop\"}]}", a.content)
+	}
+	return &model.Usage{PromptTokens: 10, CompletionTokens: 5}, nil
+}
+
+func newChoicesTest(stream bool, adaptors ...*fakeChoiceAdaptor) (*httptest.ResponseRecorder, func() (*model.Usage, *model.ErrorWithStatusCode)) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	relayMeta := &meta.Meta{IsStream: stream, ActualModelName: "claude-3-haiku-20240307"}
+	textRequest := &model.GeneralOpenAIRequest{N: len(adaptors), StreamOptions: &model.StreamOptions{IncludeUsage: true}}
+	merger := &choiceMerger{c: c, id: "chatcmpl-test"}
+	results := make([]choiceResult, len(adaptors))
+	for i, a := range adaptors {
+		choiceMeta := *relayMeta
+		results[i] = choiceResult{
+			meta:    &choiceMeta,
+			adaptor: a,
+			writer:  newChoiceWriter(c, merger, i, stream),
+		}
+	}
+	return w, func() (*model.Usage, *model.ErrorWithStatusCode) {
+		return relayChoices(c, relayMeta, textRequest, merger, results)
+	}
+}
+
+func TestRelayChoices(t *testing.T) {
+	Convey("merge the emulated choices", t, func() {
+		Convey("non-stream responses are merged with their choice index and summed usage", func() {
+			w, relay := newChoicesTest(false, &fakeChoiceAdaptor{content: "first"}, &fakeChoiceAdaptor{content: "second"})
+			usage, err := relay()
+			So(err, ShouldBeNil)
+			So(usage.PromptTokens, ShouldEqual, 20)
+			So(usage.TotalTokens, ShouldEqual, 30)
+			var response openai.TextResponse
+			So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+			So(response.Id, ShouldEqual, "chatcmpl-test")
+			So(len(response.Choices), ShouldEqual, 2)
+			So(response.Choices[0].Index, ShouldEqual, 0)
+			So(response.Choices[0].Message.StringContent(), ShouldEqual, "first")
+			So(response.Choices[1].Index, ShouldEqual, 1)
+			So(response.Choices[1].Message.StringContent(), ShouldEqual, "second")
+			So(response.Usage.CompletionTokens, ShouldEqual, 10)
+		})
+		Convey("a failed non-stream choice fails the request but the others are still billed", func() {
+			_, relay := newChoicesTest(false, &fakeChoiceAdaptor{content: "first"}, &fakeChoiceAdaptor{fail: true})
+			usage, err := relay()
+			So(err, ShouldNotBeNil)
+			So(err.Error.Code, ShouldEqual, "do_request_failed")
+			So(usage.PromptTokens, ShouldEqual, 10)
+			So(usage.CompletionTokens, ShouldEqual, 5)
+			So(usage.TotalTokens, ShouldEqual, 15)
+		})
+		Convey("stream chunks are re-indexed under one id", func() {
+			w, relay := newChoicesTest(true, &fakeChoiceAdaptor{content: "a b"}, &fakeChoiceAdaptor{content: "c d"})
+			usage, err := relay()
+			So(err, ShouldBeNil)
+			So(usage.CompletionTokens, ShouldEqual, 10)
+			chunks, done := parseChoiceChunks(w.Body.String())
+			So(done, ShouldBeTrue)
+			content := map[int]string{}
+			for _, chunk := range chunks {
+				So(chunk.Id, ShouldEqual, "chatcmpl-test")
+				for _, choice := range chunk.Choices {
+					content[choice.Index] += choice.Delta.StringContent()
+				}
+			}
+			So(content[0], ShouldEqual, "ab")
+			So(content[1], ShouldEqual, "cd")
+			last := chunks[len(chunks)-1]
+			So(len(last.Choices), ShouldEqual, 0)
+			So(last.Usage.TotalTokens, ShouldEqual, 30)
+		})
+		Convey("a failed stream choice is finished with its error", func() {
+			w, relay := newChoicesTest(true, &fakeChoiceAdaptor{content: "a b"}, &fakeChoiceAdaptor{fail: true})
+			usage, err := relay()
+			So(err, ShouldBeNil)
+			So(usage.CompletionTokens, ShouldEqual, 5)
+			chunks, done := parseChoiceChunks(w.Body.String())
+			So(done, ShouldBeTrue)
+			var failed *choiceChunk
+			for i := range chunks {
+				if chunks[i].Error != nil {
+					failed = &chunks[i]
+				}
+			}
+			So(failed, ShouldNotBeNil)
+			So(failed.Choices[0].Index, ShouldEqual, 1)
+			So(*failed.Choices[0].FinishReason, ShouldEqual, "error")
+			So(failed.Error.Code, ShouldEqual, "do_request_failed")
+		})
+		Convey("a stream where every choice failed returns the error", func() {
+			_, relay := newChoicesTest(true, &fakeChoiceAdaptor{fail: true}, &fakeChoiceAdaptor{fail: true})
+			usage, err := relay()
+			So(err, ShouldNotBeNil)
+			So(usage.TotalTokens, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestGetPreConsumedQuota(t *testing.T) {
+	Convey("the pre-consumed quota of n choices", t, func() {
+		textRequest := &model.GeneralOpenAIRequest{N: 3, MaxTokens: 100}
+		native := getPreConsumedQuota(textRequest, 1000, 1, false)
+		emulated := getPreConsumedQuota(textRequest, 1000, 1, true)
+		So(native, ShouldEqual, config.PreConsumedQuota+1000+3*100)
+		So(emulated-native, ShouldEqual, 2*1000)
+		textRequest.N = 1
+		So(getPreConsumedQuota(textRequest, 1000, 1, true), ShouldEqual, config.PreConsumedQuota+1000+100)
+	})
+}
+
+type choiceChunk struct {
+	openai.ChatCompletionsStreamResponse
+	Error *model.Error `json:"error"`
+}
+
+func parseChoiceChunks(body string) ([]choiceChunk, bool) {
+	var chunks []choiceChunk
+	done := false
+	for _, line := range strings.Split(body, "\n") {
+		data, found := strings.CutPrefix(line, "data: ")
+		if !found {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk choiceChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err == nil {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, done
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/modelinfo"
+)
+
+const (
+	// ContextFitRemovedMessagesHeader reports the number of oldest messages dropped to fit the context window
+	ContextFitRemovedMessagesHeader = "X-Context-Fit-Removed-Messages"
+	// ContextFitTruncatedToolResultsHeader reports the number of tool results truncated in the middle
+	ContextFitTruncatedToolResultsHeader = "X-Context-Fit-Truncated-Tool-Results"
+)
+
+// minToolResultTokens is the size a long tool result is truncated to
+const minToolResultTokens = 1024
+
+// truncationMarkerTokens is reserved for the marker inserted into a truncated tool result
+const truncationMarkerTokens = 16
+
+// replyPrimingTokens is added once per request by openai.CountTokenMessages
+const replyPrimingTokens = 3
+
+type contextFitResult struct {
+	removedMessages      int
+	truncatedToolResults int
+}
+
+// fitContextWindow trims the chat messages until the prompt and max_tokens fit in the context window of the model,
+// long tool results are truncated in the middle first, then the oldest non-system messages are dropped,
+// the last message is always kept, the new prompt tokens are returned
+func fitContextWindow(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest, promptTokens int) int {
+	info, ok := modelinfo.GetModelInfo(textRequest.Model)
+	if !ok || info.ContextWindow <= 0 {
+		return promptTokens
+	}
+	budget := info.ContextWindow - textRequest.MaxTokens
+	if promptTokens <= budget {
+		return promptTokens
+	}
+	messages, result := fitMessages(textRequest.Messages, textRequest.Model, budget)
+	if result.removedMessages == 0 && result.truncatedToolResults == 0 {
+		return promptTokens
+	}
+	logger.Infof(c.Request.Context(), "context window fitted: %d messages removed, %d tool results truncated",
+		result.removedMessages, result.truncatedToolResults)
+	textRequest.Messages = messages
+	meta.ContextFitted = true
+	c.Header(ContextFitRemovedMessagesHeader, strconv.Itoa(result.removedMessages))
+	c.Header(ContextFitTruncatedToolResultsHeader, strconv.Itoa(result.truncatedToolResults))
+	return openai.CountTokenMessages(textRequest.Messages, textRequest.Model)
+}
+
+func countMessageTokens(message model.Message, modelName string) int {
+	return openai.CountTokenMessages([]model.Message{message}, modelName) - replyPrimingTokens
+}
+
+func fitMessages(messages []model.Message, modelName string, budget int) ([]model.Message, contextFitResult) {
+	var result contextFitResult
+	messages = append([]model.Message(nil), messages...)
+	tokens := make([]int, len(messages))
+	total := replyPrimingTokens
+	for i, message := range messages {
+		tokens[i] = countMessageTokens(message, modelName)
+		total += tokens[i]
+	}
+
+	// truncate the long tool results in the middle, the beginning and the end of them are usually the most useful
+	for i := range messages {
+		if total <= budget {
+			break
+		}
+		if messages[i].Role != "tool" || !messages[i].IsStringContent() || tokens[i] <= minToolResultTokens {
+			continue
+		}
+		keep := tokens[i] - (total - budget) - truncationMarkerTokens
+		if keep < minToolResultTokens {
+			keep = minToolResultTokens
+		}
+		messages[i].Content = truncateMiddle(messages[i].StringContent(), keep, tokens[i])
+		newTokens := countMessageTokens(messages[i], modelName)
+		total += newTokens - tokens[i]
+		tokens[i] = newTokens
+		result.truncatedToolResults++
+	}
+
+	// drop the oldest non-system messages, an assistant message with tool calls is dropped together with the tool results
+	// answering it, so that no tool call is left without its result and no result without its call
+	removed := make([]bool, len(messages))
+	last := len(messages) - 1
+	for i := 0; i < last && total > budget; {
+		end := i + 1
+		if messages[i].Role == "assistant" && len(messages[i].ToolCalls) > 0 {
+			for end <= last && messages[end].Role == "tool" {
+				end++
+			}
+		}
+		if end > last {
+			// the group holds the last message
+			break
+		}
+		if messages[i].Role != "system" {
+			for j := i; j < end; j++ {
+				removed[j] = true
+				total -= tokens[j]
+				result.removedMessages++
+			}
+		}
+		i = end
+	}
+	if result.removedMessages == 0 {
+		return messages, result
+	}
+	fitted := make([]model.Message, 0, len(messages)-result.removedMessages)
+	for i, message := range messages {
+		if !removed[i] {
+			fitted = append(fitted, message)
+		}
+	}
+	return fitted, result
+}
+
+// truncateMiddle keeps about keepTokens of the text, the text is cut by runes in proportion to its tokens
+func truncateMiddle(text string, keepTokens int, totalTokens int) string {
+	runes := []rune(text)
+	keepRunes := int(float64(len(runes)) * float64(keepTokens) / float64(totalTokens))
+	if keepRunes >= len(runes) {
+		return text
+	}
+	head := keepRunes / 2
+	tail := keepRunes - head
+	return fmt.Sprintf("%s\n...[%d characters truncated]...\n%s",
+		string(runes[:head]), len(runes)-keepRunes, string(runes[len(runes)-tail:]))
+}
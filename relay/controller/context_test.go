@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+func TestFitMessages(t *testing.T) {
+	config.ApproximateTokenEnabled = true
+	defer func() { config.ApproximateTokenEnabled = false }()
+	Convey("fit messages into the budget", t, func() {
+		long := strings.Repeat("a", 1000)
+		Convey("drop the oldest non-system messages", func() {
+			messages := []model.Message{
+				{Role: "system", Content: "be brief"},
+				{Role: "user", Content: long},
+				{Role: "assistant", Content: long},
+				{Role: "user", Content: "hi"},
+			}
+			fitted, result := fitMessages(messages, "gpt-4o", 300)
+			So(result.removedMessages, ShouldEqual, 2)
+			So(len(fitted), ShouldEqual, 2)
+			So(fitted[0].Role, ShouldEqual, "system")
+			So(fitted[1].StringContent(), ShouldEqual, "hi")
+			So(len(messages), ShouldEqual, 4)
+		})
+		Convey("drop tool results along with the tool call", func() {
+			messages := []model.Message{
+				{Role: "assistant", Content: long, ToolCalls: []model.Tool{{Id: "call_1", Type: "function"}, {Id: "call_2", Type: "function"}}},
+				{Role: "tool", Content: "sunny", ToolCallId: "call_1"},
+				{Role: "tool", Content: "rainy", ToolCallId: "call_2"},
+				{Role: "user", Content: "hi"},
+			}
+			fitted, result := fitMessages(messages, "gpt-4o", 100)
+			So(result.removedMessages, ShouldEqual, 3)
+			So(len(fitted), ShouldEqual, 1)
+			So(fitted[0].Role, ShouldEqual, "user")
+		})
+		Convey("keep the tool call answered by the last message", func() {
+			messages := []model.Message{
+				{Role: "user", Content: long},
+				{Role: "assistant", Content: long, ToolCalls: []model.Tool{{Id: "call_1", Type: "function"}}},
+				{Role: "tool", Content: "sunny", ToolCallId: "call_1"},
+			}
+			fitted, result := fitMessages(messages, "gpt-4o", 100)
+			So(result.removedMessages, ShouldEqual, 1)
+			So(len(fitted), ShouldEqual, 2)
+			So(fitted[0].Role, ShouldEqual, "assistant")
+			So(fitted[1].Role, ShouldEqual, "tool")
+		})
+		Convey("truncate long tool results in the middle", func() {
+			messages := []model.Message{
+				{Role: "user", Content: "read the file"},
+				{Role: "tool", Content: "BEGIN" + strings.Repeat("b", 10000) + "END", ToolCallId: "call_1"},
+				{Role: "user", Content: "summarize it"},
+			}
+			fitted, result := fitMessages(messages, "gpt-4o", 3000)
+			So(result.truncatedToolResults, ShouldEqual, 1)
+			So(result.removedMessages, ShouldEqual, 0)
+			content := fitted[1].StringContent()
+			So(content, ShouldStartWith, "BEGIN")
+			So(content, ShouldEndWith, "END")
+			So(content, ShouldContainSubstring, "characters truncated")
+			So(len(messages[1].StringContent()), ShouldEqual, 10008)
+		})
+	})
+}
@@ -171,7 +171,7 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	modelRatio := billingratio.GetModelRatio(imageModel, meta.ChannelType)
 	groupRatio := billingratio.GetGroupRatio(meta.Group)
 	ratio := modelRatio * groupRatio
-	userQuota, err := model.CacheGetUserQuota(ctx, meta.UserId)
+	userQuota, err := model.CacheGetPayerQuota(ctx, meta.UserId, meta.OrganizationId)
 
 	var quota int64
 	switch meta.ChannelType {
@@ -200,7 +200,7 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 			return
 		}
 
-		err := model.PostConsumeTokenQuota(meta.TokenId, quota)
+		err := model.PostConsumeTokenQuota(ctx, meta.TokenId, quota, meta.OriginModelName)
 		if err != nil {
 			logger.SysError("error consuming token remain quota: " + err.Error())
 		}
@@ -228,11 +228,16 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	}(c.Request.Context())
 
 	// do response
-	_, respErr := adaptor.DoResponse(c, resp, meta)
+	usage, respErr := adaptor.DoResponse(c, resp, meta)
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
 		return respErr
 	}
+	// most image apis return no usage, the prompt is counted for the tokens per minute then
+	if usage == nil {
+		usage = &relaymodel.Usage{PromptTokens: openai.CountTokenText(imageRequest.Prompt, imageModel)}
+	}
+	recordUsedTokens(c, usage)
 
 	return nil
 }
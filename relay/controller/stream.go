@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/conv"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/render"
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+const (
+	StreamConversionFake      = dbmodel.StreamConversionFake
+	StreamConversionAggregate = dbmodel.StreamConversionAggregate
+)
+
+// fakeStreamChunkSize is the number of runes in one fake stream chunk
+const fakeStreamChunkSize = 32
+
+type streamConversion struct {
+	mode         string
+	includeUsage bool
+}
+
+// applyStreamConversion switches the stream mode of the upstream request according to the channel config,
+// it returns nil if no conversion is needed
+func applyStreamConversion(meta *meta.Meta, textRequest *model.GeneralOpenAIRequest) *streamConversion {
+	if meta.Mode != relaymode.ChatCompletions {
+		return nil
+	}
+	mode := meta.Config.GetStreamConversion(meta.ActualModelName)
+	conversion := &streamConversion{mode: mode}
+	switch mode {
+	case StreamConversionFake:
+		if !textRequest.Stream {
+			return nil
+		}
+		conversion.includeUsage = textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage
+		textRequest.Stream = false
+		textRequest.StreamOptions = nil
+		meta.IsStream = false
+	case StreamConversionAggregate:
+		if textRequest.Stream {
+			return nil
+		}
+		textRequest.Stream = true
+		textRequest.StreamOptions = &model.StreamOptions{IncludeUsage: true}
+		meta.IsStream = true
+	default:
+		return nil
+	}
+	meta.StreamConversion = mode
+	return conversion
+}
+
+// doResponse lets the adaptor handle the upstream response, then converts it to the mode requested by the client
+func (s *streamConversion) doResponse(c *gin.Context, resp *http.Response, meta *meta.Meta, adaptor adaptor.Adaptor) (*model.Usage, *model.ErrorWithStatusCode) {
+	subContext := c.Copy()
+	writer := newChoiceWriter(c, nil, 0, false)
+	subContext.Writer = writer
+	usage, respErr := adaptor.DoResponse(subContext, resp, meta)
+	// restore the stream mode of the client for the consume log
+	meta.IsStream = s.mode == StreamConversionFake
+	if respErr != nil {
+		return nil, respErr
+	}
+	switch s.mode {
+	case StreamConversionFake:
+		var textResponse openai.TextResponse
+		err := json.Unmarshal(writer.body.Bytes(), &textResponse)
+		if err != nil {
+			return nil, openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+		}
+		if usage == nil {
+			usage = &textResponse.Usage
+		}
+		renderFakeStream(c, &textResponse, usage, s.includeUsage)
+	case StreamConversionAggregate:
+		textResponse := aggregateStream(writer.body.String())
+		if textResponse.Model == "" {
+			textResponse.Model = meta.ActualModelName
+		}
+		if usage != nil {
+			textResponse.Usage = *usage
+		} else {
+			usage = &textResponse.Usage
+		}
+		c.JSON(http.StatusOK, textResponse)
+	}
+	return usage, nil
+}
+
+func splitByRunes(text string, size int) []string {
+	runes := []rune(text)
+	chunks := make([]string, 0, len(runes)/size+1)
+	for i := 0; i < len(runes); i += size {
+		end := i + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunks = append(chunks, string(runes[i:end]))
+	}
+	return chunks
+}
+
+// renderFakeStream sends a full response to the client as chat completion chunks
+func renderFakeStream(c *gin.Context, textResponse *openai.TextResponse, usage *model.Usage, includeUsage bool) {
+	common.SetEventStreamHeaders(c)
+	newChunk := func(choices ...openai.ChatCompletionsStreamResponseChoice) *openai.ChatCompletionsStreamResponse {
+		return &openai.ChatCompletionsStreamResponse{
+			Id:      textResponse.Id,
+			Object:  "chat.completion.chunk",
+			Created: textResponse.Created,
+			Model:   textResponse.Model,
+			Choices: choices,
+		}
+	}
+	var chunks []*openai.ChatCompletionsStreamResponse
+	for _, choice := range textResponse.Choices {
+		delta := openai.ChatCompletionsStreamResponseChoice{Index: choice.Index}
+		delta.Delta.Role = "assistant"
+		delta.Delta.Content = ""
+		chunks = append(chunks, newChunk(delta))
+		if reasoning := conv.AsString(choice.ReasoningContent); reasoning != "" {
+			for _, text := range splitByRunes(reasoning, fakeStreamChunkSize) {
+				delta := openai.ChatCompletionsStreamResponseChoice{Index: choice.Index}
+				delta.Delta.ReasoningContent = text
+				chunks = append(chunks, newChunk(delta))
+			}
+		}
+		for _, text := range splitByRunes(choice.StringContent(), fakeStreamChunkSize) {
+			delta := openai.ChatCompletionsStreamResponseChoice{Index: choice.Index}
+			delta.Delta.Content = text
+			chunks = append(chunks, newChunk(delta))
+		}
+		for i, tool := range choice.ToolCalls {
+			index := i
+			tool.Index = &index
+			delta := openai.ChatCompletionsStreamResponseChoice{Index: choice.Index}
+			delta.Delta.ToolCalls = []model.Tool{tool}
+			chunks = append(chunks, newChunk(delta))
+		}
+		finishReason := choice.FinishReason
+		delta = openai.ChatCompletionsStreamResponseChoice{Index: choice.Index, FinishReason: &finishReason}
+		chunks = append(chunks, newChunk(delta))
+	}
+	if includeUsage {
+		chunk := newChunk()
+		chunk.Choices = []openai.ChatCompletionsStreamResponseChoice{}
+		chunk.Usage = usage
+		chunks = append(chunks, chunk)
+	}
+	for _, chunk := range chunks {
+		err := render.ObjectData(c, chunk)
+		if err != nil {
+			logger.SysError(err.Error())
+		}
+	}
+	render.Done(c)
+}
+
+// aggregateStream merges the chat completion chunks into one response, tool call deltas included
+func aggregateStream(body string) *openai.TextResponse {
+	textResponse := &openai.TextResponse{
+		Object:  "chat.completion",
+		Created: helper.GetTimestamp(),
+	}
+	type choiceBuilder struct {
+		content      strings.Builder
+		reasoning    strings.Builder
+		toolCalls    []model.Tool
+		finishReason string
+	}
+	var builders []*choiceBuilder
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk openai.ChatCompletionsStreamResponse
+		err := json.Unmarshal([]byte(data), &chunk)
+		if err != nil {
+			logger.SysError("error unmarshalling stream response: " + err.Error())
+			continue
+		}
+		if chunk.Id != "" {
+			textResponse.Id = chunk.Id
+		}
+		if chunk.Model != "" {
+			textResponse.Model = chunk.Model
+		}
+		if chunk.Created != 0 {
+			textResponse.Created = chunk.Created
+		}
+		if chunk.Usage != nil {
+			textResponse.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			for len(builders) <= choice.Index {
+				builders = append(builders, &choiceBuilder{})
+			}
+			builder := builders[choice.Index]
+			builder.content.WriteString(choice.Delta.StringContent())
+			builder.reasoning.WriteString(conv.AsString(choice.Delta.ReasoningContent))
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				builder.finishReason = *choice.FinishReason
+			}
+			for _, tool := range choice.Delta.ToolCalls {
+				builder.toolCalls = mergeToolCallDelta(builder.toolCalls, tool)
+			}
+		}
+	}
+	for i, builder := range builders {
+		choice := openai.TextResponseChoice{
+			Index: i,
+			Message: model.Message{
+				Role:    "assistant",
+				Content: builder.content.String(),
+			},
+			FinishReason: builder.finishReason,
+		}
+		if builder.reasoning.Len() > 0 {
+			choice.ReasoningContent = builder.reasoning.String()
+		}
+		for j := range builder.toolCalls {
+			builder.toolCalls[j].Index = nil
+		}
+		if len(builder.toolCalls) > 0 {
+			choice.ToolCalls = builder.toolCalls
+		}
+		textResponse.Choices = append(textResponse.Choices, choice)
+	}
+	return textResponse
+}
+
+// mergeToolCallDelta appends the delta to the tool call with the same index,
+// deltas without index start a new tool call when they carry an id, otherwise they belong to the last one
+func mergeToolCallDelta(toolCalls []model.Tool, delta model.Tool) []model.Tool {
+	position := -1
+	if delta.Index != nil {
+		for i := range toolCalls {
+			if toolCalls[i].Index != nil && *toolCalls[i].Index == *delta.Index {
+				position = i
+				break
+			}
+		}
+	} else if delta.Id == "" && len(toolCalls) > 0 {
+		position = len(toolCalls) - 1
+	}
+	if position == -1 {
+		delta.Function.Arguments = conv.AsString(delta.Function.Arguments)
+		return append(toolCalls, delta)
+	}
+	toolCall := &toolCalls[position]
+	if delta.Id != "" {
+		toolCall.Id = delta.Id
+	}
+	if delta.Type != "" {
+		toolCall.Type = delta.Type
+	}
+	if toolCall.Function.Name == "" {
+		toolCall.Function.Name = delta.Function.Name
+	}
+	toolCall.Function.Arguments = conv.AsString(toolCall.Function.Arguments) + conv.AsString(delta.Function.Arguments)
+	return toolCalls
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+func TestAggregateStream(t *testing.T) {
+	Convey("aggregate stream chunks", t, func() {
+		body := `data: {"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"lo"}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}
+
+data: {"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}
+
+data: [DONE]
+
+`
+		textResponse := aggregateStream(body)
+		So(textResponse.Id, ShouldEqual, "chatcmpl-1")
+		So(textResponse.Model, ShouldEqual, "gpt-4o")
+		So(len(textResponse.Choices), ShouldEqual, 1)
+		choice := textResponse.Choices[0]
+		So(choice.StringContent(), ShouldEqual, "Hello")
+		So(choice.FinishReason, ShouldEqual, "tool_calls")
+		So(len(choice.ToolCalls), ShouldEqual, 1)
+		So(choice.ToolCalls[0].Function.Name, ShouldEqual, "get_weather")
+		So(choice.ToolCalls[0].Function.Arguments, ShouldEqual, `{"city":"Paris"}`)
+		So(choice.ToolCalls[0].Index, ShouldBeNil)
+		So(textResponse.Usage.TotalTokens, ShouldEqual, 8)
+	})
+}
+
+func TestApplyStreamConversion(t *testing.T) {
+	Convey("apply the stream conversion of the channel", t, func() {
+		Convey("a model without stream support is not converted unless the channel asks for it", func() {
+			relayMeta := &meta.Meta{Mode: relaymode.ChatCompletions, ActualModelName: "o1", IsStream: true}
+			textRequest := &model.GeneralOpenAIRequest{Model: "o1", Stream: true}
+			So(applyStreamConversion(relayMeta, textRequest), ShouldBeNil)
+			So(textRequest.Stream, ShouldBeTrue)
+			So(relayMeta.StreamConversion, ShouldEqual, "")
+		})
+		Convey("fake stream configured for the model", func() {
+			relayMeta := &meta.Meta{
+				Mode:            relaymode.ChatCompletions,
+				ActualModelName: "o1",
+				IsStream:        true,
+				Config:          dbmodel.ChannelConfig{StreamConversion: map[string]string{"o1": StreamConversionFake}},
+			}
+			textRequest := &model.GeneralOpenAIRequest{Model: "o1", Stream: true, StreamOptions: &model.StreamOptions{IncludeUsage: true}}
+			conversion := applyStreamConversion(relayMeta, textRequest)
+			So(conversion, ShouldNotBeNil)
+			So(conversion.includeUsage, ShouldBeTrue)
+			So(textRequest.Stream, ShouldBeFalse)
+			So(relayMeta.IsStream, ShouldBeFalse)
+			So(relayMeta.StreamConversion, ShouldEqual, StreamConversionFake)
+		})
+	})
+}
@@ -18,8 +18,10 @@ import (
 	"github.com/songquanpeng/one-api/relay/billing"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/controller/validator"
 	"github.com/songquanpeng/one-api/relay/meta"
 	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
 func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
@@ -37,6 +39,11 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	meta.OriginModelName = textRequest.Model
 	textRequest.Model, _ = getMappedModelName(textRequest.Model, meta.ModelMapping)
 	meta.ActualModelName = textRequest.Model
+	if meta.Mode == relaymode.ChatCompletions {
+		if err := validator.ValidateModelCapability(textRequest, meta.Config.GetStreamConversion(meta.ActualModelName) == StreamConversionFake); err != nil {
+			return openai.ErrorWrapper(err, "invalid_text_request", http.StatusBadRequest)
+		}
+	}
 	// set system prompt if not empty
 	systemPromptReset := setSystemPrompt(ctx, textRequest, meta.ForcedSystemPrompt)
 	// get model ratio & group ratio
@@ -46,6 +53,15 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	// pre-consume quota
 	promptTokens := getPromptTokens(textRequest, meta.Mode)
 	meta.PromptTokens = promptTokens
+	if meta.Mode == relaymode.ChatCompletions {
+		if meta.ContextFit {
+			promptTokens = fitContextWindow(c, meta, textRequest, promptTokens)
+			meta.PromptTokens = promptTokens
+		}
+		if err := validator.ValidateContextWindow(textRequest, promptTokens); err != nil {
+			return openai.ErrorWrapper(err, "context_length_exceeded", http.StatusBadRequest)
+		}
+	}
 	preConsumedQuota, bizErr := preConsumeQuota(ctx, textRequest, promptTokens, ratio, meta)
 	if bizErr != nil {
 		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
@@ -58,6 +74,22 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	}
 	adaptor.Init(meta)
 
+	// emulate n > 1 for the upstreams which can only return one choice
+	if shouldEmulateMultipleChoices(meta, textRequest) {
+		usage, respErr := relayMultipleChoices(c, meta, textRequest)
+		if respErr != nil && (usage == nil || usage.TotalTokens == 0) {
+			billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId, meta.OriginModelName)
+			return respErr
+		}
+		// the choices which succeeded are billed even if another one failed
+		recordUsedTokens(c, usage)
+		go postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio, systemPromptReset)
+		return respErr
+	}
+
+	// convert between stream and non-stream if the upstream only supports one of them
+	conversion := applyStreamConversion(meta, textRequest)
+
 	// get request body
 	requestBody, err := getRequestBody(c, meta, textRequest, adaptor)
 	if err != nil {
@@ -71,18 +103,25 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
 	if isErrorHappened(meta, resp) {
-		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId, meta.OriginModelName)
 		return RelayErrorHandler(resp)
 	}
 
 	// do response
-	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	var usage *model.Usage
+	var respErr *model.ErrorWithStatusCode
+	if conversion != nil {
+		usage, respErr = conversion.doResponse(c, resp, meta, adaptor)
+	} else {
+		usage, respErr = adaptor.DoResponse(c, resp, meta)
+	}
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
-		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId, meta.OriginModelName)
 		return respErr
 	}
 	// post-consume quota
+	recordUsedTokens(c, usage)
 	go postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio, systemPromptReset)
 	return nil
 }
@@ -92,7 +131,9 @@ func getRequestBody(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralO
 		meta.APIType == apitype.OpenAI &&
 		meta.OriginModelName == meta.ActualModelName &&
 		meta.ChannelType != channeltype.Baichuan &&
-		meta.ForcedSystemPrompt == "" {
+		meta.ForcedSystemPrompt == "" &&
+		meta.StreamConversion == "" &&
+		!meta.ContextFitted {
 		// no need to convert request for openai
 		return c.Request.Body, nil
 	}
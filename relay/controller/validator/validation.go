@@ -2,7 +2,9 @@ package validator
 
 import (
 	"errors"
+	"fmt"
 	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/modelinfo"
 	"github.com/songquanpeng/one-api/relay/relaymode"
 	"math"
 )
@@ -35,3 +37,35 @@ func ValidateTextRequest(textRequest *model.GeneralOpenAIRequest, relayMode int)
 	}
 	return nil
 }
+
+// ValidateModelCapability rejects the requests which the model can never serve,
+// it should be called with the mapped model name, since that is the model which serves the request,
+// fakeStream tells that the channel answers the stream requests with non-stream upstream requests
+func ValidateModelCapability(textRequest *model.GeneralOpenAIRequest, fakeStream bool) error {
+	info, ok := modelinfo.GetModelInfo(textRequest.Model)
+	if !ok {
+		return nil
+	}
+	if info.MaxOutputTokens > 0 && textRequest.MaxTokens > info.MaxOutputTokens {
+		return fmt.Errorf("max_tokens is too large, model %s supports at most %d output tokens", textRequest.Model, info.MaxOutputTokens)
+	}
+	requirement := modelinfo.GetRequirement(textRequest)
+	requirement.Stream = requirement.Stream && !fakeStream
+	if err := info.CheckRequirement(requirement); err != nil {
+		return fmt.Errorf("model %s: %w", textRequest.Model, err)
+	}
+	return nil
+}
+
+// ValidateContextWindow checks whether the prompt and the max output tokens fit in the context window of the model
+func ValidateContextWindow(textRequest *model.GeneralOpenAIRequest, promptTokens int) error {
+	info, ok := modelinfo.GetModelInfo(textRequest.Model)
+	if !ok || info.ContextWindow <= 0 {
+		return nil
+	}
+	if promptTokens+textRequest.MaxTokens > info.ContextWindow {
+		return fmt.Errorf("this model's maximum context length is %d tokens, however you requested %d tokens (%d in the messages, %d in the completion)",
+			info.ContextWindow, promptTokens+textRequest.MaxTokens, promptTokens, textRequest.MaxTokens)
+	}
+	return nil
+}
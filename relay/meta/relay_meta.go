@@ -13,14 +13,16 @@ import (
 )
 
 type Meta struct {
-	Mode         int
-	ChannelType  int
-	ChannelId    int
-	TokenId      int
-	TokenName    string
-	UserId       int
-	Group        string
-	ModelMapping map[string]string
+	Mode        int
+	ChannelType int
+	ChannelId   int
+	TokenId     int
+	TokenName   string
+	UserId      int
+	// OrganizationId is set when the token draws from the quota pool of an organization
+	OrganizationId int
+	Group          string
+	ModelMapping   map[string]string
 	// BaseURL is the proxy url set in the channel config
 	BaseURL  string
 	APIKey   string
@@ -35,6 +37,11 @@ type Meta struct {
 	PromptTokens       int // only for DoResponse
 	ForcedSystemPrompt string
 	StartTime          time.Time
+	// StreamConversion is set when the stream mode sent to upstream differs from the client's
+	StreamConversion string
+	// ContextFit enables trimming the messages to fit the context window, ContextFitted is set once they are trimmed
+	ContextFit    bool
+	ContextFitted bool
 }
 
 func GetByContext(c *gin.Context) *Meta {
@@ -45,6 +52,7 @@ func GetByContext(c *gin.Context) *Meta {
 		TokenId:            c.GetInt(ctxkey.TokenId),
 		TokenName:          c.GetString(ctxkey.TokenName),
 		UserId:             c.GetInt(ctxkey.Id),
+		OrganizationId:     c.GetInt(ctxkey.OrganizationId),
 		Group:              c.GetString(ctxkey.Group),
 		ModelMapping:       c.GetStringMapString(ctxkey.ModelMapping),
 		OriginModelName:    c.GetString(ctxkey.RequestModel),
@@ -52,6 +60,7 @@ func GetByContext(c *gin.Context) *Meta {
 		APIKey:             strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "),
 		RequestURLPath:     c.Request.URL.String(),
 		ForcedSystemPrompt: c.GetString(ctxkey.SystemPrompt),
+		ContextFit:         c.GetBool(ctxkey.ContextFit),
 		StartTime:          time.Now(),
 	}
 	cfg, ok := c.Get(ctxkey.Config)
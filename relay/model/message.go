@@ -1,5 +1,7 @@
 package model
 
+import "strings"
+
 type Message struct {
 	Role             string  `json:"role,omitempty"`
 	Content          any     `json:"content,omitempty"`
@@ -72,6 +74,30 @@ func (m Message) ParseContent() []MessageContent {
 						},
 					})
 				}
+			case ContentTypeInputAudio:
+				if subObj, ok := contentMap["input_audio"].(map[string]any); ok {
+					data, _ := subObj["data"].(string)
+					format, _ := subObj["format"].(string)
+					contentList = append(contentList, MessageContent{
+						Type: ContentTypeInputAudio,
+						InputAudio: &InputAudio{
+							Data:   data,
+							Format: format,
+						},
+					})
+				}
+			case ContentTypeFile:
+				if subObj, ok := contentMap["file"].(map[string]any); ok {
+					fileData, _ := subObj["file_data"].(string)
+					filename, _ := subObj["filename"].(string)
+					contentList = append(contentList, MessageContent{
+						Type: ContentTypeFile,
+						File: &File{
+							FileData: fileData,
+							Filename: filename,
+						},
+					})
+				}
 			}
 		}
 		return contentList
@@ -84,8 +110,51 @@ type ImageURL struct {
 	Detail string `json:"detail,omitempty"`
 }
 
+type InputAudio struct {
+	// Data is the base64 encoded audio data
+	Data   string `json:"data,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+// MimeType returns the mime type of the audio, e.g. wav -> audio/wav
+func (a *InputAudio) MimeType() string {
+	switch a.Format {
+	case "mp3", "mpeg":
+		return "audio/mp3"
+	case "":
+		return "audio/wav"
+	default:
+		return "audio/" + a.Format
+	}
+}
+
+type File struct {
+	// FileData is a data url, e.g. data:application/pdf;base64,xxx
+	FileData string `json:"file_data,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// Parse splits the data url into mime type and base64 encoded data,
+// raw base64 data without a data url prefix is treated as pdf
+func (f *File) Parse() (mimeType string, data string) {
+	if !strings.HasPrefix(f.FileData, "data:") {
+		return "application/pdf", f.FileData
+	}
+	header, data, found := strings.Cut(strings.TrimPrefix(f.FileData, "data:"), ",")
+	if !found {
+		return "application/pdf", ""
+	}
+	mimeType = strings.TrimSuffix(header, ";base64")
+	if mimeType == "" {
+		mimeType = "application/pdf"
+	}
+	return mimeType, data
+}
+
 type MessageContent struct {
-	Type     string    `json:"type,omitempty"`
-	Text     string    `json:"text"`
-	ImageURL *ImageURL `json:"image_url,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	Text       string      `json:"text"`
+	ImageURL   *ImageURL   `json:"image_url,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+	File       *File       `json:"file,omitempty"`
 }
@@ -0,0 +1,25 @@
+package model
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestParseContent(t *testing.T) {
+	Convey("parse audio and file content", t, func() {
+		message := Message{
+			Role: "user",
+			Content: []any{
+				map[string]any{"type": "text", "text": "summarize"},
+				map[string]any{"type": "input_audio", "input_audio": map[string]any{"data": "UklGRg==", "format": "mp3"}},
+				map[string]any{"type": "file", "file": map[string]any{"filename": "a.pdf", "file_data": "data:application/pdf;base64,JVBERi0="}},
+			},
+		}
+		contents := message.ParseContent()
+		So(len(contents), ShouldEqual, 3)
+		So(contents[1].InputAudio.MimeType(), ShouldEqual, "audio/mp3")
+		mimeType, data := contents[2].File.Parse()
+		So(mimeType, ShouldEqual, "application/pdf")
+		So(data, ShouldEqual, "JVBERi0=")
+	})
+}
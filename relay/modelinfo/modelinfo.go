@@ -0,0 +1,188 @@
+package modelinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// ModelInfo describes the limits and capabilities of a model
+type ModelInfo struct {
+	ContextWindow   int  `json:"context_window,omitempty"`
+	MaxOutputTokens int  `json:"max_output_tokens,omitempty"`
+	Vision          bool `json:"vision"`
+	Tools           bool `json:"tools"`
+	JSONSchema      bool `json:"json_schema"`
+	Audio           bool `json:"audio"`
+	Stream          bool `json:"stream"`
+}
+
+var modelInfoLock sync.RWMutex
+
+// versionSuffixPattern matches the suffixes of the dated variants, e.g. 2024-08-06, 20241022, 0613, 002 and latest
+var versionSuffixPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}|\d{3,8}|latest)$`)
+
+// DefaultModelInfo
+// https://platform.openai.com/docs/models
+// https://docs.anthropic.com/en/docs/about-claude/models
+// https://ai.google.dev/gemini-api/docs/models/gemini
+// a model name also matches the dated variants of it, e.g. gpt-4o matches gpt-4o-2024-08-06
+var DefaultModelInfo = map[string]ModelInfo{
+	"gpt-3.5-turbo":        {ContextWindow: 16385, MaxOutputTokens: 4096, Tools: true, Stream: true},
+	"gpt-4":                {ContextWindow: 8192, MaxOutputTokens: 8192, Tools: true, Stream: true},
+	"gpt-4-32k":            {ContextWindow: 32768, MaxOutputTokens: 8192, Tools: true, Stream: true},
+	"gpt-4-1106-preview":   {ContextWindow: 128000, MaxOutputTokens: 4096, Tools: true, Stream: true},
+	"gpt-4-0125-preview":   {ContextWindow: 128000, MaxOutputTokens: 4096, Tools: true, Stream: true},
+	"gpt-4-turbo-preview":  {ContextWindow: 128000, MaxOutputTokens: 4096, Tools: true, Stream: true},
+	"gpt-4-turbo":          {ContextWindow: 128000, MaxOutputTokens: 4096, Vision: true, Tools: true, Stream: true},
+	"gpt-4o":               {ContextWindow: 128000, MaxOutputTokens: 16384, Vision: true, Tools: true, JSONSchema: true, Stream: true},
+	"gpt-4o-mini":          {ContextWindow: 128000, MaxOutputTokens: 16384, Vision: true, Tools: true, JSONSchema: true, Stream: true},
+	"gpt-4o-audio-preview": {ContextWindow: 128000, MaxOutputTokens: 16384, Tools: true, Audio: true, Stream: true},
+	"chatgpt-4o-latest":    {ContextWindow: 128000, MaxOutputTokens: 16384, Vision: true, Stream: true},
+	"o1":                   {ContextWindow: 200000, MaxOutputTokens: 100000, Vision: true, Tools: true, JSONSchema: true},
+	"o1-preview":           {ContextWindow: 128000, MaxOutputTokens: 32768, Stream: true},
+	"o1-mini":              {ContextWindow: 128000, MaxOutputTokens: 65536, Stream: true},
+	"o3-mini":              {ContextWindow: 200000, MaxOutputTokens: 100000, Tools: true, JSONSchema: true, Stream: true},
+	"claude-2.0":           {ContextWindow: 100000, MaxOutputTokens: 4096, Stream: true},
+	"claude-2.1":           {ContextWindow: 200000, MaxOutputTokens: 4096, Stream: true},
+	"claude-3-haiku":       {ContextWindow: 200000, MaxOutputTokens: 4096, Vision: true, Tools: true, Stream: true},
+	"claude-3-sonnet":      {ContextWindow: 200000, MaxOutputTokens: 4096, Vision: true, Tools: true, Stream: true},
+	"claude-3-opus":        {ContextWindow: 200000, MaxOutputTokens: 4096, Vision: true, Tools: true, Stream: true},
+	"claude-3-5-haiku":     {ContextWindow: 200000, MaxOutputTokens: 8192, Tools: true, Stream: true},
+	"claude-3-5-sonnet":    {ContextWindow: 200000, MaxOutputTokens: 8192, Vision: true, Tools: true, Stream: true},
+	"gemini-pro":           {ContextWindow: 32760, MaxOutputTokens: 8192, Tools: true, Stream: true},
+	"gemini-1.0-pro":       {ContextWindow: 32760, MaxOutputTokens: 8192, Tools: true, Stream: true},
+	"gemini-1.5-pro":       {ContextWindow: 2097152, MaxOutputTokens: 8192, Vision: true, Tools: true, JSONSchema: true, Audio: true, Stream: true},
+	"gemini-1.5-flash":     {ContextWindow: 1048576, MaxOutputTokens: 8192, Vision: true, Tools: true, JSONSchema: true, Audio: true, Stream: true},
+	"gemini-2.0-flash":     {ContextWindow: 1048576, MaxOutputTokens: 8192, Vision: true, Tools: true, JSONSchema: true, Audio: true, Stream: true},
+	"gemini-2.0-pro":       {ContextWindow: 2097152, MaxOutputTokens: 8192, Vision: true, Tools: true, JSONSchema: true, Audio: true, Stream: true},
+	"deepseek-chat":        {ContextWindow: 65536, MaxOutputTokens: 8192, Tools: true, Stream: true},
+	"deepseek-reasoner":    {ContextWindow: 65536, MaxOutputTokens: 8192, Stream: true},
+}
+
+// modelInfo only holds the entries overridden by admin, the others fall back to DefaultModelInfo
+var modelInfo = map[string]ModelInfo{}
+
+func ModelInfo2JSONString() string {
+	modelInfoLock.RLock()
+	defer modelInfoLock.RUnlock()
+	jsonBytes, err := json.Marshal(modelInfo)
+	if err != nil {
+		logger.SysError("error marshalling model info: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelInfoByJSONString(jsonStr string) error {
+	newModelInfo := make(map[string]ModelInfo)
+	err := json.Unmarshal([]byte(jsonStr), &newModelInfo)
+	if err != nil {
+		return err
+	}
+	modelInfoLock.Lock()
+	defer modelInfoLock.Unlock()
+	modelInfo = newModelInfo
+	return nil
+}
+
+// GetModelInfo returns the info of the model, the second return value is false for unknown models,
+// which should be treated as capable of everything
+func GetModelInfo(name string) (ModelInfo, bool) {
+	modelInfoLock.RLock()
+	defer modelInfoLock.RUnlock()
+	if info, ok := modelInfo[name]; ok {
+		return info, true
+	}
+	if info, ok := DefaultModelInfo[name]; ok {
+		return info, true
+	}
+	// match the longest prefix followed by a date or version, e.g. claude-3-5-sonnet-20241022 -> claude-3-5-sonnet,
+	// other suffixes name another model, e.g. gpt-4-vision-preview is not gpt-4
+	var matched string
+	var matchedInfo ModelInfo
+	for _, infos := range []map[string]ModelInfo{modelInfo, DefaultModelInfo} {
+		for prefix, info := range infos {
+			if len(prefix) > len(matched) && strings.HasPrefix(name, prefix+"-") && versionSuffixPattern.MatchString(name[len(prefix)+1:]) {
+				matched = prefix
+				matchedInfo = info
+			}
+		}
+	}
+	return matchedInfo, matched != ""
+}
+
+// Requirement is the capabilities needed to serve a request
+type Requirement struct {
+	Vision     bool
+	Tools      bool
+	JSONSchema bool
+	Audio      bool
+	Stream     bool
+}
+
+func (r Requirement) IsEmpty() bool {
+	return r == Requirement{}
+}
+
+func GetRequirement(request *model.GeneralOpenAIRequest) Requirement {
+	var requirement Requirement
+	requirement.Stream = request.Stream
+	requirement.Tools = len(request.Tools) > 0 || request.Functions != nil
+	requirement.JSONSchema = request.ResponseFormat != nil && request.ResponseFormat.JsonSchema != nil
+	for _, modality := range request.Modalities {
+		if modality == "audio" {
+			requirement.Audio = true
+		}
+	}
+	for _, message := range request.Messages {
+		if message.IsStringContent() {
+			continue
+		}
+		for _, content := range message.ParseContent() {
+			switch content.Type {
+			case model.ContentTypeImageURL:
+				requirement.Vision = true
+			case model.ContentTypeInputAudio:
+				requirement.Audio = true
+			}
+		}
+	}
+	return requirement
+}
+
+// CheckRequirement returns an error describing the first capability the model lacks
+func (info ModelInfo) CheckRequirement(requirement Requirement) error {
+	if requirement.Vision && !info.Vision {
+		return errors.New("image input is not supported")
+	}
+	if requirement.Tools && !info.Tools {
+		return errors.New("tools are not supported")
+	}
+	if requirement.JSONSchema && !info.JSONSchema {
+		return errors.New("response_format json_schema is not supported")
+	}
+	if requirement.Audio && !info.Audio {
+		return errors.New("audio is not supported")
+	}
+	if requirement.Stream && !info.Stream {
+		return errors.New("stream is not supported")
+	}
+	return nil
+}
+
+// IsModelSatisfied reports whether the model can serve a request with the requirement
+func IsModelSatisfied(name string, requirement Requirement) bool {
+	if requirement.IsEmpty() {
+		return true
+	}
+	info, ok := GetModelInfo(name)
+	if !ok {
+		return true
+	}
+	return info.CheckRequirement(requirement) == nil
+}
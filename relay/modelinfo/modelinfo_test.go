@@ -0,0 +1,71 @@
+package modelinfo
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+func TestGetModelInfo(t *testing.T) {
+	Convey("GetModelInfo", t, func() {
+		Convey("exact match", func() {
+			info, ok := GetModelInfo("gpt-4o-mini")
+			So(ok, ShouldBeTrue)
+			So(info.ContextWindow, ShouldEqual, 128000)
+		})
+		Convey("dated variant matches the longest prefix", func() {
+			info, ok := GetModelInfo("gpt-4o-mini-2024-07-18")
+			So(ok, ShouldBeTrue)
+			So(info, ShouldResemble, DefaultModelInfo["gpt-4o-mini"])
+			info, ok = GetModelInfo("claude-3-5-sonnet-20241022")
+			So(ok, ShouldBeTrue)
+			So(info.MaxOutputTokens, ShouldEqual, 8192)
+		})
+		Convey("only date and version suffixes match a known model", func() {
+			cases := []struct {
+				name    string
+				matched string
+			}{
+				{"gpt-4-0613", "gpt-4"},
+				{"gpt-4-turbo-2024-04-09", "gpt-4-turbo"},
+				{"claude-3-5-sonnet-latest", "claude-3-5-sonnet"},
+				{"gemini-1.5-pro-002", "gemini-1.5-pro"},
+				{"o1-2024-12-17", "o1"},
+				{"gpt-4-vision-preview", ""},
+				{"gemini-pro-vision", ""},
+				{"gpt-4o-realtime-preview", ""},
+				{"gemini-1.5-flash-8b", ""},
+			}
+			for _, tc := range cases {
+				info, ok := GetModelInfo(tc.name)
+				So(ok, ShouldEqual, tc.matched != "")
+				if tc.matched != "" {
+					So(info, ShouldResemble, DefaultModelInfo[tc.matched])
+				}
+			}
+		})
+		Convey("unknown model", func() {
+			_, ok := GetModelInfo("my-custom-model")
+			So(ok, ShouldBeFalse)
+			So(IsModelSatisfied("my-custom-model", Requirement{Vision: true}), ShouldBeTrue)
+		})
+		Convey("stream", func() {
+			requirement := GetRequirement(&model.GeneralOpenAIRequest{Stream: true})
+			So(requirement, ShouldResemble, Requirement{Stream: true})
+			So(IsModelSatisfied("gpt-4o", requirement), ShouldBeTrue)
+			So(IsModelSatisfied("o1-2024-12-17", requirement), ShouldBeFalse)
+			So(IsModelSatisfied("o1", Requirement{}), ShouldBeTrue)
+		})
+		Convey("admin override", func() {
+			err := UpdateModelInfoByJSONString(`{"gpt-4o":{"context_window":1000,"stream":true}}`)
+			So(err, ShouldBeNil)
+			defer UpdateModelInfoByJSONString("{}")
+			info, ok := GetModelInfo("gpt-4o-2024-08-06")
+			So(ok, ShouldBeTrue)
+			So(info.ContextWindow, ShouldEqual, 1000)
+			So(IsModelSatisfied("gpt-4o", Requirement{Vision: true}), ShouldBeFalse)
+		})
+	})
+}
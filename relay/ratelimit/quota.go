@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// QuotaCap caps the quota consumed by a subject that has no row of its own, such as a relay jwt,
+// the usage is kept in the rate limit store and forgotten when it expires
+type QuotaCap struct {
+	// Name describes the subject in error messages
+	Name       string
+	Subject    string
+	Limit      int64
+	Expiration time.Duration
+}
+
+type quotaCapsKey struct{}
+
+func quotaCapKey(subject string) string {
+	return "relayQuotaCap:" + subject
+}
+
+// WithQuotaCap attaches the cap to the request context, the quota consumed by the request is counted against it
+func WithQuotaCap(ctx context.Context, quotaCap QuotaCap) context.Context {
+	caps := append(getQuotaCaps(ctx), quotaCap)
+	return context.WithValue(ctx, quotaCapsKey{}, caps)
+}
+
+func getQuotaCaps(ctx context.Context) []QuotaCap {
+	caps, _ := ctx.Value(quotaCapsKey{}).([]QuotaCap)
+	// copy so that contexts derived from the same parent don't share the backing array
+	return append([]QuotaCap(nil), caps...)
+}
+
+// CheckQuotaCaps returns an error if consuming quota would exceed one of the caps of the request,
+// quota 0 only checks whether a cap is used up
+func CheckQuotaCaps(ctx context.Context, quota int64) error {
+	for _, quotaCap := range getQuotaCaps(ctx) {
+		used, err := get(ctx, quotaCapKey(quotaCap.Subject))
+		if err != nil {
+			return err
+		}
+		if remain := quotaCap.Limit - used; remain <= 0 || remain < quota {
+			return fmt.Errorf("%s额度已用尽", quotaCap.Name)
+		}
+	}
+	return nil
+}
+
+// RecordQuota adds the quota to the caps of the request, negative quota gives it back
+func RecordQuota(ctx context.Context, quota int64) {
+	if quota == 0 {
+		return
+	}
+	for _, quotaCap := range getQuotaCaps(ctx) {
+		// quota is recorded after the response is sent, when the request context may be canceled already
+		_, err := incrBy(context.Background(), quotaCapKey(quotaCap.Subject), quota, quotaCap.Expiration)
+		if err != nil {
+			logger.Error(ctx, fmt.Sprintf("failed to record quota of %s: %s", quotaCap.Subject, err.Error()))
+		}
+	}
+}
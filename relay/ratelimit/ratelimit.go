@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// Limit is the rate limit of a token or a user group, 0 means unlimited
+type Limit struct {
+	RPM         int `json:"rpm"`
+	TPM         int `json:"tpm"`
+	Concurrency int `json:"concurrency"`
+}
+
+func (l Limit) IsEmpty() bool {
+	return l == Limit{}
+}
+
+var groupRateLimitLock sync.RWMutex
+
+// GroupRateLimit is applied to every user of the group
+var GroupRateLimit = map[string]Limit{}
+
+func GroupRateLimit2JSONString() string {
+	groupRateLimitLock.RLock()
+	defer groupRateLimitLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupRateLimit)
+	if err != nil {
+		logger.SysError("error marshalling group rate limit: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupRateLimitByJSONString(jsonStr string) error {
+	newGroupRateLimit := make(map[string]Limit)
+	err := json.Unmarshal([]byte(jsonStr), &newGroupRateLimit)
+	if err != nil {
+		return err
+	}
+	groupRateLimitLock.Lock()
+	defer groupRateLimitLock.Unlock()
+	GroupRateLimit = newGroupRateLimit
+	return nil
+}
+
+func GetGroupRateLimit(group string) Limit {
+	groupRateLimitLock.RLock()
+	defer groupRateLimitLock.RUnlock()
+	return GroupRateLimit[group]
+}
+
+// Status describes a fixed one minute window of a limit
+type Status struct {
+	Limit     int
+	Remaining int
+	// Reset is the seconds until the window resets
+	Reset int
+}
+
+const window = 60
+
+func currentWindow() (int64, int) {
+	now := time.Now().Unix()
+	return now / window, int(window - now%window)
+}
+
+func windowKey(kind string, subject string) (string, int) {
+	current, reset := currentWindow()
+	return fmt.Sprintf("relayRateLimit:%s:%s:%d", kind, subject, current), reset
+}
+
+func newStatus(limit int, used int64, reset int) Status {
+	remaining := limit - int(used)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Status{Limit: limit, Remaining: remaining, Reset: reset}
+}
+
+// CheckRequests counts a request of the subject, false is returned if the requests per minute exceed the limit
+func CheckRequests(ctx context.Context, subject string, limit int) (Status, bool, error) {
+	key, reset := windowKey("rpm", subject)
+	used, err := incrBy(ctx, key, 1, 2*window*time.Second)
+	if err != nil {
+		return Status{}, false, err
+	}
+	return newStatus(limit, used, reset), used <= int64(limit), nil
+}
+
+// CheckTokens reports whether the subject has tokens left in the current minute
+func CheckTokens(ctx context.Context, subject string, limit int) (Status, bool, error) {
+	key, reset := windowKey("tpm", subject)
+	used, err := get(ctx, key)
+	if err != nil {
+		return Status{}, false, err
+	}
+	return newStatus(limit, used, reset), used < int64(limit), nil
+}
+
+// RecordTokens adds the tokens consumed by a finished request to the current minute
+func RecordTokens(ctx context.Context, subject string, tokens int) error {
+	key, _ := windowKey("tpm", subject)
+	_, err := incrBy(ctx, key, int64(tokens), 2*window*time.Second)
+	return err
+}
+
+// Acquire takes a concurrency slot of the subject, Release must be called if true is returned
+func Acquire(ctx context.Context, subject string, limit int) (bool, error) {
+	key := "relayRateLimit:concurrency:" + subject
+	// the expiration frees the slots leaked by crashed instances
+	running, err := incrBy(ctx, key, 1, config.RateLimitKeyExpirationDuration)
+	if err != nil {
+		return false, err
+	}
+	if running > int64(limit) {
+		_, err = incrBy(ctx, key, -1, config.RateLimitKeyExpirationDuration)
+		return false, err
+	}
+	return true, nil
+}
+
+func Release(ctx context.Context, subject string) error {
+	key := "relayRateLimit:concurrency:" + subject
+	_, err := incrBy(ctx, key, -1, config.RateLimitKeyExpirationDuration)
+	return err
+}
+
+var inMemoryRateLimiter common.InMemoryRateLimiter
+
+func incrBy(ctx context.Context, key string, value int64, expiration time.Duration) (int64, error) {
+	if !common.RedisEnabled {
+		inMemoryRateLimiter.Init(config.RateLimitKeyExpirationDuration)
+		return inMemoryRateLimiter.IncrBy(key, value, expiration), nil
+	}
+	pipe := common.RDB.TxPipeline()
+	incr := pipe.IncrBy(ctx, key, value)
+	pipe.Expire(ctx, key, expiration)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func get(ctx context.Context, key string) (int64, error) {
+	if !common.RedisEnabled {
+		inMemoryRateLimiter.Init(config.RateLimitKeyExpirationDuration)
+		return inMemoryRateLimiter.Get(key), nil
+	}
+	value, err := common.RDB.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return value, err
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common"
+)
+
+func TestRateLimit(t *testing.T) {
+	common.RedisEnabled = false
+	ctx := context.Background()
+	Convey("in memory rate limit", t, func() {
+		Convey("requests per minute", func() {
+			for i := 0; i < 2; i++ {
+				_, ok, err := CheckRequests(ctx, "test:rpm", 2)
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+			}
+			status, ok, err := CheckRequests(ctx, "test:rpm", 2)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(status.Remaining, ShouldEqual, 0)
+		})
+		Convey("tokens per minute", func() {
+			status, ok, _ := CheckTokens(ctx, "test:tpm", 100)
+			So(ok, ShouldBeTrue)
+			So(status.Remaining, ShouldEqual, 100)
+			So(RecordTokens(ctx, "test:tpm", 100), ShouldBeNil)
+			_, ok, _ = CheckTokens(ctx, "test:tpm", 100)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("concurrency", func() {
+			ok, _ := Acquire(ctx, "test:concurrency", 1)
+			So(ok, ShouldBeTrue)
+			ok, _ = Acquire(ctx, "test:concurrency", 1)
+			So(ok, ShouldBeFalse)
+			So(Release(ctx, "test:concurrency"), ShouldBeNil)
+			ok, _ = Acquire(ctx, "test:concurrency", 1)
+			So(ok, ShouldBeTrue)
+		})
+		Convey("quota caps", func() {
+			capped := WithQuotaCap(ctx, QuotaCap{Name: "test ", Subject: "test:cap", Limit: 100, Expiration: time.Minute})
+			So(CheckQuotaCaps(capped, 100), ShouldBeNil)
+			RecordQuota(capped, 80)
+			So(CheckQuotaCaps(capped, 0), ShouldBeNil)
+			So(CheckQuotaCaps(capped, 30), ShouldNotBeNil)
+			RecordQuota(capped, -10)
+			So(CheckQuotaCaps(capped, 30), ShouldBeNil)
+			RecordQuota(capped, 30)
+			So(CheckQuotaCaps(capped, 0), ShouldNotBeNil)
+			So(CheckQuotaCaps(ctx, 1000), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,30 @@
+package relaymode
+
+// names are used to configure the endpoints a token is allowed to access,
+// the audio modes share one name
+var names = map[int]string{
+	ChatCompletions:    "chat",
+	Completions:        "completions",
+	Embeddings:         "embeddings",
+	Moderations:        "moderations",
+	ImagesGenerations:  "images",
+	Edits:              "edits",
+	AudioSpeech:        "audio",
+	AudioTranscription: "audio",
+	AudioTranslation:   "audio",
+	Proxy:              "proxy",
+}
+
+// GetName returns the endpoint name of the relay mode, empty for Unknown
+func GetName(relayMode int) string {
+	return names[relayMode]
+}
+
+func IsValidName(name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/songquanpeng/one-api/controller"
 	"github.com/songquanpeng/one-api/controller/auth"
 	"github.com/songquanpeng/one-api/middleware"
+	"github.com/songquanpeng/one-api/model"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
@@ -25,16 +26,20 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/oauth/github", middleware.CriticalRateLimit(), auth.GitHubOAuth)
 		apiRouter.GET("/oauth/oidc", middleware.CriticalRateLimit(), auth.OidcAuth)
 		apiRouter.GET("/oauth/lark", middleware.CriticalRateLimit(), auth.LarkOAuth)
+		apiRouter.POST("/oauth/ldap", middleware.CriticalRateLimit(), auth.LdapAuth)
+		apiRouter.GET("/oauth/providers", auth.GetOAuthProviders)
+		apiRouter.GET("/oauth/provider/:name", middleware.CriticalRateLimit(), auth.GenericOAuth)
 		apiRouter.GET("/oauth/state", middleware.CriticalRateLimit(), auth.GenerateOAuthCode)
 		apiRouter.GET("/oauth/wechat", middleware.CriticalRateLimit(), auth.WeChatAuth)
 		apiRouter.GET("/oauth/wechat/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), auth.WeChatBind)
 		apiRouter.GET("/oauth/email/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), controller.EmailBind)
-		apiRouter.POST("/topup", middleware.AdminAuth(), controller.AdminTopUp)
+		apiRouter.POST("/topup", middleware.PermissionAuth(model.PermissionUserManage), controller.AdminTopUp)
 
 		userRoute := apiRouter.Group("/user")
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), controller.Login)
+			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), controller.VerifyTwoFactorLogin)
 			userRoute.GET("/logout", controller.Logout)
 
 			selfRoute := userRoute.Group("/")
@@ -48,41 +53,56 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.GET("/available_models", controller.GetUserAvailableModels)
+				selfRoute.GET("/permissions", controller.GetSelfPermissions)
+				selfRoute.GET("/identities", controller.GetSelfIdentities)
+				selfRoute.GET("/sessions", controller.GetSelfSessions)
+				selfRoute.GET("/pat", controller.GetSelfPersonalAccessTokens)
+				selfRoute.POST("/pat", controller.AddPersonalAccessToken)
+				selfRoute.DELETE("/pat/:id", controller.DeletePersonalAccessToken)
+				selfRoute.DELETE("/sessions", controller.DeleteOtherSelfSessions)
+				selfRoute.DELETE("/sessions/:id", controller.DeleteSelfSession)
+				selfRoute.DELETE("/identities/:id", controller.DeleteSelfIdentity)
+				selfRoute.GET("/2fa/setup", controller.SetupTwoFactor)
+				selfRoute.POST("/2fa/enable", controller.EnableTwoFactor)
+				selfRoute.POST("/2fa/disable", middleware.CriticalRateLimit(), controller.DisableTwoFactor)
+				selfRoute.POST("/2fa/recovery_codes", middleware.CriticalRateLimit(), controller.RegenerateRecoveryCodes)
 			}
 
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetAllUsers)
-				adminRoute.GET("/search", controller.SearchUsers)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.GET("/", middleware.PermissionAuth(model.PermissionUserRead), controller.GetAllUsers)
+				adminRoute.GET("/search", middleware.PermissionAuth(model.PermissionUserRead), controller.SearchUsers)
+				adminRoute.GET("/pending", middleware.PermissionAuth(model.PermissionUserRead), controller.GetPendingUsers)
+				adminRoute.GET("/:id", middleware.PermissionAuth(model.PermissionUserRead), controller.GetUser)
+				adminRoute.GET("/:id/sessions", middleware.PermissionAuth(model.PermissionUserRead), controller.GetUserSessions)
+				adminRoute.POST("/", middleware.PermissionAuth(model.PermissionUserManage), controller.CreateUser)
+				adminRoute.POST("/manage", middleware.PermissionAuth(model.PermissionUserManage), controller.ManageUser)
+				adminRoute.PUT("/", middleware.PermissionAuth(model.PermissionUserManage), controller.UpdateUser)
+				adminRoute.DELETE("/:id", middleware.PermissionAuth(model.PermissionUserManage), controller.DeleteUser)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
+		optionRoute.Use(middleware.PermissionAuth(model.PermissionOptionManage))
 		{
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
 		}
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
 		{
-			channelRoute.GET("/", controller.GetAllChannels)
-			channelRoute.GET("/search", controller.SearchChannels)
-			channelRoute.GET("/models", controller.ListAllModels)
-			channelRoute.GET("/:id", controller.GetChannel)
-			channelRoute.GET("/test", controller.TestChannels)
-			channelRoute.GET("/test/:id", controller.TestChannel)
-			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
-			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
-			channelRoute.POST("/", controller.AddChannel)
-			channelRoute.PUT("/", controller.UpdateChannel)
-			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
-			channelRoute.DELETE("/:id", controller.DeleteChannel)
+			channelReadAuth := middleware.PermissionAuth(model.PermissionChannelRead)
+			channelWriteAuth := middleware.PermissionAuth(model.PermissionChannelWrite)
+			channelRoute.GET("/", channelReadAuth, controller.GetAllChannels)
+			channelRoute.GET("/search", channelReadAuth, controller.SearchChannels)
+			channelRoute.GET("/models", channelReadAuth, controller.ListAllModels)
+			channelRoute.GET("/:id", channelReadAuth, controller.GetChannel)
+			channelRoute.GET("/test", channelWriteAuth, controller.TestChannels)
+			channelRoute.GET("/test/:id", channelWriteAuth, controller.TestChannel)
+			channelRoute.GET("/update_balance", channelWriteAuth, controller.UpdateAllChannelsBalance)
+			channelRoute.GET("/update_balance/:id", channelWriteAuth, controller.UpdateChannelBalance)
+			channelRoute.POST("/", channelWriteAuth, controller.AddChannel)
+			channelRoute.PUT("/", channelWriteAuth, controller.UpdateChannel)
+			channelRoute.DELETE("/disabled", channelWriteAuth, controller.DeleteDisabledChannel)
+			channelRoute.DELETE("/:id", channelWriteAuth, controller.DeleteChannel)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -95,23 +115,83 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
 		{
-			redemptionRoute.GET("/", controller.GetAllRedemptions)
-			redemptionRoute.GET("/search", controller.SearchRedemptions)
-			redemptionRoute.GET("/:id", controller.GetRedemption)
-			redemptionRoute.POST("/", controller.AddRedemption)
-			redemptionRoute.PUT("/", controller.UpdateRedemption)
-			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
+			redemptionReadAuth := middleware.PermissionAuth(model.PermissionRedemptionRead)
+			redemptionCreateAuth := middleware.PermissionAuth(model.PermissionRedemptionCreate)
+			redemptionRoute.GET("/", redemptionReadAuth, controller.GetAllRedemptions)
+			redemptionRoute.GET("/search", redemptionReadAuth, controller.SearchRedemptions)
+			redemptionRoute.GET("/:id", redemptionReadAuth, controller.GetRedemption)
+			redemptionRoute.POST("/", redemptionCreateAuth, controller.AddRedemption)
+			redemptionRoute.PUT("/", redemptionCreateAuth, controller.UpdateRedemption)
+			redemptionRoute.DELETE("/:id", redemptionCreateAuth, controller.DeleteRedemption)
+		}
+		registrationInviteRoute := apiRouter.Group("/registration_invite")
+		registrationInviteRoute.Use(middleware.PermissionAuth(model.PermissionUserManage))
+		{
+			registrationInviteRoute.GET("/", controller.GetAllRegistrationInvites)
+			registrationInviteRoute.POST("/", controller.AddRegistrationInvite)
+			registrationInviteRoute.DELETE("/:id", controller.DeleteRegistrationInvite)
+		}
+		signingKeyRoute := apiRouter.Group("/signing_key")
+		signingKeyRoute.Use(middleware.PermissionAuth(model.PermissionSigningKeyManage))
+		{
+			signingKeyRoute.GET("/", controller.GetAllSigningKeys)
+			signingKeyRoute.GET("/:id", controller.GetSigningKey)
+			signingKeyRoute.POST("/", controller.AddSigningKey)
+			signingKeyRoute.PUT("/", controller.UpdateSigningKey)
+			signingKeyRoute.DELETE("/:id", controller.DeleteSigningKey)
+		}
+		organizationRoute := apiRouter.Group("/organization")
+		{
+			organizationUserAuth := middleware.UserAuth()
+			organizationManageAuth := middleware.PermissionAuth(model.PermissionOrganizationManage)
+			organizationRoute.GET("/", organizationUserAuth, controller.GetUserOrganizations)
+			organizationRoute.GET("/all", organizationManageAuth, controller.GetAllOrganizations)
+			organizationRoute.POST("/", organizationUserAuth, controller.AddOrganization)
+			organizationRoute.POST("/join", organizationUserAuth, controller.JoinOrganization)
+			organizationRoute.GET("/:id", organizationUserAuth, controller.GetOrganization)
+			organizationRoute.PUT("/:id", organizationUserAuth, controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", organizationUserAuth, controller.DeleteOrganization)
+			organizationRoute.GET("/:id/member", organizationUserAuth, controller.GetOrganizationMembers)
+			organizationRoute.PUT("/:id/member", organizationUserAuth, controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/member/:user_id", organizationUserAuth, controller.DeleteOrganizationMember)
+			organizationRoute.GET("/:id/invitation", organizationUserAuth, controller.GetOrganizationInvitations)
+			organizationRoute.POST("/:id/invitation", organizationUserAuth, controller.AddOrganizationInvitation)
+			organizationRoute.DELETE("/:id/invitation/:invitation_id", organizationUserAuth, controller.DeleteOrganizationInvitation)
+			organizationRoute.POST("/:id/contribute", organizationUserAuth, controller.ContributeOrganizationQuota)
+			organizationRoute.POST("/:id/topup", organizationManageAuth, controller.TopUpOrganization)
+			organizationRoute.GET("/:id/log", organizationUserAuth, controller.GetOrganizationLogs)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", middleware.PermissionAuth(model.PermissionLogRead), controller.GetAllLogs)
+		logRoute.DELETE("/", middleware.PermissionAuth(model.PermissionLogDelete), controller.DeleteHistoryLogs)
+		logRoute.GET("/stat", middleware.PermissionAuth(model.PermissionLogRead), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
-		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/end_user_stat", middleware.PermissionAuth(model.PermissionLogRead), controller.GetLogsEndUserStat)
+		logRoute.GET("/self/end_user_stat", middleware.UserAuth(), controller.GetLogsSelfEndUserStat)
+		logRoute.GET("/search", middleware.PermissionAuth(model.PermissionLogRead), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
+		apiRouter.GET("/audit_log", middleware.PermissionAuth(model.PermissionAuditLogRead), controller.GetAuditLogs)
+		oauthProviderRoute := apiRouter.Group("/oauth_provider")
+		oauthProviderRoute.Use(middleware.PermissionAuth(model.PermissionOptionManage))
+		{
+			oauthProviderRoute.GET("/", controller.GetAllOAuthProviders)
+			oauthProviderRoute.GET("/:id", controller.GetOAuthProvider)
+			oauthProviderRoute.POST("/", controller.AddOAuthProvider)
+			oauthProviderRoute.PUT("/", controller.UpdateOAuthProvider)
+			oauthProviderRoute.DELETE("/:id", controller.DeleteOAuthProvider)
+		}
+		roleRoute := apiRouter.Group("/role")
+		roleRoute.Use(middleware.PermissionAuth(model.PermissionRoleManage))
+		{
+			roleRoute.GET("/", controller.GetAllCustomRoles)
+			roleRoute.GET("/permissions", controller.GetAllPermissions)
+			roleRoute.GET("/:id", controller.GetCustomRole)
+			roleRoute.POST("/", controller.AddCustomRole)
+			roleRoute.PUT("/", controller.UpdateCustomRole)
+			roleRoute.DELETE("/:id", controller.DeleteCustomRole)
+		}
 		groupRoute := apiRouter.Group("/group")
 		groupRoute.Use(middleware.AdminAuth())
 		{
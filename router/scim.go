@@ -0,0 +1,30 @@
+package router
+
+import (
+	"github.com/gin-contrib/gzip"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/controller"
+	"github.com/songquanpeng/one-api/middleware"
+)
+
+func SetScimRouter(router *gin.Engine) {
+	scimRouter := router.Group("/scim/v2")
+	scimRouter.Use(gzip.Gzip(gzip.DefaultCompression))
+	scimRouter.Use(middleware.GlobalAPIRateLimit())
+	scimRouter.Use(middleware.ScimAuth())
+	{
+		scimRouter.GET("/ServiceProviderConfig", controller.ScimGetServiceProviderConfig)
+		scimRouter.GET("/Users", controller.ScimGetUsers)
+		scimRouter.GET("/Users/:id", controller.ScimGetUser)
+		scimRouter.POST("/Users", controller.ScimCreateUser)
+		scimRouter.PUT("/Users/:id", controller.ScimReplaceUser)
+		scimRouter.PATCH("/Users/:id", controller.ScimPatchUser)
+		scimRouter.DELETE("/Users/:id", controller.ScimDeleteUser)
+		scimRouter.GET("/Groups", controller.ScimGetGroups)
+		scimRouter.GET("/Groups/:id", controller.ScimGetGroup)
+		scimRouter.POST("/Groups", controller.ScimCreateGroup)
+		scimRouter.PUT("/Groups/:id", controller.ScimReplaceGroup)
+		scimRouter.PATCH("/Groups/:id", controller.ScimPatchGroup)
+		scimRouter.DELETE("/Groups/:id", controller.ScimDeleteGroup)
+	}
+}
@@ -2,9 +2,9 @@ import React, { useContext, useEffect, useState } from 'react';
 import { Link, useNavigate, useSearchParams } from 'react-router-dom';
 import { UserContext } from '../context/User';
 import { API, getLogo, showError, showInfo, showSuccess } from '../helpers';
-import { onGitHubOAuthClicked } from './utils';
+import { onGitHubOAuthClicked, onOAuthProviderClicked } from './utils';
 import Turnstile from 'react-turnstile';
-import { Button, Card, Divider, Form, Icon, Layout, Modal } from '@douyinfe/semi-ui';
+import { Button, Card, Checkbox, Divider, Form, Icon, Layout, Modal } from '@douyinfe/semi-ui';
 import Title from '@douyinfe/semi-ui/lib/es/typography/title';
 import Text from '@douyinfe/semi-ui/lib/es/typography/text';
 import TelegramLoginButton from 'react-telegram-login';
@@ -16,10 +16,13 @@ const LoginForm = () => {
   const [inputs, setInputs] = useState({
     username: '',
     password: '',
-    wechat_verification_code: ''
+    wechat_verification_code: '',
+    two_factor_code: ''
   });
   const [searchParams, setSearchParams] = useSearchParams();
   const [submitted, setSubmitted] = useState(false);
+  const [requireTwoFactor, setRequireTwoFactor] = useState(false);
+  const [ldapLogin, setLdapLogin] = useState(false);
   const { username, password } = inputs;
   const [userState, userDispatch] = useContext(UserContext);
   const [turnstileEnabled, setTurnstileEnabled] = useState(false);
@@ -27,12 +30,17 @@ const LoginForm = () => {
   const [turnstileToken, setTurnstileToken] = useState('');
   let navigate = useNavigate();
   const [status, setStatus] = useState({});
+  const [oauthProviders, setOAuthProviders] = useState([]);
   const logo = getLogo();
 
   useEffect(() => {
     if (searchParams.get('expired')) {
       showError('未登录或登录已过期，请重新登录！');
     }
+    if (searchParams.get('two_factor')) {
+      // the oauth login of a user with two-factor authentication enabled
+      setRequireTwoFactor(true);
+    }
     let status = localStorage.getItem('status');
     if (status) {
       status = JSON.parse(status);
@@ -42,8 +50,17 @@ const LoginForm = () => {
         setTurnstileSiteKey(status.turnstile_site_key);
       }
     }
+    loadOAuthProviders().then();
   }, []);
 
+  const loadOAuthProviders = async () => {
+    const res = await API.get('/api/oauth/providers');
+    const { success, data } = res.data;
+    if (success) {
+      setOAuthProviders(data);
+    }
+  };
+
   const [showWeChatLoginModal, setShowWeChatLoginModal] = useState(false);
 
   const onWeChatLoginClicked = () => {
@@ -60,11 +77,15 @@ const LoginForm = () => {
     );
     const { success, message, data } = res.data;
     if (success) {
+      setShowWeChatLoginModal(false);
+      if (data && data.require_two_factor) {
+        setRequireTwoFactor(true);
+        return;
+      }
       userDispatch({ type: 'login', payload: data });
       localStorage.setItem('user', JSON.stringify(data));
       navigate('/');
       showSuccess('登录成功！');
-      setShowWeChatLoginModal(false);
     } else {
       showError(message);
     }
@@ -81,19 +102,18 @@ const LoginForm = () => {
     }
     setSubmitted(true);
     if (username && password) {
-      const res = await API.post(`/api/user/login?turnstile=${turnstileToken}`, {
+      const url = ldapLogin ? `/api/oauth/ldap` : `/api/user/login?turnstile=${turnstileToken}`;
+      const res = await API.post(url, {
         username,
         password
       });
       const { success, message, data } = res.data;
       if (success) {
-        userDispatch({ type: 'login', payload: data });
-        localStorage.setItem('user', JSON.stringify(data));
-        showSuccess('登录成功！');
-        if (username === 'root' && password === '123456') {
-          Modal.error({ title: '您正在使用默认密码！', content: '请立刻修改默认密码！', centered: true });
+        if (data && data.require_two_factor) {
+          setRequireTwoFactor(true);
+          return;
         }
-        navigate('/token');
+        completeLogin(data);
       } else {
         showError(message);
       }
@@ -102,6 +122,32 @@ const LoginForm = () => {
     }
   }
 
+  function completeLogin(data) {
+    userDispatch({ type: 'login', payload: data });
+    localStorage.setItem('user', JSON.stringify(data));
+    showSuccess('登录成功！');
+    if (username === 'root' && password === '123456') {
+      Modal.error({ title: '您正在使用默认密码！', content: '请立刻修改默认密码！', centered: true });
+    }
+    navigate('/token');
+  }
+
+  async function handleTwoFactorSubmit(e) {
+    if (!inputs.two_factor_code) {
+      showError('请输入验证码！');
+      return;
+    }
+    const res = await API.post(`/api/user/login/2fa`, {
+      code: inputs.two_factor_code
+    });
+    const { success, message, data } = res.data;
+    if (success) {
+      completeLogin(data);
+    } else {
+      showError(message);
+    }
+  }
+
   // 添加Telegram登录处理函数
   const onTelegramLoginClicked = async (response) => {
     const fields = ['id', 'first_name', 'last_name', 'username', 'photo_url', 'auth_date', 'hash', 'lang'];
@@ -114,6 +160,10 @@ const LoginForm = () => {
     const res = await API.get(`/api/oauth/telegram/login`, { params });
     const { success, message, data } = res.data;
     if (success) {
+      if (data && data.require_two_factor) {
+        setRequireTwoFactor(true);
+        return;
+      }
       userDispatch({ type: 'login', payload: data });
       localStorage.setItem('user', JSON.stringify(data));
       showSuccess('登录成功！');
@@ -135,28 +185,59 @@ const LoginForm = () => {
                 <Title heading={2} style={{ textAlign: 'center' }}>
                   用户登录
                 </Title>
-                <Form>
-                  <Form.Input
-                    field={'username'}
-                    label={'用户名'}
-                    placeholder="用户名"
-                    name="username"
-                    onChange={(value) => handleChange('username', value)}
-                  />
-                  <Form.Input
-                    field={'password'}
-                    label={'密码'}
-                    placeholder="密码"
-                    name="password"
-                    type="password"
-                    onChange={(value) => handleChange('password', value)}
-                  />
+                {requireTwoFactor ? (
+                  <Form>
+                    <Text type="secondary">
+                      该账户已启用两步验证，请输入身份验证器中的验证码或恢复码
+                    </Text>
+                    <Form.Input
+                      field={'two_factor_code'}
+                      label={'验证码'}
+                      placeholder="验证码 / 恢复码"
+                      name="two_factor_code"
+                      onChange={(value) => handleChange('two_factor_code', value)}
+                    />
 
-                  <Button theme="solid" style={{ width: '100%' }} type={'primary'} size="large"
-                          htmlType={'submit'} onClick={handleSubmit}>
-                    登录
-                  </Button>
-                </Form>
+                    <Button theme="solid" style={{ width: '100%' }} type={'primary'} size="large"
+                            htmlType={'submit'} onClick={handleTwoFactorSubmit}>
+                      验证
+                    </Button>
+                    <Button style={{ width: '100%', marginTop: 12 }} size="large"
+                            onClick={() => setRequireTwoFactor(false)}>
+                      返回
+                    </Button>
+                  </Form>
+                ) : (
+                  <Form>
+                    <Form.Input
+                      field={'username'}
+                      label={'用户名'}
+                      placeholder="用户名"
+                      name="username"
+                      onChange={(value) => handleChange('username', value)}
+                    />
+                    <Form.Input
+                      field={'password'}
+                      label={'密码'}
+                      placeholder="密码"
+                      name="password"
+                      type="password"
+                      onChange={(value) => handleChange('password', value)}
+                    />
+                    {status.ldap ? (
+                      <Checkbox checked={ldapLogin} onChange={(e) => setLdapLogin(e.target.checked)} style={{ marginBottom: 12 }}>
+                        使用 LDAP 账户登录
+                      </Checkbox>
+                    ) : (
+                      <></>
+                    )}
+
+                    <Button theme="solid" style={{ width: '100%' }} type={'primary'} size="large"
+                            htmlType={'submit'} onClick={handleSubmit}>
+                      登录
+                    </Button>
+                  </Form>
+                )}
                 <div style={{ display: 'flex', justifyContent: 'space-between', marginTop: 20 }}>
                   <Text>
                     没有账号请先 <Link to="/register">注册账号</Link>
@@ -165,7 +246,7 @@ const LoginForm = () => {
                     忘记密码 <Link to="/reset">点击重置</Link>
                   </Text>
                 </div>
-                {status.github_oauth || status.wechat_login || status.telegram_oauth ? (
+                {status.github_oauth || status.wechat_login || status.telegram_oauth || oauthProviders.length > 0 ? (
                   <>
                     <Divider margin="12px" align="center">
                       第三方登录
@@ -197,6 +278,17 @@ const LoginForm = () => {
                         <></>
                       )}
                     </div>
+                    {oauthProviders.map((provider) => (
+                      <Button
+                        key={provider.name}
+                        theme="light"
+                        block
+                        style={{ marginTop: 10 }}
+                        onClick={() => onOAuthProviderClicked(provider)}
+                      >
+                        使用 {provider.display_name || provider.name} 登录
+                      </Button>
+                    ))}
                   </>
                 ) : (
                   <></>
@@ -32,7 +32,8 @@ const PersonalSetting = () => {
     email: '',
     self_account_deletion_confirmation: '',
     set_new_password: '',
-    set_new_password_confirmation: ''
+    set_new_password_confirmation: '',
+    two_factor_code: ''
   });
   const [status, setStatus] = useState({});
   const [showChangePasswordModal, setShowChangePasswordModal] = useState(false);
@@ -50,6 +51,10 @@ const PersonalSetting = () => {
   const [models, setModels] = useState([]);
   const [openTransfer, setOpenTransfer] = useState(false);
   const [transferAmount, setTransferAmount] = useState(0);
+  // enable, disable or recovery, empty when the modal is closed
+  const [twoFactorAction, setTwoFactorAction] = useState('');
+  const [twoFactorSetup, setTwoFactorSetup] = useState({ secret: '', uri: '' });
+  const [recoveryCodes, setRecoveryCodes] = useState([]);
 
   useEffect(() => {
     // let user = localStorage.getItem('user');
@@ -127,6 +132,64 @@ const PersonalSetting = () => {
     }
   };
 
+  const openTwoFactorModal = async (action) => {
+    if (action === 'enable') {
+      const res = await API.get('/api/user/2fa/setup');
+      const { success, message, data } = res.data;
+      if (!success) {
+        showError(message);
+        return;
+      }
+      setTwoFactorSetup(data);
+    }
+    setRecoveryCodes([]);
+    handleInputChange('two_factor_code', '');
+    setTwoFactorAction(action);
+  };
+
+  const closeTwoFactorModal = () => {
+    setTwoFactorAction('');
+    setTwoFactorSetup({ secret: '', uri: '' });
+    setRecoveryCodes([]);
+    handleInputChange('two_factor_code', '');
+  };
+
+  const submitTwoFactor = async () => {
+    if (recoveryCodes.length > 0) {
+      closeTwoFactorModal();
+      return;
+    }
+    if (inputs.two_factor_code === '') {
+      showError('请输入验证码！');
+      return;
+    }
+    const url = {
+      enable: '/api/user/2fa/enable',
+      disable: '/api/user/2fa/disable',
+      recovery: '/api/user/2fa/recovery_codes'
+    }[twoFactorAction];
+    const res = await API.post(url, { code: inputs.two_factor_code });
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    switch (twoFactorAction) {
+      case 'enable':
+        showSuccess('两步验证已启用！');
+        setRecoveryCodes(data.recovery_codes);
+        break;
+      case 'disable':
+        showSuccess('两步验证已停用！');
+        closeTwoFactorModal();
+        break;
+      default:
+        showSuccess('恢复码已重新生成！');
+        setRecoveryCodes(data.recovery_codes);
+    }
+    await getUserData();
+  };
+
   const loadModels = async () => {
     let res = await API.get(`/api/user/available_models`);
     const { success, message, data } = res.data;
@@ -533,6 +596,74 @@ const PersonalSetting = () => {
                 </Modal>
               </div>
             </Card>
+            <Card>
+              <Typography.Title heading={6}>两步验证</Typography.Title>
+              <div style={{ marginTop: 20 }}>
+                <Banner
+                  type={userState.user && userState.user.two_factor_enabled ? 'success' : 'info'}
+                  description={userState.user && userState.user.two_factor_enabled
+                    ? '两步验证已启用，登录时需要输入身份验证器中的验证码。'
+                    : '启用两步验证后，登录时除密码外还需要输入身份验证器中的验证码。'}
+                  closeIcon={null}
+                />
+              </div>
+              <div style={{ marginTop: 10 }}>
+                {userState.user && userState.user.two_factor_enabled ? (
+                  <Space>
+                    <Button onClick={() => openTwoFactorModal('recovery')}>重新生成恢复码</Button>
+                    <Button type={'danger'} onClick={() => openTwoFactorModal('disable')}>停用两步验证</Button>
+                  </Space>
+                ) : (
+                  <Button onClick={() => openTwoFactorModal('enable')}>启用两步验证</Button>
+                )}
+              </div>
+            </Card>
+            <Modal
+              title={{ enable: '启用两步验证', disable: '停用两步验证', recovery: '重新生成恢复码' }[twoFactorAction]}
+              onCancel={closeTwoFactorModal}
+              onOk={submitTwoFactor}
+              okText={recoveryCodes.length > 0 ? '我已保存' : '确定'}
+              visible={twoFactorAction !== ''}
+              size={'small'}
+              centered={true}
+              maskClosable={false}
+            >
+              {recoveryCodes.length > 0 ? (
+                <div style={{ marginTop: 20 }}>
+                  <Banner
+                    type="warning"
+                    description="请妥善保存以下恢复码，每个恢复码只能使用一次，关闭后将无法再次查看。"
+                    closeIcon={null}
+                  />
+                  <Typography.Paragraph copyable={{ content: recoveryCodes.join('\n') }} style={{ marginTop: 10 }}>
+                    <pre>{recoveryCodes.join('\n')}</pre>
+                  </Typography.Paragraph>
+                </div>
+              ) : (
+                <div style={{ marginTop: 20 }}>
+                  {twoFactorAction === 'enable' && (
+                    <>
+                      <Banner
+                        type="info"
+                        description="请在身份验证器中添加以下密钥，或导入链接，然后输入生成的验证码"
+                        closeIcon={null}
+                      />
+                      <Typography.Text strong>密钥</Typography.Text>
+                      <Input value={twoFactorSetup.secret} readOnly onClick={() => copy(twoFactorSetup.secret)} />
+                      <Typography.Text strong>链接</Typography.Text>
+                      <Input value={twoFactorSetup.uri} readOnly onClick={() => copy(twoFactorSetup.uri)} />
+                    </>
+                  )}
+                  <Input
+                    style={{ marginTop: 20 }}
+                    name="two_factor_code"
+                    placeholder={twoFactorAction === 'enable' ? '验证码' : '验证码 / 恢复码'}
+                    value={inputs.two_factor_code}
+                    onChange={(value) => handleInputChange('two_factor_code', value)}
+                  />
+                </div>
+              )}
+            </Modal>
             <Modal
               onCancel={() => setShowEmailBindModal(false)}
               // onOpen={() => setShowEmailBindModal(true)}
@@ -23,6 +23,8 @@ const RegisterForm = () => {
   if (affCode) {
     localStorage.setItem('aff', affCode);
   }
+  // the registration invite link is /register?invite=<code>
+  const inviteCode = new URLSearchParams(window.location.search).get('invite');
 
   useEffect(() => {
     let status = localStorage.getItem('status');
@@ -63,6 +65,9 @@ const RegisterForm = () => {
         affCode = localStorage.getItem('aff');
       }
       inputs.aff_code = affCode;
+      if (inviteCode) {
+        inputs.invite_code = inviteCode;
+      }
       const res = await API.post(
         `/api/user/register?turnstile=${turnstileToken}`,
         inputs
@@ -70,7 +75,7 @@ const RegisterForm = () => {
       const { success, message } = res.data;
       if (success) {
         navigate('/login');
-        showSuccess('注册成功！');
+        showSuccess(message || '注册成功！');
       } else {
         showError(message);
       }
@@ -100,7 +100,10 @@ const UsersTable = () => {
         >
           <Button theme="light" type="secondary" style={{ marginRight: 1 }}>降级</Button>
         </Popconfirm>
-        {record.status === 1 ?
+        {record.status === 4 ?
+          <Button theme="light" type="primary" style={{ marginRight: 1 }} onClick={async () => {
+            manageUser(record.username, 'approve', record);
+          }}>批准</Button> : record.status === 1 ?
           <Button theme="light" type="warning" style={{ marginRight: 1 }} onClick={async () => {
             manageUser(record.username, 'disable', record);
           }}>禁用</Button> :
@@ -141,6 +144,7 @@ const UsersTable = () => {
   });
   const [orderBy, setOrderBy] = useState('');
   const [dropdownVisible, setDropdownVisible] = useState(false);
+  const [showPending, setShowPending] = useState(false);
 
   const setCount = (data) => {
     if (data.length >= (activePage) * ITEMS_PER_PAGE) {
@@ -164,10 +168,11 @@ const UsersTable = () => {
   };
 
   const loadUsers = async (startIdx) => {
-    const res = await API.get(`/api/user/?p=${startIdx}&order=${orderBy}`);
+    // the users registered in the approval mode are returned at once
+    const res = await API.get(showPending ? '/api/user/pending' : `/api/user/?p=${startIdx}&order=${orderBy}`);
     const { success, message, data } = res.data;
     if (success) {
-      if (startIdx === 0) {
+      if (startIdx === 0 || showPending) {
         setUsers(data);
         setCount(data);
       } else {
@@ -198,7 +203,7 @@ const UsersTable = () => {
       .catch((reason) => {
         showError(reason);
       });
-  }, [orderBy]);
+  }, [orderBy, showPending]);
 
   const manageUser = async (username, action, record) => {
     const res = await API.post('/api/user/manage', {
@@ -229,6 +234,10 @@ const UsersTable = () => {
         return (<Tag size="large" color="red">
           已封禁
         </Tag>);
+      case 4:
+        return (<Tag size="large" color="orange">
+          待审核
+        </Tag>);
       default:
         return (<Tag size="large" color="grey">
           未知状态
@@ -276,7 +285,7 @@ const UsersTable = () => {
 
   const handlePageChange = page => {
     setActivePage(page);
-    if (page === Math.ceil(users.length / ITEMS_PER_PAGE) + 1) {
+    if (!showPending && page === Math.ceil(users.length / ITEMS_PER_PAGE) + 1) {
       // In this case we have to load more data and then append them.
       loadUsers(page - 1).then(r => {
       });
@@ -369,6 +378,10 @@ const UsersTable = () => {
       >
         <Button style={{ marginLeft: '10px' }}>{renderSelectedOption(orderBy)}</Button>
       </Dropdown>
+      <Button theme={showPending ? 'solid' : 'light'} type="primary" style={{ marginLeft: '10px' }} onClick={() => {
+        setShowPending(!showPending);
+        setActivePage(1);
+      }}>待审核用户</Button>
     </>
   );
 };
@@ -4,19 +4,46 @@ import { LOGIN } from 'store/actions';
 import { useNavigate } from 'react-router';
 import { showSuccess } from 'utils/common';
 
+// the users with two-factor authentication enabled enter the code on the login page
+const twoFactorPath = '/login?two_factor=true';
+
 const useLogin = () => {
   const dispatch = useDispatch();
   const navigate = useNavigate();
-  const login = async (username, password) => {
+  // ldap logs in with the account of the directory
+  const login = async (username, password, ldap = false) => {
     try {
-      const res = await API.post(`/api/user/login`, {
+      const res = await API.post(ldap ? `/api/oauth/ldap` : `/api/user/login`, {
         username,
         password
       });
       const { success, message, data } = res.data;
+      if (success) {
+        if (data && data.require_two_factor) {
+          navigate(twoFactorPath);
+        } else {
+          localStorage.setItem('user', JSON.stringify(data));
+          dispatch({ type: LOGIN, payload: data });
+          navigate('/panel');
+        }
+      }
+      return { success, message };
+    } catch (err) {
+      // 请求失败，设置错误信息
+      return { success: false, message: '' };
+    }
+  };
+
+  const twoFactorLogin = async (code) => {
+    try {
+      const res = await API.post(`/api/user/login/2fa`, {
+        code
+      });
+      const { success, message, data } = res.data;
       if (success) {
         localStorage.setItem('user', JSON.stringify(data));
         dispatch({ type: LOGIN, payload: data });
+        showSuccess('登录成功！');
         navigate('/panel');
       }
       return { success, message };
@@ -34,6 +61,8 @@ const useLogin = () => {
         if (message === 'bind') {
           showSuccess('绑定成功！');
           navigate('/panel');
+        } else if (data && data.require_two_factor) {
+          navigate(twoFactorPath);
         } else {
           dispatch({ type: LOGIN, payload: data });
           localStorage.setItem('user', JSON.stringify(data));
@@ -56,6 +85,8 @@ const useLogin = () => {
         if (message === 'bind') {
           showSuccess('绑定成功！');
           navigate('/panel');
+        } else if (data && data.require_two_factor) {
+          navigate(twoFactorPath);
         } else {
           dispatch({ type: LOGIN, payload: data });
           localStorage.setItem('user', JSON.stringify(data));
@@ -78,6 +109,8 @@ const useLogin = () => {
         if (message === 'bind') {
           showSuccess('绑定成功！');
           navigate('/panel');
+        } else if (data && data.require_two_factor) {
+          navigate(twoFactorPath);
         } else {
           dispatch({ type: LOGIN, payload: data });
           localStorage.setItem('user', JSON.stringify(data));
@@ -92,15 +125,43 @@ const useLogin = () => {
     }
   }
 
+  const providerLogin = async (name, code, state) => {
+    try {
+      const res = await API.get(`/api/oauth/provider/${encodeURIComponent(name)}?code=${encodeURIComponent(code)}&state=${state}`);
+      const { success, message, data } = res.data;
+      if (success) {
+        if (message === 'bind') {
+          showSuccess('绑定成功！');
+          navigate('/panel');
+        } else if (data && data.require_two_factor) {
+          navigate(twoFactorPath);
+        } else {
+          dispatch({ type: LOGIN, payload: data });
+          localStorage.setItem('user', JSON.stringify(data));
+          showSuccess('登录成功！');
+          navigate('/panel');
+        }
+      }
+      return { success, message };
+    } catch (err) {
+      // 请求失败，设置错误信息
+      return { success: false, message: '' };
+    }
+  };
+
   const wechatLogin = async (code) => {
     try {
       const res = await API.get(`/api/oauth/wechat?code=${code}`);
       const { success, message, data } = res.data;
       if (success) {
-        dispatch({ type: LOGIN, payload: data });
-        localStorage.setItem('user', JSON.stringify(data));
-        showSuccess('登录成功！');
-        navigate('/panel');
+        if (data && data.require_two_factor) {
+          navigate(twoFactorPath);
+        } else {
+          dispatch({ type: LOGIN, payload: data });
+          localStorage.setItem('user', JSON.stringify(data));
+          showSuccess('登录成功！');
+          navigate('/panel');
+        }
       }
       return { success, message };
     } catch (err) {
@@ -116,7 +177,7 @@ const useLogin = () => {
     navigate('/');
   };
 
-  return { login, logout, githubLogin, wechatLogin, larkLogin,oidcLogin };
+  return { login, twoFactorLogin, logout, githubLogin, wechatLogin, larkLogin,oidcLogin, providerLogin };
 };
 
 export default useLogin;
@@ -13,7 +13,7 @@ const useRegister = () => {
       const res = await API.post(`/api/user/register?turnstile=${turnstile}`, input);
       const { success, message } = res.data;
       if (success) {
-        showSuccess('注册成功！');
+        showSuccess(message || '注册成功！');
         navigate('/login');
       }
       return { success, message };
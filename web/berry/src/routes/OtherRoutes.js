@@ -10,6 +10,7 @@ const AuthRegister = Loadable(lazy(() => import('views/Authentication/Auth/Regis
 const GitHubOAuth = Loadable(lazy(() => import('views/Authentication/Auth/GitHubOAuth')));
 const LarkOAuth = Loadable(lazy(() => import('views/Authentication/Auth/LarkOAuth')));
 const OidcOAuth = Loadable(lazy(() => import('views/Authentication/Auth/OidcOAuth')));
+const GenericOAuth = Loadable(lazy(() => import('views/Authentication/Auth/GenericOAuth')));
 const ForgetPassword = Loadable(lazy(() => import('views/Authentication/Auth/ForgetPassword')));
 const ResetPassword = Loadable(lazy(() => import('views/Authentication/Auth/ResetPassword')));
 const Home = Loadable(lazy(() => import('views/Home')));
@@ -58,6 +59,10 @@ const OtherRoutes = {
       path: 'oauth/oidc',
       element: <OidcOAuth />
     },
+    {
+      path: '/oauth/provider/:name',
+      element: <GenericOAuth />
+    },
     {
       path: '/404',
       element: <NotFoundView />
@@ -112,6 +112,25 @@ export async function onOidcClicked(auth_url, client_id, openInNewTab = false) {
     }
 }
 
+// onOAuthProviderClicked starts the login with a provider of /api/oauth/providers
+export async function onOAuthProviderClicked(provider, openInNewTab = false) {
+    const state = await getOAuthState();
+    if (!state) return;
+    const url = new URL(provider.authorization_endpoint);
+    url.searchParams.set('response_type', 'code');
+    url.searchParams.set('client_id', provider.client_id);
+    url.searchParams.set('redirect_uri', provider.redirect_uri);
+    url.searchParams.set('state', state);
+    if (provider.scopes) {
+        url.searchParams.set('scope', provider.scopes);
+    }
+    if (openInNewTab) {
+        window.open(url.toString());
+    } else {
+        window.location.href = url.toString();
+    }
+}
+
 export function isAdmin() {
     let user = localStorage.getItem('user');
     if (!user) return false;
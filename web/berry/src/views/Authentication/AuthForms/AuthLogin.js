@@ -1,14 +1,16 @@
-import { useState } from 'react';
+import { useEffect, useState } from 'react';
 import { useSelector } from 'react-redux';
-import { Link } from 'react-router-dom';
+import { Link, useNavigate, useSearchParams } from 'react-router-dom';
 
 // material-ui
 import { useTheme } from '@mui/material/styles';
 import {
   Box,
   Button,
+  Checkbox,
   Divider,
   FormControl,
+  FormControlLabel,
   FormHelperText,
   Grid,
   IconButton,
@@ -37,21 +39,37 @@ import Github from 'assets/images/icons/github.svg';
 import Wechat from 'assets/images/icons/wechat.svg';
 import Lark from 'assets/images/icons/lark.svg';
 import OIDC from 'assets/images/icons/oidc.svg';
-import { onGitHubOAuthClicked, onLarkOAuthClicked, onOidcClicked } from 'utils/common';
+import { onGitHubOAuthClicked, onLarkOAuthClicked, onOAuthProviderClicked, onOidcClicked } from 'utils/common';
+import { API } from 'utils/api';
 
 // ============================|| FIREBASE - LOGIN ||============================ //
 
 const LoginForm = ({ ...others }) => {
   const theme = useTheme();
-  const { login, wechatLogin } = useLogin();
+  const { login, twoFactorLogin, wechatLogin } = useLogin();
+  const navigate = useNavigate();
+  const [searchParams] = useSearchParams();
+  const requireTwoFactor = Boolean(searchParams.get('two_factor'));
   const [openWechat, setOpenWechat] = useState(false);
   const matchDownSM = useMediaQuery(theme.breakpoints.down('md'));
   const customization = useSelector((state) => state.customization);
   const siteInfo = useSelector((state) => state.siteInfo);
   // const [checked, setChecked] = useState(true);
+  const [oauthProviders, setOAuthProviders] = useState([]);
+
+  useEffect(() => {
+    const loadOAuthProviders = async () => {
+      const res = await API.get('/api/oauth/providers');
+      const { success, data } = res.data;
+      if (success) {
+        setOAuthProviders(data);
+      }
+    };
+    loadOAuthProviders().then();
+  }, []);
 
   let tripartiteLogin = false;
-  if (siteInfo.github_oauth || siteInfo.wechat_login || siteInfo.lark_client_id || siteInfo.oidc) {
+  if (siteInfo.github_oauth || siteInfo.wechat_login || siteInfo.lark_client_id || siteInfo.oidc || oauthProviders.length > 0) {
     tripartiteLogin = true;
   }
 
@@ -72,6 +90,76 @@ const LoginForm = ({ ...others }) => {
     event.preventDefault();
   };
 
+  if (requireTwoFactor) {
+    return (
+      <Formik
+        initialValues={{
+          code: '',
+          submit: null
+        }}
+        validationSchema={Yup.object().shape({
+          code: Yup.string().max(64).required('验证码不能为空')
+        })}
+        onSubmit={async (values, { setErrors, setStatus, setSubmitting }) => {
+          const { success, message } = await twoFactorLogin(values.code);
+          if (success) {
+            setStatus({ success: true });
+          } else {
+            setStatus({ success: false });
+            if (message) {
+              setErrors({ submit: message });
+            }
+          }
+          setSubmitting(false);
+        }}
+      >
+        {({ errors, handleBlur, handleChange, handleSubmit, isSubmitting, touched, values }) => (
+          <form noValidate onSubmit={handleSubmit} {...others}>
+            <Typography variant="body2" color="text.secondary" sx={{ mb: 2 }}>
+              该账户已启用两步验证，请输入身份验证器中的验证码或恢复码
+            </Typography>
+            <FormControl fullWidth error={Boolean(touched.code && errors.code)} sx={{ ...theme.typography.customInput }}>
+              <InputLabel htmlFor="outlined-adornment-code-login">验证码 / 恢复码</InputLabel>
+              <OutlinedInput
+                id="outlined-adornment-code-login"
+                type="text"
+                value={values.code}
+                name="code"
+                onBlur={handleBlur}
+                onChange={handleChange}
+                label="验证码 / 恢复码"
+                inputProps={{ autoComplete: 'one-time-code' }}
+              />
+              {touched.code && errors.code && (
+                <FormHelperText error id="standard-weight-helper-text-code-login">
+                  {errors.code}
+                </FormHelperText>
+              )}
+            </FormControl>
+            {errors.submit && (
+              <Box sx={{ mt: 3 }}>
+                <FormHelperText error>{errors.submit}</FormHelperText>
+              </Box>
+            )}
+
+            <Box sx={{ mt: 2 }}>
+              <AnimateButton>
+                <Button disableElevation disabled={isSubmitting} fullWidth size="large" type="submit" variant="contained" color="primary">
+                  验证
+                </Button>
+              </AnimateButton>
+            </Box>
+            <Box sx={{ mt: 2 }}>
+              <Button fullWidth size="large" onClick={() => navigate('/login')}>
+                返回
+              </Button>
+            </Box>
+          </form>
+        )}
+      </Formik>
+    );
+  }
+
   return (
     <>
       {tripartiteLogin && (
@@ -169,6 +257,26 @@ const LoginForm = ({ ...others }) => {
               </AnimateButton>
             </Grid>
           )}
+          {oauthProviders.map((provider) => (
+            <Grid item xs={12} key={provider.name}>
+              <AnimateButton>
+                <Button
+                  disableElevation
+                  fullWidth
+                  onClick={() => onOAuthProviderClicked(provider)}
+                  size="large"
+                  variant="outlined"
+                  sx={{
+                    color: 'grey.700',
+                    backgroundColor: theme.palette.grey[50],
+                    borderColor: theme.palette.grey[100]
+                  }}
+                >
+                  使用 {provider.display_name || provider.name} 登录
+                </Button>
+              </AnimateButton>
+            </Grid>
+          ))}
           <Grid item xs={12}>
             <Box
               sx={{
@@ -206,6 +314,7 @@ const LoginForm = ({ ...others }) => {
         initialValues={{
           username: '',
           password: '',
+          ldap: false,
           submit: null
         }}
         validationSchema={Yup.object().shape({
@@ -213,7 +322,7 @@ const LoginForm = ({ ...others }) => {
           password: Yup.string().max(255).required('Password is required')
         })}
         onSubmit={async (values, { setErrors, setStatus, setSubmitting }) => {
-          const { success, message } = await login(values.username, values.password);
+          const { success, message } = await login(values.username, values.password, values.ldap);
           if (success) {
             setStatus({ success: true });
           } else {
@@ -277,6 +386,12 @@ const LoginForm = ({ ...others }) => {
               )}
             </FormControl>
             <Stack direction="row" alignItems="center" justifyContent="space-between" spacing={1}>
+              {siteInfo.ldap && (
+                <FormControlLabel
+                  control={<Checkbox checked={values.ldap} onChange={handleChange} name="ldap" color="primary" />}
+                  label="使用 LDAP 账户登录"
+                />
+              )}
               {/* <FormControlLabel
                 control={
                   <Checkbox checked={checked} onChange={(event) => setChecked(event.target.checked)} name="checked" color="primary" />
@@ -121,7 +121,9 @@ const RegisterForm = ({ ...others }) => {
             return;
           }
 
-          const { success, message } = await register(values, turnstileToken);
+          // the registration invite link is /register?invite=<code>
+          const inviteCode = searchParams.get('invite');
+          const { success, message } = await register(inviteCode ? { ...values, invite_code: inviteCode } : values, turnstileToken);
           if (success) {
             setStatus({ success: true });
           } else {
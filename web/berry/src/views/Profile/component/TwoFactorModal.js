@@ -0,0 +1,211 @@
+import { useState, useEffect } from "react";
+import PropTypes from "prop-types";
+import React from "react";
+import {
+  Alert,
+  Dialog,
+  DialogTitle,
+  DialogContent,
+  DialogActions,
+  OutlinedInput,
+  Button,
+  InputLabel,
+  Grid,
+  FormControl,
+  FormHelperText,
+  Typography,
+} from "@mui/material";
+import { Formik } from "formik";
+import { copy, showError, showSuccess } from "utils/common";
+import { useTheme } from "@mui/material/styles";
+import * as Yup from "yup";
+import { API } from "utils/api";
+
+const validationSchema = Yup.object().shape({
+  code: Yup.string().required("验证码不能为空"),
+});
+
+const titles = {
+  enable: "启用两步验证",
+  disable: "停用两步验证",
+  recovery: "重新生成恢复码",
+};
+
+const urls = {
+  enable: "/api/user/2fa/enable",
+  disable: "/api/user/2fa/disable",
+  recovery: "/api/user/2fa/recovery_codes",
+};
+
+// action is enable, disable or recovery, the recovery codes are shown once after enabling or regenerating
+const TwoFactorModal = ({ open, action, setup, handleClose, onEnabledChange }) => {
+  const theme = useTheme();
+  const [recoveryCodes, setRecoveryCodes] = useState([]);
+
+  useEffect(() => {
+    setRecoveryCodes([]);
+  }, [open]);
+
+  const submit = async (values, { setErrors, setStatus, setSubmitting }) => {
+    setSubmitting(true);
+    const res = await API.post(urls[action], { code: values.code });
+    const { success, message, data } = res.data;
+    setSubmitting(false);
+    if (!success) {
+      showError(message);
+      setErrors({ submit: message });
+      return;
+    }
+    setStatus({ success: true });
+    switch (action) {
+      case "enable":
+        showSuccess("两步验证已启用！");
+        onEnabledChange(true);
+        setRecoveryCodes(data.recovery_codes);
+        break;
+      case "disable":
+        showSuccess("两步验证已停用！");
+        onEnabledChange(false);
+        handleClose();
+        break;
+      default:
+        showSuccess("恢复码已重新生成！");
+        setRecoveryCodes(data.recovery_codes);
+    }
+  };
+
+  return (
+    <Dialog open={open} onClose={handleClose}>
+      <DialogTitle>{titles[action]}</DialogTitle>
+      <DialogContent>
+        {recoveryCodes.length > 0 ? (
+          <Grid container direction="column" spacing={2}>
+            <Grid item>
+              <Alert severity="warning">
+                请妥善保存以下恢复码，每个恢复码只能使用一次，关闭后将无法再次查看。
+              </Alert>
+            </Grid>
+            <Grid item>
+              <Typography
+                component="pre"
+                sx={{ fontFamily: "monospace", margin: 0 }}
+              >
+                {recoveryCodes.join("\n")}
+              </Typography>
+            </Grid>
+            <DialogActions>
+              <Button onClick={() => copy(recoveryCodes.join("\n"), "恢复码")}>
+                复制
+              </Button>
+              <Button variant="contained" color="primary" onClick={handleClose}>
+                我已保存
+              </Button>
+            </DialogActions>
+          </Grid>
+        ) : (
+          <Grid container direction="column" alignItems="center">
+            <Formik
+              initialValues={{
+                code: "",
+              }}
+              enableReinitialize
+              validationSchema={validationSchema}
+              onSubmit={submit}
+            >
+              {({
+                errors,
+                touched,
+                handleBlur,
+                handleChange,
+                handleSubmit,
+                isSubmitting,
+                values,
+              }) => (
+                <form noValidate onSubmit={handleSubmit}>
+                  {action === "enable" && (
+                    <>
+                      <Alert severity="info">
+                        请在身份验证器中添加以下密钥，或导入链接，然后输入生成的验证码
+                      </Alert>
+                      <FormControl
+                        fullWidth
+                        sx={{ ...theme.typography.customInput }}
+                      >
+                        <InputLabel htmlFor="two_factor_secret">密钥</InputLabel>
+                        <OutlinedInput
+                          id="two_factor_secret"
+                          type="text"
+                          value={setup.secret}
+                          readOnly
+                          onClick={() => copy(setup.secret, "密钥")}
+                        />
+                      </FormControl>
+                      <FormControl
+                        fullWidth
+                        sx={{ ...theme.typography.customInput }}
+                      >
+                        <InputLabel htmlFor="two_factor_uri">链接</InputLabel>
+                        <OutlinedInput
+                          id="two_factor_uri"
+                          type="text"
+                          value={setup.uri}
+                          readOnly
+                          onClick={() => copy(setup.uri, "链接")}
+                        />
+                      </FormControl>
+                    </>
+                  )}
+                  <FormControl
+                    fullWidth
+                    error={Boolean(touched.code && errors.code)}
+                    sx={{ ...theme.typography.customInput }}
+                  >
+                    <InputLabel htmlFor="two_factor_code">
+                      {action === "enable" ? "验证码" : "验证码 / 恢复码"}
+                    </InputLabel>
+                    <OutlinedInput
+                      id="two_factor_code"
+                      type="text"
+                      value={values.code}
+                      name="code"
+                      onBlur={handleBlur}
+                      onChange={handleChange}
+                      inputProps={{ autoComplete: "one-time-code" }}
+                    />
+                    {touched.code && errors.code && (
+                      <FormHelperText error id="helper-two_factor_code">
+                        {errors.code}
+                      </FormHelperText>
+                    )}
+                  </FormControl>
+                  <DialogActions>
+                    <Button onClick={handleClose}>取消</Button>
+                    <Button
+                      disableElevation
+                      disabled={isSubmitting}
+                      type="submit"
+                      variant="contained"
+                      color={action === "disable" ? "error" : "primary"}
+                    >
+                      提交
+                    </Button>
+                  </DialogActions>
+                </form>
+              )}
+            </Formik>
+          </Grid>
+        )}
+      </DialogContent>
+    </Dialog>
+  );
+};
+
+export default TwoFactorModal;
+
+TwoFactorModal.propTypes = {
+  open: PropTypes.bool,
+  action: PropTypes.string,
+  setup: PropTypes.object,
+  handleClose: PropTypes.func,
+  onEnabledChange: PropTypes.func,
+};
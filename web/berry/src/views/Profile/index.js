@@ -26,6 +26,7 @@ import * as Yup from 'yup';
 import WechatModal from 'views/Authentication/AuthForms/WechatModal';
 import { useSelector } from 'react-redux';
 import EmailModal from './component/EmailModal';
+import TwoFactorModal from './component/TwoFactorModal';
 import Turnstile from 'react-turnstile';
 import { ReactComponent as Lark } from 'assets/images/icons/lark.svg';
 import { ReactComponent as OIDC } from 'assets/images/icons/oidc.svg';
@@ -46,6 +47,8 @@ export default function Profile() {
   const [turnstileToken, setTurnstileToken] = useState('');
   const [openWechat, setOpenWechat] = useState(false);
   const [openEmail, setOpenEmail] = useState(false);
+  const [twoFactorAction, setTwoFactorAction] = useState('');
+  const [twoFactorSetup, setTwoFactorSetup] = useState({ secret: '', uri: '' });
   const status = useSelector((state) => state.siteInfo);
 
   const handleWechatOpen = () => {
@@ -114,6 +117,19 @@ export default function Profile() {
     }
   };
 
+  const openTwoFactor = async (action) => {
+    if (action === 'enable') {
+      const res = await API.get('/api/user/2fa/setup');
+      const { success, message, data } = res.data;
+      if (!success) {
+        showError(message);
+        return;
+      }
+      setTwoFactorSetup(data);
+    }
+    setTwoFactorAction(action);
+  };
+
   useEffect(() => {
     if (status) {
       if (status.turnstile_check) {
@@ -258,6 +274,37 @@ export default function Profile() {
                 </Grid>
               </Grid>
             </SubCard>
+            <SubCard title="两步验证">
+              <Grid container spacing={2}>
+                <Grid xs={12}>
+                  <Alert severity={inputs.two_factor_enabled ? 'success' : 'info'}>
+                    {inputs.two_factor_enabled
+                      ? '两步验证已启用，登录时需要输入身份验证器中的验证码。'
+                      : '启用两步验证后，登录时除密码外还需要输入身份验证器中的验证码。'}
+                  </Alert>
+                </Grid>
+                {inputs.two_factor_enabled ? (
+                  <>
+                    <Grid xs={12} md={4}>
+                      <Button variant="contained" onClick={() => openTwoFactor('recovery')}>
+                        重新生成恢复码
+                      </Button>
+                    </Grid>
+                    <Grid xs={12} md={4}>
+                      <Button variant="contained" color="error" onClick={() => openTwoFactor('disable')}>
+                        停用两步验证
+                      </Button>
+                    </Grid>
+                  </>
+                ) : (
+                  <Grid xs={12} md={4}>
+                    <Button variant="contained" onClick={() => openTwoFactor('enable')}>
+                      启用两步验证
+                    </Button>
+                  </Grid>
+                )}
+              </Grid>
+            </SubCard>
             <SubCard title="其他">
               <Grid container spacing={2}>
                 <Grid xs={12}>
@@ -312,6 +359,17 @@ export default function Profile() {
         </DialogActions>
       </Dialog>
       <WechatModal open={openWechat} handleClose={handleWechatClose} wechatLogin={bindWeChat} qrCode={status.wechat_qrcode} />
+      <TwoFactorModal
+        open={twoFactorAction !== ''}
+        action={twoFactorAction}
+        setup={twoFactorSetup}
+        handleClose={() => {
+          setTwoFactorAction('');
+        }}
+        onEnabledChange={(enabled) => {
+          setInputs((inputs) => ({ ...inputs, two_factor_enabled: enabled }));
+        }}
+      />
       <EmailModal
         open={openEmail}
         turnstileToken={turnstileToken}
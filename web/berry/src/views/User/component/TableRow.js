@@ -20,7 +20,16 @@ import {
 import Label from 'ui-component/Label';
 import TableSwitch from 'ui-component/Switch';
 import { renderQuota, renderNumber } from 'utils/common';
-import { IconDotsVertical, IconEdit, IconTrash, IconUser, IconBrandWechat, IconBrandGithub, IconMail } from '@tabler/icons-react';
+import {
+  IconDotsVertical,
+  IconEdit,
+  IconTrash,
+  IconUser,
+  IconUserCheck,
+  IconBrandWechat,
+  IconBrandGithub,
+  IconMail
+} from '@tabler/icons-react';
 import { useTheme } from '@mui/material/styles';
 
 function renderRole(role) {
@@ -67,6 +76,14 @@ export default function UsersTableRow({ item, manageUser, handleOpenModal, setMo
     }
   };
 
+  const handleApprove = async () => {
+    handleCloseMenu();
+    const { success } = await manageUser(item.username, 'approve', '');
+    if (success) {
+      setStatusSwitch(1);
+    }
+  };
+
   const handleDelete = async () => {
     handleCloseMenu();
     await manageUser(item.username, 'delete', '');
@@ -122,7 +139,11 @@ export default function UsersTableRow({ item, manageUser, handleOpenModal, setMo
 
         <TableCell>
           {' '}
-          <TableSwitch id={`switch-${item.id}`} checked={statusSwitch === 1} onChange={handleStatus} />
+          {statusSwitch === 4 ? (
+            <Label color="warning">待审核</Label>
+          ) : (
+            <TableSwitch id={`switch-${item.id}`} checked={statusSwitch === 1} onChange={handleStatus} />
+          )}
         </TableCell>
         <TableCell>
           <IconButton onClick={handleOpenMenu} sx={{ color: 'rgb(99, 115, 129)' }}>
@@ -141,6 +162,12 @@ export default function UsersTableRow({ item, manageUser, handleOpenModal, setMo
           sx: { width: 140 }
         }}
       >
+        {statusSwitch === 4 && (
+          <MenuItem onClick={handleApprove}>
+            <IconUserCheck style={{ marginRight: '16px' }} />
+            批准
+          </MenuItem>
+        )}
         {item.role !== 100 && (
           <MenuItem
             onClick={() => {
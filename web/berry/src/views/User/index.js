@@ -16,7 +16,7 @@ import UsersTableHead from './component/TableHead';
 import TableToolBar from 'ui-component/TableToolBar';
 import { API } from 'utils/api';
 import { ITEMS_PER_PAGE } from 'constants';
-import { IconRefresh, IconPlus } from '@tabler/icons-react';
+import { IconRefresh, IconPlus, IconUserCheck } from '@tabler/icons-react';
 import EditeModal from './component/EditModal';
 
 // ----------------------------------------------------------------------
@@ -27,13 +27,15 @@ export default function Users() {
   const [searchKeyword, setSearchKeyword] = useState('');
   const [openModal, setOpenModal] = useState(false);
   const [editUserId, setEditUserId] = useState(0);
+  const [showPending, setShowPending] = useState(false);
 
   const loadUsers = async (startIdx) => {
     setSearching(true);
-    const res = await API.get(`/api/user/?p=${startIdx}`);
+    // the users registered in the approval mode are returned at once
+    const res = await API.get(showPending ? '/api/user/pending' : `/api/user/?p=${startIdx}`);
     const { success, message, data } = res.data;
     if (success) {
-      if (startIdx === 0) {
+      if (startIdx === 0 || showPending) {
         setUsers(data);
       } else {
         let newUsers = [...users];
@@ -48,7 +50,7 @@ export default function Users() {
 
   const onPaginationChange = (event, activePage) => {
     (async () => {
-      if (activePage === Math.ceil(users.length / ITEMS_PER_PAGE)) {
+      if (!showPending && activePage === Math.ceil(users.length / ITEMS_PER_PAGE)) {
         // In this case we have to load more data and then append them.
         await loadUsers(activePage);
       }
@@ -93,6 +95,9 @@ export default function Users() {
       case 'role':
         data.action = value === true ? 'promote' : 'demote';
         break;
+      case 'approve':
+        data.action = 'approve';
+        break;
     }
 
     res = await API.post(url, data);
@@ -130,12 +135,13 @@ export default function Users() {
   };
 
   useEffect(() => {
+    setActivePage(0);
     loadUsers(0)
       .then()
       .catch((reason) => {
         showError(reason);
       });
-  }, []);
+  }, [showPending]);
 
   return (
     <>
@@ -168,6 +174,13 @@ export default function Users() {
               <Button onClick={handleRefresh} startIcon={<IconRefresh width={'18px'} />}>
                 刷新
               </Button>
+              <Button
+                onClick={() => setShowPending(!showPending)}
+                variant={showPending ? 'contained' : 'outlined'}
+                startIcon={<IconUserCheck width={'18px'} />}
+              >
+                待审核用户
+              </Button>
             </ButtonGroup>
           </Container>
         </Toolbar>
@@ -25,6 +25,7 @@ import TopUp from './pages/TopUp';
 import Log from './pages/Log';
 import Chat from './pages/Chat';
 import LarkOAuth from './components/LarkOAuth';
+import GenericOAuth from './components/GenericOAuth';
 import Dashboard from './pages/Dashboard';
 
 const Home = lazy(() => import('./pages/Home'));
@@ -254,6 +255,14 @@ function App() {
           </Suspense>
         }
       />
+      <Route
+        path='/oauth/provider/:name'
+        element={
+          <Suspense fallback={<Loading></Loading>}>
+            <GenericOAuth />
+          </Suspense>
+        }
+      />
       <Route
         path='/setting'
         element={
@@ -15,7 +15,11 @@ import { Link, useNavigate, useSearchParams } from 'react-router-dom';
 import { useTranslation } from 'react-i18next';
 import { UserContext } from '../context/User';
 import { API, getLogo, showError, showSuccess, showWarning } from '../helpers';
-import { onGitHubOAuthClicked, onLarkOAuthClicked } from './utils';
+import {
+  onGitHubOAuthClicked,
+  onLarkOAuthClicked,
+  onOAuthProviderClicked,
+} from './utils';
 import larkIcon from '../images/lark.svg';
 
 const LoginForm = () => {
@@ -24,9 +28,13 @@ const LoginForm = () => {
     username: '',
     password: '',
     wechat_verification_code: '',
+    two_factor_code: '',
   });
   const [searchParams, setSearchParams] = useSearchParams();
   const [submitted, setSubmitted] = useState(false);
+  const [requireTwoFactor, setRequireTwoFactor] = useState(false);
+  const [ldapLogin, setLdapLogin] = useState(false);
+  const [oauthProviders, setOAuthProviders] = useState([]);
   const { username, password } = inputs;
   const [userState, userDispatch] = useContext(UserContext);
   let navigate = useNavigate();
@@ -37,13 +45,26 @@ const LoginForm = () => {
     if (searchParams.get('expired')) {
       showError(t('messages.error.login_expired'));
     }
+    if (searchParams.get('two_factor')) {
+      // the oauth login of a user with two-factor authentication enabled
+      setRequireTwoFactor(true);
+    }
     let status = localStorage.getItem('status');
     if (status) {
       status = JSON.parse(status);
       setStatus(status);
     }
+    loadOAuthProviders().then();
   }, []);
 
+  const loadOAuthProviders = async () => {
+    const res = await API.get('/api/oauth/providers');
+    const { success, data } = res.data;
+    if (success) {
+      setOAuthProviders(data);
+    }
+  };
+
   const [showWeChatLoginModal, setShowWeChatLoginModal] = useState(false);
 
   const onWeChatLoginClicked = () => {
@@ -56,11 +77,15 @@ const LoginForm = () => {
     );
     const { success, message, data } = res.data;
     if (success) {
+      setShowWeChatLoginModal(false);
+      if (data && data.require_two_factor) {
+        setRequireTwoFactor(true);
+        return;
+      }
       userDispatch({ type: 'login', payload: data });
       localStorage.setItem('user', JSON.stringify(data));
       navigate('/');
       showSuccess(t('messages.success.login'));
-      setShowWeChatLoginModal(false);
     } else {
       showError(message);
     }
@@ -71,31 +96,55 @@ const LoginForm = () => {
     setInputs((inputs) => ({ ...inputs, [name]: value }));
   }
 
+  function completeLogin(data) {
+    userDispatch({ type: 'login', payload: data });
+    localStorage.setItem('user', JSON.stringify(data));
+    if (username === 'root' && password === '123456') {
+      navigate('/user/edit');
+      showSuccess(t('messages.success.login'));
+      showWarning(t('messages.error.root_password'));
+    } else {
+      navigate('/token');
+      showSuccess(t('messages.success.login'));
+    }
+  }
+
   async function handleSubmit(e) {
     setSubmitted(true);
     if (username && password) {
-      const res = await API.post(`/api/user/login`, {
-        username,
-        password,
-      });
+      const res = await API.post(
+        ldapLogin ? `/api/oauth/ldap` : `/api/user/login`,
+        {
+          username,
+          password,
+        }
+      );
       const { success, message, data } = res.data;
       if (success) {
-        userDispatch({ type: 'login', payload: data });
-        localStorage.setItem('user', JSON.stringify(data));
-        if (username === 'root' && password === '123456') {
-          navigate('/user/edit');
-          showSuccess(t('messages.success.login'));
-          showWarning(t('messages.error.root_password'));
-        } else {
-          navigate('/token');
-          showSuccess(t('messages.success.login'));
+        if (data && data.require_two_factor) {
+          setRequireTwoFactor(true);
+          return;
         }
+        completeLogin(data);
       } else {
         showError(message);
       }
     }
   }
 
+  async function handleTwoFactorSubmit(e) {
+    if (!inputs.two_factor_code) return;
+    const res = await API.post(`/api/user/login/2fa`, {
+      code: inputs.two_factor_code,
+    });
+    const { success, message, data } = res.data;
+    if (success) {
+      completeLogin(data);
+    } else {
+      showError(message);
+    }
+  }
+
   return (
     <Grid textAlign='center' style={{ marginTop: '48px' }}>
       <Grid.Column style={{ maxWidth: 450 }}>
@@ -115,41 +164,88 @@ const LoginForm = () => {
                 <Header.Content>{t('auth.login.title')}</Header.Content>
               </Header>
             </Card.Header>
-            <Form size='large'>
-              <Form.Input
-                fluid
-                icon='user'
-                iconPosition='left'
-                placeholder={t('auth.login.username')}
-                name='username'
-                value={username}
-                onChange={handleChange}
-                style={{ marginBottom: '1em' }}
-              />
-              <Form.Input
-                fluid
-                icon='lock'
-                iconPosition='left'
-                placeholder={t('auth.login.password')}
-                name='password'
-                type='password'
-                value={password}
-                onChange={handleChange}
-                style={{ marginBottom: '1.5em' }}
-              />
-              <Button
-                fluid
-                size='large'
-                style={{
-                  background: '#2F73FF', // 使用更现代的蓝色
-                  color: 'white',
-                  marginBottom: '1.5em',
-                }}
-                onClick={handleSubmit}
-              >
-                {t('auth.login.button')}
-              </Button>
-            </Form>
+            {requireTwoFactor ? (
+              <Form size='large'>
+                <Message>{t('auth.login.two_factor.tip')}</Message>
+                <Form.Input
+                  fluid
+                  icon='shield'
+                  iconPosition='left'
+                  placeholder={t('auth.login.two_factor.code_placeholder')}
+                  name='two_factor_code'
+                  value={inputs.two_factor_code}
+                  onChange={handleChange}
+                  style={{ marginBottom: '1.5em' }}
+                />
+                <Button
+                  fluid
+                  size='large'
+                  style={{
+                    background: '#2F73FF',
+                    color: 'white',
+                    marginBottom: '1em',
+                  }}
+                  onClick={handleTwoFactorSubmit}
+                >
+                  {t('auth.login.two_factor.verify')}
+                </Button>
+                <Button
+                  fluid
+                  size='large'
+                  style={{ marginBottom: '1.5em' }}
+                  onClick={() => {
+                    setRequireTwoFactor(false);
+                    setInputs((inputs) => ({ ...inputs, two_factor_code: '' }));
+                  }}
+                >
+                  {t('auth.login.two_factor.back')}
+                </Button>
+              </Form>
+            ) : (
+              <Form size='large'>
+                <Form.Input
+                  fluid
+                  icon='user'
+                  iconPosition='left'
+                  placeholder={t('auth.login.username')}
+                  name='username'
+                  value={username}
+                  onChange={handleChange}
+                  style={{ marginBottom: '1em' }}
+                />
+                <Form.Input
+                  fluid
+                  icon='lock'
+                  iconPosition='left'
+                  placeholder={t('auth.login.password')}
+                  name='password'
+                  type='password'
+                  value={password}
+                  onChange={handleChange}
+                  style={{ marginBottom: '1.5em' }}
+                />
+                {status.ldap && (
+                  <Form.Checkbox
+                    label={t('auth.login.ldap')}
+                    checked={ldapLogin}
+                    onChange={(e, { checked }) => setLdapLogin(checked)}
+                    style={{ marginBottom: '1.5em', textAlign: 'left' }}
+                  />
+                )}
+                <Button
+                  fluid
+                  size='large'
+                  style={{
+                    background: '#2F73FF', // 使用更现代的蓝色
+                    color: 'white',
+                    marginBottom: '1.5em',
+                  }}
+                  onClick={handleSubmit}
+                >
+                  {t('auth.login.button')}
+                </Button>
+              </Form>
+            )}
 
             <Divider />
             <Message style={{ background: 'transparent', boxShadow: 'none' }}>
@@ -184,7 +280,8 @@ const LoginForm = () => {
 
             {(status.github_oauth ||
               status.wechat_login ||
-              status.lark_client_id) && (
+              status.lark_client_id ||
+              oauthProviders.length > 0) && (
               <>
                 <Divider
                   horizontal
@@ -244,6 +341,23 @@ const LoginForm = () => {
                     </div>
                   )}
                 </div>
+                {oauthProviders.length > 0 && (
+                  <div style={{ marginTop: '1em' }}>
+                    {oauthProviders.map((provider) => (
+                      <Button
+                        key={provider.name}
+                        fluid
+                        basic
+                        style={{ marginBottom: '0.5em' }}
+                        onClick={() => onOAuthProviderClicked(provider)}
+                      >
+                        {t('auth.login.oauth_provider', {
+                          name: provider.display_name || provider.name,
+                        })}
+                      </Button>
+                    ))}
+                  </div>
+                )}
               </>
             )}
           </Card.Content>
@@ -32,6 +32,7 @@ const PersonalSetting = () => {
     email_verification_code: '',
     email: '',
     self_account_deletion_confirmation: '',
+    two_factor_code: '',
   });
   const [status, setStatus] = useState({});
   const [showWeChatBindModal, setShowWeChatBindModal] = useState(false);
@@ -45,6 +46,11 @@ const PersonalSetting = () => {
   const [countdown, setCountdown] = useState(30);
   const [affLink, setAffLink] = useState('');
   const [systemToken, setSystemToken] = useState('');
+  const [twoFactorEnabled, setTwoFactorEnabled] = useState(false);
+  // enable, disable or recovery, empty when the modal is closed
+  const [twoFactorAction, setTwoFactorAction] = useState('');
+  const [twoFactorSetup, setTwoFactorSetup] = useState({ secret: '', uri: '' });
+  const [recoveryCodes, setRecoveryCodes] = useState([]);
 
   useEffect(() => {
     let status = localStorage.getItem('status');
@@ -56,8 +62,17 @@ const PersonalSetting = () => {
         setTurnstileSiteKey(status.turnstile_site_key);
       }
     }
+    loadTwoFactorStatus().then();
   }, []);
 
+  const loadTwoFactorStatus = async () => {
+    const res = await API.get('/api/user/self');
+    const { success, data } = res.data;
+    if (success) {
+      setTwoFactorEnabled(data.two_factor_enabled);
+    }
+  };
+
   useEffect(() => {
     let countdownInterval = null;
     if (disableButton && countdown > 0) {
@@ -184,6 +199,65 @@ const PersonalSetting = () => {
     setLoading(false);
   };
 
+  const openTwoFactorModal = async (action) => {
+    if (action === 'enable') {
+      const res = await API.get('/api/user/2fa/setup');
+      const { success, message, data } = res.data;
+      if (!success) {
+        showError(message);
+        return;
+      }
+      setTwoFactorSetup(data);
+    }
+    setRecoveryCodes([]);
+    setInputs((inputs) => ({ ...inputs, two_factor_code: '' }));
+    setTwoFactorAction(action);
+  };
+
+  const closeTwoFactorModal = () => {
+    setTwoFactorAction('');
+    setTwoFactorSetup({ secret: '', uri: '' });
+    setRecoveryCodes([]);
+    setInputs((inputs) => ({ ...inputs, two_factor_code: '' }));
+  };
+
+  const submitTwoFactor = async () => {
+    if (inputs.two_factor_code === '') return;
+    const url = {
+      enable: '/api/user/2fa/enable',
+      disable: '/api/user/2fa/disable',
+      recovery: '/api/user/2fa/recovery_codes',
+    }[twoFactorAction];
+    setLoading(true);
+    const res = await API.post(url, { code: inputs.two_factor_code });
+    const { success, message, data } = res.data;
+    setLoading(false);
+    if (!success) {
+      showError(message);
+      return;
+    }
+    switch (twoFactorAction) {
+      case 'enable':
+        setTwoFactorEnabled(true);
+        setRecoveryCodes(data.recovery_codes);
+        showSuccess(t('setting.personal.two_factor.messages.enabled'));
+        break;
+      case 'disable':
+        setTwoFactorEnabled(false);
+        closeTwoFactorModal();
+        showSuccess(t('setting.personal.two_factor.messages.disabled'));
+        break;
+      default:
+        setRecoveryCodes(data.recovery_codes);
+        showSuccess(t('setting.personal.two_factor.messages.regenerated'));
+    }
+  };
+
+  const copyRecoveryCodes = async () => {
+    await copy(recoveryCodes.join('\n'));
+    showSuccess(t('setting.personal.two_factor.messages.copied'));
+  };
+
   return (
     <div style={{ lineHeight: '40px' }}>
       <Header as='h3'>{t('setting.personal.general.title')}</Header>
@@ -224,6 +298,138 @@ const PersonalSetting = () => {
         />
       )}
       <Divider />
+      <Header as='h3'>{t('setting.personal.two_factor.title')}</Header>
+      <Message>
+        {twoFactorEnabled
+          ? t('setting.personal.two_factor.enabled_notice')
+          : t('setting.personal.two_factor.disabled_notice')}
+      </Message>
+      {twoFactorEnabled ? (
+        <>
+          <Button onClick={() => openTwoFactorModal('recovery')}>
+            {t('setting.personal.two_factor.buttons.regenerate')}
+          </Button>
+          <Button onClick={() => openTwoFactorModal('disable')}>
+            {t('setting.personal.two_factor.buttons.disable')}
+          </Button>
+        </>
+      ) : (
+        <Button onClick={() => openTwoFactorModal('enable')}>
+          {t('setting.personal.two_factor.buttons.enable')}
+        </Button>
+      )}
+      <Modal
+        onClose={closeTwoFactorModal}
+        open={twoFactorAction !== ''}
+        size={'tiny'}
+        style={{ maxWidth: '450px' }}
+      >
+        <Modal.Header>
+          {twoFactorAction &&
+            t(`setting.personal.two_factor.${twoFactorAction}.title`)}
+        </Modal.Header>
+        <Modal.Content>
+          <Modal.Description>
+            {recoveryCodes.length > 0 ? (
+              <Form size='large'>
+                <Message warning visible>
+                  {t('setting.personal.two_factor.recovery_codes_notice')}
+                </Message>
+                <Form.TextArea
+                  readOnly
+                  rows={recoveryCodes.length}
+                  value={recoveryCodes.join('\n')}
+                  style={{ fontFamily: 'monospace' }}
+                />
+                <div
+                  style={{
+                    display: 'flex',
+                    justifyContent: 'space-between',
+                    marginTop: '1rem',
+                  }}
+                >
+                  <Button fluid size='large' onClick={copyRecoveryCodes}>
+                    {t('setting.personal.two_factor.buttons.copy')}
+                  </Button>
+                  <div style={{ width: '1rem' }}></div>
+                  <Button fluid size='large' onClick={closeTwoFactorModal}>
+                    {t('setting.personal.two_factor.buttons.done')}
+                  </Button>
+                </div>
+              </Form>
+            ) : (
+              <Form size='large'>
+                {twoFactorAction === 'enable' && (
+                  <>
+                    <Message>
+                      {t('setting.personal.two_factor.enable.description')}
+                    </Message>
+                    <Form.Input
+                      fluid
+                      readOnly
+                      label={t('setting.personal.two_factor.enable.secret')}
+                      value={twoFactorSetup.secret}
+                      onClick={async (e) => {
+                        e.target.select();
+                        await copy(twoFactorSetup.secret);
+                        showSuccess(
+                          t('setting.personal.two_factor.messages.copied')
+                        );
+                      }}
+                    />
+                    <Form.Input
+                      fluid
+                      readOnly
+                      label={t('setting.personal.two_factor.enable.uri')}
+                      value={twoFactorSetup.uri}
+                      onClick={async (e) => {
+                        e.target.select();
+                        await copy(twoFactorSetup.uri);
+                        showSuccess(
+                          t('setting.personal.two_factor.messages.copied')
+                        );
+                      }}
+                    />
+                  </>
+                )}
+                <Form.Input
+                  fluid
+                  placeholder={
+                    twoFactorAction === 'enable'
+                      ? t('setting.personal.two_factor.code_placeholder')
+                      : t('setting.personal.two_factor.code_or_recovery')
+                  }
+                  name='two_factor_code'
+                  value={inputs.two_factor_code}
+                  onChange={handleInputChange}
+                />
+                <div
+                  style={{
+                    display: 'flex',
+                    justifyContent: 'space-between',
+                    marginTop: '1rem',
+                  }}
+                >
+                  <Button
+                    color={twoFactorAction === 'disable' ? 'red' : ''}
+                    fluid
+                    size='large'
+                    onClick={submitTwoFactor}
+                    loading={loading}
+                  >
+                    {t('setting.personal.two_factor.buttons.confirm')}
+                  </Button>
+                  <div style={{ width: '1rem' }}></div>
+                  <Button fluid size='large' onClick={closeTwoFactorModal}>
+                    {t('setting.personal.two_factor.buttons.cancel')}
+                  </Button>
+                </div>
+              </Form>
+            )}
+          </Modal.Description>
+        </Modal.Content>
+      </Modal>
+      <Divider />
       <Header as='h3'>{t('setting.personal.binding.title')}</Header>
       {status.wechat_login && (
         <Button onClick={() => setShowWeChatBindModal(true)}>
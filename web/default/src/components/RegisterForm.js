@@ -36,6 +36,8 @@ const RegisterForm = () => {
   if (affCode) {
     localStorage.setItem('aff', affCode);
   }
+  // the registration invite link is /register?invite=<code>
+  const inviteCode = new URLSearchParams(window.location.search).get('invite');
 
   useEffect(() => {
     let status = localStorage.getItem('status');
@@ -89,6 +91,9 @@ const RegisterForm = () => {
         affCode = localStorage.getItem('aff');
       }
       inputs.aff_code = affCode;
+      if (inviteCode) {
+        inputs.invite_code = inviteCode;
+      }
       const res = await API.post(
         `/api/user/register?turnstile=${turnstileToken}`,
         inputs
@@ -96,7 +101,7 @@ const RegisterForm = () => {
       const { success, message } = res.data;
       if (success) {
         navigate('/login');
-        showSuccess(t('messages.success.register'));
+        showSuccess(message || t('messages.success.register'));
       } else {
         showError(message);
       }
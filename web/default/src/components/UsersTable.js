@@ -43,8 +43,13 @@ const UsersTable = () => {
   const [searchKeyword, setSearchKeyword] = useState('');
   const [searching, setSearching] = useState(false);
   const [orderBy, setOrderBy] = useState('');
+  const [showPending, setShowPending] = useState(false);
 
   const loadUsers = async (startIdx) => {
+    if (showPending) {
+      await loadPendingUsers();
+      return;
+    }
     const res = await API.get(`/api/user/?p=${startIdx}&order=${orderBy}`);
     const { success, message, data } = res.data;
     if (success) {
@@ -61,9 +66,24 @@ const UsersTable = () => {
     setLoading(false);
   };
 
+  // the users registered in the approval mode, they are returned at once
+  const loadPendingUsers = async () => {
+    const res = await API.get('/api/user/pending');
+    const { success, message, data } = res.data;
+    if (success) {
+      setUsers(data);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
   const onPaginationChange = (e, { activePage }) => {
     (async () => {
-      if (activePage === Math.ceil(users.length / ITEMS_PER_PAGE) + 1) {
+      if (
+        !showPending &&
+        activePage === Math.ceil(users.length / ITEMS_PER_PAGE) + 1
+      ) {
         // In this case we have to load more data and then append them.
         await loadUsers(activePage - 1, orderBy);
       }
@@ -77,7 +97,7 @@ const UsersTable = () => {
       .catch((reason) => {
         showError(reason);
       });
-  }, [orderBy]);
+  }, [orderBy, showPending]);
 
   const manageUser = (username, action, idx) => {
     (async () => {
@@ -114,6 +134,12 @@ const UsersTable = () => {
             {t('user.table.status_types.banned')}
           </Label>
         );
+      case 4:
+        return (
+          <Label basic color='orange'>
+            {t('user.table.status_types.pending')}
+          </Label>
+        );
       default:
         return (
           <Label basic color='grey'>
@@ -335,21 +361,33 @@ const UsersTable = () => {
                           {t('user.buttons.delete_user')} {user.username}
                         </Button>
                       </Popup>
-                      <Button
-                        size={'tiny'}
-                        onClick={() => {
-                          manageUser(
-                            user.username,
-                            user.status === 1 ? 'disable' : 'enable',
-                            idx
-                          );
-                        }}
-                        disabled={user.role === 100}
-                      >
-                        {user.status === 1
-                          ? t('user.buttons.disable')
-                          : t('user.buttons.enable')}
-                      </Button>
+                      {user.status === 4 ? (
+                        <Button
+                          size={'tiny'}
+                          positive
+                          onClick={() => {
+                            manageUser(user.username, 'approve', idx);
+                          }}
+                        >
+                          {t('user.buttons.approve')}
+                        </Button>
+                      ) : (
+                        <Button
+                          size={'tiny'}
+                          onClick={() => {
+                            manageUser(
+                              user.username,
+                              user.status === 1 ? 'disable' : 'enable',
+                              idx
+                            );
+                          }}
+                          disabled={user.role === 100}
+                        >
+                          {user.status === 1
+                            ? t('user.buttons.disable')
+                            : t('user.buttons.enable')}
+                        </Button>
+                      )}
                       <Button
                         size={'tiny'}
                         as={Link}
@@ -395,6 +433,18 @@ const UsersTable = () => {
                 onChange={handleOrderByChange}
                 style={{ marginLeft: '10px' }}
               />
+              <Button
+                size='small'
+                toggle
+                active={showPending}
+                onClick={() => {
+                  setShowPending(!showPending);
+                  setActivePage(1);
+                }}
+                style={{ marginLeft: '10px' }}
+              >
+                {t('user.buttons.show_pending')}
+              </Button>
               <Pagination
                 floated='right'
                 activePage={activePage}
@@ -26,4 +26,18 @@ export async function onLarkOAuthClicked(lark_client_id) {
   window.open(
     `https://open.feishu.cn/open-apis/authen/v1/index?redirect_uri=${redirect_uri}&app_id=${lark_client_id}&state=${state}`
   );
-}
\ No newline at end of file
+}
+// onOAuthProviderClicked starts the login with a provider of /api/oauth/providers
+export async function onOAuthProviderClicked(provider) {
+  const state = await getOAuthState();
+  if (!state) return;
+  const url = new URL(provider.authorization_endpoint);
+  url.searchParams.set('response_type', 'code');
+  url.searchParams.set('client_id', provider.client_id);
+  url.searchParams.set('redirect_uri', provider.redirect_uri);
+  url.searchParams.set('state', state);
+  if (provider.scopes) {
+    url.searchParams.set('scope', provider.scopes);
+  }
+  window.open(url.toString());
+}
@@ -2,6 +2,7 @@ import React, { useEffect, useState } from 'react';
 import { useTranslation } from 'react-i18next';
 import {
   Button,
+  Dropdown,
   Form,
   Header,
   Message,
@@ -14,6 +15,7 @@ import {
   copy,
   showError,
   showSuccess,
+  showWarning,
   timestamp2string,
 } from '../../helpers';
 import { renderQuotaWithPrompt } from '../../helpers/render';
@@ -34,6 +36,8 @@ const EditToken = () => {
     subnet: '',
   };
   const [inputs, setInputs] = useState(originInputs);
+  // the key is only returned once on creation, the server keeps its hash
+  const [createdKey, setCreatedKey] = useState('');
   const { name, remain_quota, expired_time, unlimited_quota } = inputs;
   const navigate = useNavigate();
   const handleInputChange = (e, { name, value }) => {
@@ -42,6 +46,61 @@ const EditToken = () => {
   const handleCancel = () => {
     navigate('/token');
   };
+
+  const COPY_OPTIONS = [
+    { key: 'raw', text: t('token.copy_options.raw'), value: '' },
+    { key: 'next', text: t('token.copy_options.next'), value: 'next' },
+    { key: 'ama', text: t('token.copy_options.ama'), value: 'ama' },
+    { key: 'opencat', text: t('token.copy_options.opencat'), value: 'opencat' },
+    { key: 'lobe', text: t('token.copy_options.lobe'), value: 'lobechat' },
+  ];
+
+  const onCopy = async (type, key) => {
+    let status = localStorage.getItem('status');
+    let serverAddress = '';
+    if (status) {
+      status = JSON.parse(status);
+      serverAddress = status.server_address;
+    }
+    if (serverAddress === '') {
+      serverAddress = window.location.origin;
+    }
+    let encodedServerAddress = encodeURIComponent(serverAddress);
+    const nextLink = localStorage.getItem('chat_link');
+    let nextUrl;
+
+    if (nextLink) {
+      nextUrl =
+        nextLink + `/#/?settings={"key":"sk-${key}","url":"${serverAddress}"}`;
+    } else {
+      nextUrl = `https://app.nextchat.dev/#/?settings={"key":"sk-${key}","url":"${serverAddress}"}`;
+    }
+
+    let url;
+    switch (type) {
+      case 'ama':
+        url = `ama://set-api-key?server=${encodedServerAddress}&key=sk-${key}`;
+        break;
+      case 'opencat':
+        url = `opencat://team/join?domain=${encodedServerAddress}&token=sk-${key}`;
+        break;
+      case 'next':
+        url = nextUrl;
+        break;
+      case 'lobechat':
+        url =
+          nextLink +
+          `/?settings={"keyVaults":{"openai":{"apiKey":"sk-${key}","baseURL":"${serverAddress}/v1"}}}`;
+        break;
+      default:
+        url = `sk-${key}`;
+    }
+    if (await copy(url)) {
+      showSuccess(t('token.messages.copy_success'));
+    } else {
+      showWarning(t('token.edit.messages.copy_failed'));
+    }
+  };
   const setExpiredTime = (month, day, hour, minute) => {
     let now = new Date();
     let timestamp = now.getTime() / 1000;
@@ -139,12 +198,13 @@ const EditToken = () => {
     } else {
       res = await API.post(`/api/token/`, localInputs);
     }
-    const { success, message } = res.data;
+    const { success, message, data } = res.data;
     if (success) {
       if (isEdit) {
         showSuccess(t('token.edit.messages.update_success'));
       } else {
         showSuccess(t('token.edit.messages.create_success'));
+        setCreatedKey(data.key);
         setInputs(originInputs);
       }
     } else {
@@ -159,6 +219,35 @@ const EditToken = () => {
           <Card.Header className='header'>
             {isEdit ? t('token.edit.title_edit') : t('token.edit.title_create')}
           </Card.Header>
+          {createdKey && (
+            <Message positive>
+              <Message.Header>{t('token.edit.key_created')}</Message.Header>
+              <p>{t('token.edit.key_notice')}</p>
+              <p>
+                <code>{`sk-${createdKey}`}</code>
+              </p>
+              <Button.Group color='green' size={'tiny'}>
+                <Button
+                  size={'tiny'}
+                  positive
+                  onClick={async () => await onCopy('', createdKey)}
+                >
+                  {t('token.buttons.copy')}
+                </Button>
+                <Dropdown
+                  className='button icon'
+                  floating
+                  options={COPY_OPTIONS.map((option) => ({
+                    ...option,
+                    onClick: async () => {
+                      await onCopy(option.value, createdKey);
+                    },
+                  }))}
+                  trigger={<></>}
+                />
+              </Button.Group>
+            </Message>
+          )}
           <Form loading={loading} autoComplete='new-password'>
             <Form.Field>
               <Form.Input